@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// adoptCmd represents the adopt command
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <container-name>",
+	Short: "Let k0da manage a k0s container created by hand",
+	Long: `Adopt a running k0s container that was not created by k0da, so that it shows
+up in 'k0da list', gets a kubeconfig context and can be deleted with 'k0da delete'.
+
+The container must run k0s ('k0s status' succeeds in it) and publish the API
+port 6443. It is recreated with the k0da labels, keeping its volumes.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAdopt,
+}
+
+var (
+	adoptAs      string
+	adoptTimeout string
+)
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+
+	adoptCmd.Flags().StringVar(&adoptAs, "as", "", "cluster name to adopt the container as (default: the container name)")
+	adoptCmd.Flags().StringVarP(&adoptTimeout, "timeout", "t", "60s", "how long to wait for k0s to come back after the container is recreated")
+}
+
+func runAdopt(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+	clusterName := strings.TrimSpace(adoptAs)
+	if clusterName == "" {
+		clusterName = containerName
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+
+	running, err := r.ContainerIsRunning(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("container '%s' not found: %w", containerName, err)
+	}
+	if !running {
+		return fmt.Errorf("container '%s' is not running", containerName)
+	}
+	existing, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("cluster '%s' already exists", clusterName)
+	}
+
+	status, exit, err := r.ExecInContainer(ctx, containerName, []string{"k0s", "status"})
+	if err != nil || exit != 0 {
+		return fmt.Errorf("container '%s' does not run k0s (k0s status failed): %s", containerName, strings.TrimSpace(status))
+	}
+	role, err := k0sRoleFromStatus(status)
+	if err != nil {
+		return fmt.Errorf("container '%s': %w", containerName, err)
+	}
+
+	fmt.Printf("Adopting %s '%s' as cluster '%s'...\n", role, containerName, clusterName)
+	labels := cluster.NodeLabels(clusterName, containerName, role, nil, nil)
+	if role == "controller" {
+		labels[k0daconfig.LabelNodePrimary] = "true"
+	}
+	if err := r.RecreateWithLabels(ctx, containerName, labels); err != nil {
+		return fmt.Errorf("failed to label container: %w", err)
+	}
+
+	if role != "controller" {
+		fmt.Printf("✅ Worker '%s' adopted as cluster '%s'; no kubeconfig is written for a worker-only cluster\n", containerName, clusterName)
+		return nil
+	}
+	if err := utils.WaitForK0sReady(ctx, r, os.Stdout, containerName, adoptTimeout); err != nil {
+		return fmt.Errorf("k0s did not become ready after adopting: %w", err)
+	}
+	if err := utils.AddClusterToKubeconfig(ctx, r, clusterName, containerName); err != nil {
+		return fmt.Errorf("failed to add cluster to kubeconfig: %w", err)
+	}
+
+	fmt.Printf("✅ Cluster '%s' adopted, kubectl context: k0da-%s\n", clusterName, clusterName)
+	return nil
+}
+
+// k0sRoleFromStatus returns the k0da node role, controller or worker, from `k0s status` output.
+func k0sRoleFromStatus(status string) (string, error) {
+	sc := bufio.NewScanner(strings.NewReader(status))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "Role" {
+			continue
+		}
+		if strings.Contains(value, "controller") {
+			return "controller", nil
+		}
+		return "worker", nil
+	}
+	return "", fmt.Errorf("no role in k0s status output")
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK0sRoleFromStatus(t *testing.T) {
+	role, err := k0sRoleFromStatus("Version: v1.33.3+k0s.0\nProcess ID: 42\nRole: controller\nWorkloads: true\n")
+	require.NoError(t, err)
+	assert.Equal(t, "controller", role)
+
+	role, err = k0sRoleFromStatus("Version: v1.33.3+k0s.0\nRole: worker\n")
+	require.NoError(t, err)
+	assert.Equal(t, "worker", role)
+
+	_, err = k0sRoleFromStatus("Error: can't get \"status\" via \"k0s.sock\"")
+	assert.Error(t, err)
+}
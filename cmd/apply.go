@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply manifests to a running cluster",
+	Long: `Apply Kubernetes manifests to a running cluster with 'k0s kubectl apply' on its
+primary controller, e.g. for quick experiments. -f takes files, directories (their
+.yaml, .yml and .json files) or - for stdin, and can be repeated.
+
+Unlike manifests in the cluster config, applied manifests are not kept with the
+cluster: they are not reapplied by k0s and 'k0da update' doesn't touch them.`,
+	Args: cobra.NoArgs,
+	RunE: runApply,
+}
+
+var (
+	applyName  string
+	applyFiles []string
+)
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyName, "name", "n", DefaultClusterName, "name of the cluster")
+	applyCmd.Flags().StringArrayVarP(&applyFiles, "filename", "f", nil, "manifest file, directory or - for stdin (repeatable)")
+	_ = applyCmd.MarkFlagRequired("filename")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	tmpDir, err := os.MkdirTemp("", "k0da-apply-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	files, err := manifestFiles(applyFiles, cmd.InOrStdin(), tmpDir)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := cluster.Apply(ctx, r, applyName, files)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(cmd.OutOrStdout(), out)
+	return nil
+}
+
+// manifestFiles expands the -f arguments into files in order: directories into their
+// manifests sorted by name, and - into a file in tmpDir holding stdin.
+func manifestFiles(args []string, stdin io.Reader, tmpDir string) ([]string, error) {
+	var files []string
+	readStdin := false
+	for _, arg := range args {
+		if arg == "-" {
+			if readStdin {
+				return nil, fmt.Errorf("stdin can only be read once")
+			}
+			readStdin = true
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read stdin: %w", err)
+			}
+			path := filepath.Join(tmpDir, "stdin.yaml")
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				return nil, err
+			}
+			files = append(files, path)
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("manifest not found: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var inDir []string
+		for _, e := range entries {
+			switch strings.ToLower(filepath.Ext(e.Name())) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					inDir = append(inDir, filepath.Join(arg, e.Name()))
+				}
+			}
+		}
+		if len(inDir) == 0 {
+			return nil, fmt.Errorf("no manifests found in %s", arg)
+		}
+		sort.Strings(inDir)
+		files = append(files, inDir...)
+	}
+	return files, nil
+}
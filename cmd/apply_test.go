@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestFiles(t *testing.T) {
+	dir := t.TempDir()
+	manifests := filepath.Join(dir, "manifests")
+	require.NoError(t, os.Mkdir(manifests, 0755))
+	for _, name := range []string{"b.yaml", "a.yml", "c.json", "README.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(manifests, name), []byte("{}"), 0644))
+	}
+	single := filepath.Join(dir, "pod.yaml")
+	require.NoError(t, os.WriteFile(single, []byte("kind: Pod"), 0644))
+
+	tmp := t.TempDir()
+	files, err := manifestFiles([]string{single, manifests, "-"}, strings.NewReader("kind: Service"), tmp)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		single,
+		filepath.Join(manifests, "a.yml"),
+		filepath.Join(manifests, "b.yaml"),
+		filepath.Join(manifests, "c.json"),
+		filepath.Join(tmp, "stdin.yaml"),
+	}, files)
+	data, err := os.ReadFile(files[4])
+	require.NoError(t, err)
+	assert.Equal(t, "kind: Service", string(data))
+
+	_, err = manifestFiles([]string{"-", "-"}, strings.NewReader(""), tmp)
+	assert.ErrorContains(t, err, "once")
+	_, err = manifestFiles([]string{filepath.Join(dir, "missing.yaml")}, nil, tmp)
+	assert.Error(t, err)
+	_, err = manifestFiles([]string{t.TempDir()}, nil, tmp)
+	assert.ErrorContains(t, err, "no manifests found")
+}
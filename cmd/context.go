@@ -3,8 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
+	"github.com/makhov/k0da/internal/paths"
 	"github.com/makhov/k0da/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -30,7 +30,7 @@ func init() {
 }
 
 func runContext(cmd *cobra.Command, args []string) error {
-	unifiedKubeconfigPath := filepath.Join(os.Getenv("HOME"), ".k0da", "clusters", "kubeconfig")
+	unifiedKubeconfigPath := paths.Kubeconfig()
 
 	// Check if unified kubeconfig exists
 	if _, err := os.Stat(unifiedKubeconfigPath); os.IsNotExist(err) {
@@ -2,15 +2,13 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/makhov/k0da/internal/cluster"
 	k0daconfig "github.com/makhov/k0da/internal/config"
-	"github.com/makhov/k0da/internal/runtime"
 	"github.com/makhov/k0da/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -32,6 +30,19 @@ var (
 	wait              bool
 	timeout           string
 	name              string
+	network           string
+	waitFor           string
+	envFiles          []string
+	expandEnv         bool
+	setValues         []string
+	createLabels      []string
+	createTTL         time.Duration
+	createOutput      string
+	apiServerAddress  string
+	attachNetworks    []string
+	withIngress       string
+	withMetrics       bool
+	pullPolicy        string
 )
 
 func init() {
@@ -43,6 +54,20 @@ func init() {
 	createCmd.Flags().StringVarP(&image, "image", "i", k0daconfig.DefaultK0sImageRepo+":"+k0daconfig.DefaultK0sVersion, "k0s image to use")
 	createCmd.Flags().BoolVarP(&wait, "wait", "w", true, "wait for cluster to be ready")
 	createCmd.Flags().StringVarP(&timeout, "timeout", "t", "60s", "timeout for cluster creation")
+	createCmd.Flags().StringVar(&waitFor, "wait-for", cluster.WaitForAPI, "readiness condition to wait for: api (API responds) or all (kube-system pods Ready)")
+	createCmd.Flags().BoolVar(&expandEnv, "expand-env", false, "expand ${VAR} and ${VAR:-default} in the config file from the environment")
+	createCmd.Flags().StringArrayVar(&setValues, "set", nil, "override a config value, e.g. spec.k0s.version=v1.34.0-k0s.0 (repeatable)")
+	createCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, "file with KEY=VALUE lines applied to all nodes; node env in the config wins (repeatable)")
+	createCmd.Flags().StringArrayVar(&createLabels, "label", nil, "label applied to all nodes of the cluster, e.g. team=blue (repeatable)")
+	createCmd.Flags().DurationVar(&createTTL, "ttl", 0, "expire the cluster after this duration, e.g. 2h; expired clusters are removed by 'k0da gc'")
+	createCmd.Flags().StringVarP(&createOutput, "output", "o", "", "print the result as json on success; progress goes to stderr")
+	createCmd.Flags().StringVar(&apiServerAddress, "api-server-address", "", "host or IP to reach the API at in the kubeconfig instead of 127.0.0.1 (overrides config)")
+	createCmd.Flags().StringArrayVar(&attachNetworks, "attach-network", nil, "additional network to connect all nodes to, created if missing (repeatable)")
+	createCmd.Flags().StringVar(&withIngress, "with-ingress", "", "install an ingress controller, nginx (default) or traefik, and publish its ports (sets options.ingress)")
+	createCmd.Flags().Lookup("with-ingress").NoOptDefVal = k0daconfig.IngressNginx
+	createCmd.Flags().BoolVar(&withMetrics, "with-metrics-server", false, "keep the metrics-server k0s deploys, for 'kubectl top' (sets options.metricsServer)")
+	createCmd.Flags().StringVar(&pullPolicy, "pull", "", "when to pull node images: missing (default), always or never (overrides config)")
+	createCmd.Flags().StringVar(&network, "network", "", "network to attach nodes to (overrides config); use existing:<name> to require a pre-existing network")
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
@@ -50,342 +75,99 @@ func runCreate(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		clusterName = args[0]
 	}
+	if waitFor != cluster.WaitForAPI && waitFor != cluster.WaitForAll {
+		return fmt.Errorf("invalid --wait-for value %q (expected %s or %s)", waitFor, cluster.WaitForAPI, cluster.WaitForAll)
+	}
+	if createOutput != "" && createOutput != "json" {
+		return fmt.Errorf("unsupported output format %q (expected json)", createOutput)
+	}
 
 	// Load cluster config (always returns a valid config)
-	cc, err := k0daconfig.LoadClusterConfig(strings.TrimSpace(clusterConfigPath))
+	cc, err := k0daconfig.LoadClusterConfigWithOptions(strings.TrimSpace(clusterConfigPath), k0daconfig.LoadOptions{ExpandEnv: expandEnv, Set: setValues})
 	if err != nil {
 		return fmt.Errorf("failed to load cluster config: %w", err)
 	}
-
-	// Determine final image with precedence: config > user-flag override > fetched stable > default
-	var finalImage string
-	if cc.Spec.K0s.Image != "" || cc.Spec.K0s.Version != "" {
-		finalImage = cc.Spec.K0s.EffectiveImage()
-	} else {
-		client := &http.Client{Timeout: 3 * time.Second}
-		if stable, err := k0daconfig.FetchStableK0sVersion(client); err == nil && strings.TrimSpace(stable) != "" {
-			finalImage = k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.NormalizeVersionTag(stable)
-		} else {
-			finalImage = k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.DefaultK0sVersion
-		}
+	if strings.TrimSpace(network) != "" {
+		cc.Spec.Options.Network = strings.TrimSpace(network)
+		cc.Spec.Options.NetworkCreate = nil
 	}
-
-	fmt.Printf("Creating k0s cluster '%s'...\n", clusterName)
-
-	// Detect container backend
-	ctx := context.Background()
-	r, err := runtime.Detect(ctx, runtime.DetectOptions{})
-	if err != nil {
-		return err
+	if strings.TrimSpace(apiServerAddress) != "" {
+		cc.Spec.Options.APIServerAddress = strings.TrimSpace(apiServerAddress)
 	}
-
-	// Create cluster directory
-	clusterDir := cc.ClusterDir(clusterName)
-	if err := os.MkdirAll(clusterDir, 0755); err != nil {
-		return fmt.Errorf("failed to create cluster directory: %w", err)
+	if withIngress != "" {
+		cc.Spec.Options.Ingress = withIngress
 	}
-
-	err = cc.WriteEffectiveK0sConfig(clusterName)
-	if err != nil {
-		return fmt.Errorf("failed to write effective k0s config: %w", err)
+	if withMetrics {
+		cc.Spec.Options.MetricsServer = true
 	}
-
-	// Create the primary node/container using backend
-	if err := createK0sCluster(ctx, r, clusterName, finalImage, wait, timeout, cc); err != nil {
-		return fmt.Errorf("failed to create k0s cluster: %w", err)
+	if pullPolicy != "" {
+		cc.Spec.Options.PullPolicy = pullPolicy
 	}
-
-	// If multinode defined, join additional nodes to the primary
-	if len(cc.Spec.Nodes) > 1 {
-		if err := joinAdditionalNodes(ctx, r, clusterName, image, wait, timeout, cc); err != nil {
-			return fmt.Errorf("failed to join additional nodes: %w", err)
+	if strings.TrimSpace(network) != "" || strings.TrimSpace(apiServerAddress) != "" || withIngress != "" || pullPolicy != "" {
+		if err := cc.Validate(); err != nil {
+			return fmt.Errorf("invalid cluster config: %w", err)
 		}
 	}
-
-	fmt.Printf("✅ Cluster '%s' created successfully!\n", clusterName)
-	fmt.Printf("To use this cluster, run: kubectl config use-context k0da-%s\n", clusterName)
-
-	return nil
-}
-
-func createK0sCluster(ctx context.Context, b runtime.Runtime, name, image string, wait bool, timeout string, cc *k0daconfig.ClusterConfig) error {
-	containerName := name
-	hostname := name
-
-	fmt.Printf("Creating container '%s' with image '%s' using %s...\n", containerName, image, b.Name())
-
-	// Ensure manifests directory exists on host for k0s manifests and copy manifests into it
-	hostK0daManifestsPath := cc.ManifestDir(name)
-	if err := utils.CopyManifestsToDir(cc, hostK0daManifestsPath); err != nil {
-		return fmt.Errorf("failed to stage manifests: %w", err)
-	}
-
-	// Build mounts
-	mounts := runtime.Mounts{
-		runtime.Mount{Type: "volume", Source: fmt.Sprintf("%s-var", name), Target: "/var"},
-		runtime.Mount{Type: "bind", Source: "/lib/modules", Target: "/lib/modules", Options: []string{"ro"}},
-	}
-	// Mount manifests directory into k0s manifests path
-	mounts = append(mounts, runtime.Mount{Type: "bind", Source: hostK0daManifestsPath, Target: "/var/lib/k0s/manifests/k0da"})
-	mounts = append(mounts, runtime.Mount{Type: "bind", Source: cc.ConfigPath(name), Target: "/etc/k0s/k0s.yaml", Options: []string{"ro"}})
-
-	// Node overrides/extensions
-	node := cc.PickPrimaryNode()
-	if node != nil {
-		for _, m := range node.Mounts {
-			mounts = append(mounts, runtime.Mount{Type: m.Type, Source: m.Source, Target: m.Target, Options: m.Options})
-		}
+	env, err := loadEnvFiles(envFiles)
+	if err != nil {
+		return err
 	}
 
-	// Build command args
-	cmdArgs := buildK0sControllerArgs(cc, node, true)
-
-	// Ports, Env, Labels
-	publish := buildPublishPortsFromNode(node)
-	publish = ensureAPIExposed(publish)
-	publish = ensureAPIPortBound(publish)
-	env := buildEnvFromNode(node)
-	labels := buildLabelsForNode(name, name, "controller", node)
-
-	// Effective image with node override
-	effectiveImage := image
-	if node != nil && strings.TrimSpace(node.Image) != "" {
-		effectiveImage = node.Image
+	// Detect container backend, within the creation timeout
+	ctx := context.Background()
+	detectCtx := ctx
+	if d, err := time.ParseDuration(timeout); err == nil && d > 0 {
+		var cancel context.CancelFunc
+		detectCtx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
 	}
-
-	// Ensure network exists and attach container to it (kind-like shared network)
-	networkName := cc.Spec.Options.Network
-	if err := b.EnsureNetwork(ctx, networkName); err != nil {
-		return fmt.Errorf("failed to ensure network: %w", err)
+	r, err := detectRuntime(detectCtx)
+	if err != nil {
+		return err
 	}
-
-	// Tmpfs mounts: always mount /run and /var/run
-	tmpfs := map[string]string{"/run": "", "/var/run": ""}
-
-	_, err := b.RunContainer(ctx, runtime.RunContainerOptions{
-		Name:        containerName,
-		Hostname:    hostname,
-		Image:       effectiveImage,
-		Args:        cmdArgs,
-		Env:         env,
-		Labels:      labels,
-		Mounts:      mounts,
-		Tmpfs:       tmpfs,
-		SecurityOpt: []string{"seccomp=unconfined", "apparmor=unconfined", "label=disable"},
-		Privileged:  true,
-		Publish:     publish,
-		Network:     networkName,
+	progress := cmd.OutOrStdout()
+	if createOutput == "json" {
+		// Keep stdout for the result only.
+		progress = cmd.ErrOrStderr()
+	}
+	result, err := cluster.Create(ctx, r, cluster.CreateOptions{
+		Name:     clusterName,
+		Config:   cc,
+		Image:    image,
+		Labels:   createLabels,
+		Env:      env,
+		Networks: attachNetworks,
+		TTL:      createTTL,
+		Wait:     wait,
+		WaitFor:  waitFor,
+		Timeout:  timeout,
+		Out:      progress,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create container: %w", err)
-	}
-
-	fmt.Printf("✅ Container created successfully\n")
-
-	if wait {
-		fmt.Println("Waiting for cluster to be ready...")
-		if err := utils.WaitForK0sReady(ctx, b, containerName, timeout); err != nil {
-			return fmt.Errorf("cluster failed to become ready: %w", err)
-		}
-		fmt.Println("✅ Cluster is ready!")
-
-		// Add cluster to unified kubeconfig
-		if err := utils.AddClusterToKubeconfig(ctx, b, name, containerName); err != nil {
-			return fmt.Errorf("failed to add cluster to kubeconfig: %w", err)
-		}
-	}
-
-	return nil
-}
-
-// joinAdditionalNodes creates tokens on the primary node and starts additional nodes defined in the config.
-func joinAdditionalNodes(ctx context.Context, b runtime.Runtime, clusterName, image string, wait bool, timeout string, cc *k0daconfig.ClusterConfig) error {
-	primary := clusterName
-	clusterDir := filepath.Join(os.Getenv("HOME"), ".k0da", "clusters", clusterName)
-	tokensDir := filepath.Join(clusterDir, "tokens")
-	if err := os.MkdirAll(tokensDir, 0755); err != nil {
-		return fmt.Errorf("create tokens dir: %w", err)
-	}
-
-	networkName := k0daconfig.DefaultNetwork
-	if cc != nil {
-		networkName = cc.Spec.Options.Network
+		return err
 	}
-	if err := b.EnsureNetwork(ctx, networkName); err != nil {
-		return fmt.Errorf("failed to ensure network: %w", err)
+	if createOutput == "json" {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
 	}
-
-	primaryNode := cc.PickPrimaryNode()
-	idx := 0
-	for i := range cc.Spec.Nodes {
-		n := &cc.Spec.Nodes[i]
-		if primaryNode != nil && &cc.Spec.Nodes[i] == primaryNode {
-			continue
-		}
-		role := strings.ToLower(strings.TrimSpace(n.Role))
-		if role == "" {
-			role = "worker"
-		}
-		tokenOut, exit, err := b.ExecInContainer(ctx, primary, []string{"k0s", "token", "create", "--role=" + role})
-		if err != nil || exit != 0 {
-			return fmt.Errorf("failed to create %s token on primary: %v", role, err)
-		}
-		token := strings.TrimSpace(tokenOut)
-		nodeName := strings.TrimSpace(n.Name)
-		if nodeName == "" {
-			nodeName = fmt.Sprintf("%s-%s-%d", clusterName, role, idx)
-			idx++
-		}
-		hostTokenPath := filepath.Join(tokensDir, nodeName+".token")
-		if err := os.WriteFile(hostTokenPath, []byte(token+"\n"), 0600); err != nil {
-			return fmt.Errorf("write token file: %v", err)
-		}
-
-		var cmdArgs []string
-		switch role {
-		case "controller":
-			cmdArgs = buildK0sControllerArgs(cc, n, false)
-		default:
-			cmdArgs = []string{"k0s", "worker", "--token-file", "/etc/k0s/join.token"}
-			if len(n.Args) > 0 {
-				cmdArgs = append(cmdArgs, n.Args...)
-			}
-		}
-
-		mounts := runtime.Mounts{
-			runtime.Mount{Type: "volume", Source: fmt.Sprintf("%s-var", nodeName), Target: "/var"},
-			runtime.Mount{Type: "bind", Source: "/lib/modules", Target: "/lib/modules", Options: []string{"ro"}},
-			runtime.Mount{Type: "bind", Source: hostTokenPath, Target: "/etc/k0s/join.token", Options: []string{"ro"}},
-		}
-
-		publish := buildPublishPortsFromNode(n)
-		// Env, Labels
-		env := buildEnvFromNode(n)
-		labels := buildLabelsForNode(clusterName, nodeName, role, n)
-
-		effectiveImage := image
-		if strings.TrimSpace(n.Image) != "" {
-			effectiveImage = n.Image
-		}
-
-		_, err = b.RunContainer(ctx, runtime.RunContainerOptions{
-			Name:        nodeName,
-			Hostname:    nodeName,
-			Image:       effectiveImage,
-			Args:        cmdArgs,
-			Env:         env,
-			Labels:      labels,
-			Mounts:      mounts,
-			Tmpfs:       map[string]string{"/run": "", "/var/run": ""},
-			SecurityOpt: []string{"seccomp=unconfined", "apparmor=unconfined", "label=disable"},
-			Privileged:  true,
-			Publish:     publish,
-			Network:     networkName,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to start node %s: %w", nodeName, err)
-		}
-		if wait {
-			// Only wait for controller nodes; workers don't expose the same status
-			if role == "controller" {
-				if err := utils.WaitForK0sReady(ctx, b, nodeName, timeout); err != nil {
-					return fmt.Errorf("node %s failed to become ready: %w", nodeName, err)
-				}
-			}
-		}
+	if result.Server != "" {
+		fmt.Printf("Kubernetes API: %s\n", result.Server)
 	}
 	return nil
 }
 
-// buildK0sControllerArgs builds k0s controller command arguments
-func buildK0sControllerArgs(cc *k0daconfig.ClusterConfig, node *k0daconfig.NodeSpec, isPrimary bool) []string {
-	cmdArgs := []string{"k0s", "controller", "--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks"}
-
-	// Add role-specific arguments
-	if len(cc.Spec.Nodes) == 1 {
-		cmdArgs = append(cmdArgs, "--single")
-	} else {
-		cmdArgs = append(cmdArgs, "--enable-worker", "--no-taints")
-	}
-
-	if !isPrimary {
-		cmdArgs = append(cmdArgs, "--token-file", "/etc/k0s/join.token")
-	}
-	cmdArgs = append(cmdArgs, "--config", "/etc/k0s/k0s.yaml")
-
-	// Add global k0s args
-	if len(cc.Spec.K0s.Args) > 0 {
-		cmdArgs = append(cmdArgs, cc.Spec.K0s.Args...)
-	}
-
-	// Add node-specific args
-	if node != nil && len(node.Args) > 0 {
-		cmdArgs = append(cmdArgs, node.Args...)
-	}
-
-	return cmdArgs
-}
-
-// Helpers
-func buildPublishPortsFromNode(node *k0daconfig.NodeSpec) []runtime.PortSpec {
-	publish := []runtime.PortSpec{}
-	if node != nil && len(node.Ports) > 0 {
-		for _, p := range node.Ports {
-			proto := strings.ToLower(p.Protocol)
-			if proto == "" {
-				proto = "tcp"
-			}
-			publish = append(publish, runtime.PortSpec{ContainerPort: p.ContainerPort, Protocol: proto, HostIP: p.HostIP, HostPort: p.HostPort})
-		}
-	}
-	return publish
-}
-
-func ensureAPIExposed(publish []runtime.PortSpec) []runtime.PortSpec {
-	hasAPI := false
-	for _, ps := range publish {
-		if ps.ContainerPort == 6443 && (ps.Protocol == "" || strings.ToLower(ps.Protocol) == "tcp") {
-			hasAPI = true
-			break
-		}
-	}
-	if !hasAPI {
-		publish = append(publish, runtime.PortSpec{ContainerPort: 6443, Protocol: "tcp"})
-	}
-	return publish
-}
-
-func ensureAPIPortBound(publish []runtime.PortSpec) []runtime.PortSpec {
-	for i := range publish {
-		if publish[i].ContainerPort == 6443 && (publish[i].Protocol == "" || strings.ToLower(publish[i].Protocol) == "tcp") {
-			if publish[i].HostPort == 0 {
-				hostIP := publish[i].HostIP
-				if p, err := utils.AllocateHostPort(hostIP); err == nil && p > 0 {
-					publish[i].HostPort = p
-				}
-			}
-			break
-		}
-	}
-	return publish
-}
-
-func buildEnvFromNode(node *k0daconfig.NodeSpec) runtime.EnvVars {
-	var env runtime.EnvVars
-	if node != nil && len(node.Env) > 0 {
-		for k, v := range node.Env {
-			env = append(env, runtime.EnvVar{Name: k, Value: v})
+// loadEnvFiles parses the --env-file flags in order; later files override earlier ones.
+func loadEnvFiles(paths []string) (map[string]string, error) {
+	env := map[string]string{}
+	for _, p := range paths {
+		vars, err := utils.ParseEnvFile(p)
+		if err != nil {
+			return nil, err
 		}
-	}
-	return env
-}
-
-func buildLabelsForNode(clusterName, nodeName, role string, node *k0daconfig.NodeSpec) map[string]string {
-	labels := map[string]string{k0daconfig.LabelCluster: "true", k0daconfig.LabelClusterName: clusterName, k0daconfig.LabelClusterType: "k0s", k0daconfig.LabelNodeName: nodeName, k0daconfig.LabelNodeRole: role}
-	if node != nil && len(node.Labels) > 0 {
-		for k, v := range node.Labels {
-			labels[k] = v
+		for k, v := range vars {
+			env[k] = v
 		}
 	}
-	return labels
+	return env, nil
 }
@@ -4,13 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 
-	k0daconfig "github.com/makhov/k0da/internal/config"
-	"github.com/makhov/k0da/internal/runtime"
-	"github.com/makhov/k0da/internal/utils"
+	"github.com/makhov/k0da/internal/cluster"
 )
 
 // deleteCmd represents the delete command
@@ -49,54 +46,13 @@ func runDelete(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
-	r, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	r, err := detectRuntime(ctx)
 	if err != nil {
 		return err
 	}
-
-	// Find all containers for this cluster and delete them
-	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, true)
-	if err != nil {
+	if err := cluster.Delete(ctx, r, clusterName, os.Stdout); err != nil {
 		return err
 	}
-	if len(list) == 0 {
-		return fmt.Errorf("cluster '%s' not found", clusterName)
-	}
-	// Stop running containers first
-	for _, c := range list {
-		running, err := r.ContainerIsRunning(ctx, c.Name)
-		if err == nil && running {
-			fmt.Printf("Stopping node '%s'...\n", c.Name)
-			_ = r.StopContainer(ctx, c.Name)
-		}
-	}
-	for _, c := range list {
-		fmt.Printf("Deleting node '%s'...\n", c.Name)
-		if err := r.RemoveContainer(ctx, c.Name); err != nil {
-			fmt.Printf("Warning: failed to remove container %s: %v\n", c.Name, err)
-		}
-		// Remove its volume
-		volName := fmt.Sprintf("%s-var", c.Name)
-		if exists, _ := r.VolumeExists(ctx, volName); exists {
-			fmt.Printf("Removing volume '%s'...\n", volName)
-			if err := r.RemoveVolume(ctx, volName); err != nil {
-				fmt.Printf("Warning: failed to remove volume '%s': %v\n", volName, err)
-			}
-		}
-	}
-
-	// Remove cluster from unified kubeconfig
-	if err := utils.RemoveClusterFromKubeconfig(clusterName); err != nil {
-		fmt.Printf("Warning: failed to remove cluster from kubeconfig: %v\n", err)
-	}
-
-	// Remove cluster working directory under $HOME/.k0da/clusters/<name>
-	if home, err := os.UserHomeDir(); err == nil {
-		dir := filepath.Join(home, ".k0da", "clusters", clusterName)
-		if err := os.RemoveAll(dir); err != nil {
-			fmt.Printf("Warning: failed to remove cluster directory %s: %v\n", dir, err)
-		}
-	}
 
 	fmt.Printf("✅ Cluster '%s' deleted successfully!\n", clusterName)
 	return nil
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment k0da runs in",
+	Long: `Check the environment k0da runs in and report problems.
+This command shows which container runtime was detected, the endpoint it
+talks to and its version, and whether the host uses cgroup v2, which helps
+diagnosing compatibility issues.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	if err != nil {
+		fmt.Printf("❌ Runtime:  %v\n", err)
+		return fmt.Errorf("no usable container runtime found")
+	}
+	fmt.Printf("✅ Runtime:  %s\n", r.Describe())
+
+	version, err := r.Version(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  Version:  unknown (%v)\n", err)
+	} else {
+		fmt.Printf("✅ Version:  %s %s\n", r.Name(), version)
+	}
+
+	switch v, err := utils.HostCgroupVersion(); {
+	case err != nil:
+		fmt.Printf("⚠️  Cgroups:  unknown (%v)\n", err)
+	case v == 0:
+		fmt.Printf("✅ Cgroups:  managed by the runtime VM\n")
+	case v == 1:
+		fmt.Printf("❌ Cgroups:  %v\n", utils.CheckCgroupV2())
+		return fmt.Errorf("host is not supported")
+	default:
+		fmt.Printf("✅ Cgroups:  v%d\n", v)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// testRuntime is the Runtime the tests of this package share. It keeps just enough
+// state for the calls they make; other Runtime methods are not used.
+type testRuntime struct {
+	runtime.Runtime
+	mu sync.Mutex
+
+	// nodes are the node containers; ListContainersByLabel returns the ones matching
+	// the selector.
+	nodes []runtime.ContainerInfo
+	// calls records renames and recreations as "rename <old> <new>" and
+	// "recreate <name>", with the options of each recreation in recreateOp.
+	calls      []string
+	recreateOp map[string]runtime.RecreateOptions
+	removed    []string
+
+	// logs are the logs per node; opts records the options of the last ContainerLogs.
+	logs map[string]string
+	opts runtime.LogsOptions
+}
+
+func (r *testRuntime) ListContainersByLabel(_ context.Context, selector map[string]string, _ bool) ([]runtime.ContainerInfo, error) {
+	var out []runtime.ContainerInfo
+	for _, c := range r.nodes {
+		match := true
+		for k, v := range selector {
+			if c.Labels[k] != v {
+				match = false
+			}
+		}
+		if match {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *testRuntime) ContainerExists(context.Context, string) (bool, error)    { return false, nil }
+func (r *testRuntime) ContainerIsRunning(context.Context, string) (bool, error) { return false, nil }
+func (r *testRuntime) VolumeExists(context.Context, string) (bool, error)       { return false, nil }
+
+func (r *testRuntime) RemoveContainer(_ context.Context, name string) error {
+	r.removed = append(r.removed, name)
+	return nil
+}
+
+func (r *testRuntime) RenameContainer(_ context.Context, oldName, newName string) error {
+	r.calls = append(r.calls, "rename "+oldName+" "+newName)
+	return nil
+}
+
+func (r *testRuntime) RecreateContainer(_ context.Context, name string, opts runtime.RecreateOptions) error {
+	r.calls = append(r.calls, "recreate "+name)
+	if r.recreateOp == nil {
+		r.recreateOp = map[string]runtime.RecreateOptions{}
+	}
+	r.recreateOp[name] = opts
+	return nil
+}
+
+func (r *testRuntime) ContainerLogs(_ context.Context, name string, opts runtime.LogsOptions, stdout, _ io.Writer) error {
+	r.mu.Lock()
+	r.opts = opts
+	r.mu.Unlock()
+	_, err := io.WriteString(stdout, r.logs[name])
+	return err
+}
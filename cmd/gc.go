@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete clusters past their expiry",
+	Long: `Delete all k0da clusters whose expiry, set with 'k0da create --ttl', has passed.
+Clusters created without --ttl are never touched. Use --dry-run to only list
+the clusters that would be deleted.`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+var gcDryRun bool
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "only list expired clusters, don't delete them")
+}
+
+// expiredCluster is a cluster whose expiry label lies in the past.
+type expiredCluster struct {
+	Name    string
+	Expires time.Time
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelCluster: "true"}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+	now := time.Now()
+	expired := expiredClusters(list, now)
+	if len(expired) == 0 {
+		fmt.Println("No expired clusters found.")
+		return nil
+	}
+
+	var failed []string
+	for _, c := range expired {
+		if gcDryRun {
+			fmt.Printf("Would delete cluster '%s' (expired %s)\n", c.Name, humanizeAge(c.Expires, now))
+			continue
+		}
+		fmt.Printf("Deleting cluster '%s' (expired %s)...\n", c.Name, humanizeAge(c.Expires, now))
+		if err := cluster.Delete(ctx, r, c.Name, os.Stdout); err != nil {
+			fmt.Printf("Warning: failed to delete cluster '%s': %v\n", c.Name, err)
+			failed = append(failed, c.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete clusters: %s", strings.Join(failed, ", "))
+	}
+	if !gcDryRun {
+		fmt.Printf("✅ Deleted %d expired cluster(s)\n", len(expired))
+	}
+	return nil
+}
+
+// expiredClusters returns the clusters among the node containers in list whose expiry
+// is before now, sorted by name. Nodes with a malformed expiry are ignored.
+func expiredClusters(list []runtime.ContainerInfo, now time.Time) []expiredCluster {
+	expires := map[string]time.Time{}
+	for _, c := range list {
+		cluster := c.Labels[k0daconfig.LabelClusterName]
+		v := c.Labels[k0daconfig.LabelClusterExpires]
+		if cluster == "" || v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			continue
+		}
+		if prev, ok := expires[cluster]; !ok || t.Before(prev) {
+			expires[cluster] = t
+		}
+	}
+
+	var out []expiredCluster
+	for name, t := range expires {
+		if t.Before(now) {
+			out = append(out, expiredCluster{Name: name, Expires: t})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
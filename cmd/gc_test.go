@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiredClusters(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	node := func(cluster, expires string) runtime.ContainerInfo {
+		labels := map[string]string{k0daconfig.LabelClusterName: cluster}
+		if expires != "" {
+			labels[k0daconfig.LabelClusterExpires] = expires
+		}
+		return runtime.ContainerInfo{Name: cluster, Labels: labels}
+	}
+
+	got := expiredClusters([]runtime.ContainerInfo{
+		node("old", "2025-06-01T10:00:00Z"),
+		node("old", "2025-06-01T10:00:05Z"),
+		node("fresh", "2025-06-01T14:00:00Z"),
+		node("forever", ""),
+		node("broken", "tomorrow"),
+	}, now)
+
+	assert.Equal(t, []expiredCluster{{Name: "old", Expires: time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)}}, got)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Print details of a cluster",
+}
+
+var (
+	getName string
+	getNode string
+)
+
+var getIPCmd = &cobra.Command{
+	Use:   "ip",
+	Short: "Print the IP address of a node on the cluster network",
+	Long: `Print the IP address of a node container on the cluster's container network,
+e.g. to reach the node from other containers on that network. Without --node the
+primary controller is used.`,
+	Args: cobra.NoArgs,
+	RunE: runGetIP,
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+	getCmd.AddCommand(getIPCmd)
+
+	getCmd.PersistentFlags().StringVarP(&getName, "name", "n", DefaultClusterName, "name of the cluster")
+	getIPCmd.Flags().StringVar(&getNode, "node", "", "node name or container name (default: primary controller)")
+}
+
+func runGetIP(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	node, err := resolveNode(ctx, r, getName, getNode)
+	if err != nil {
+		return err
+	}
+	ip, err := cluster.NodeIP(ctx, r, getName, node)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), ip)
+	return nil
+}
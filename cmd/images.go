@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "Inspect the images cached in cluster nodes",
+	Long: `Inspect the images cached in the containerd of cluster nodes, e.g. to debug
+ImagePullBackOff. Without a subcommand the images are listed.`,
+	Args: cobra.NoArgs,
+	RunE: runImagesList,
+}
+
+var (
+	imagesName string
+	imagesNode string
+)
+
+var imagesListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the images in a node's containerd",
+	Long: `List the images in the containerd of the primary controller, or of the node
+given with --node. Use --node all to list the images of every node, so an image
+missing on one of them stands out.`,
+	Args: cobra.NoArgs,
+	RunE: runImagesList,
+}
+
+var imagesRmCmd = &cobra.Command{
+	Use:     "rm <image-ref>",
+	Aliases: []string{"remove"},
+	Short:   "Remove an image from a node's containerd",
+	Long: `Remove an image from the containerd of the primary controller, or of the node
+given with --node (all for every node), e.g. to force pods to pull a :latest tag
+again. Nodes that do not have the image are reported but are not an error.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImagesRm,
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+	imagesCmd.AddCommand(imagesListCmd)
+	imagesCmd.AddCommand(imagesRmCmd)
+
+	imagesCmd.PersistentFlags().StringVarP(&imagesName, "name", "n", DefaultClusterName, "name of the cluster")
+	imagesCmd.PersistentFlags().StringVar(&imagesNode, "node", "", "node (container) name, or all for every node (default: primary controller)")
+}
+
+func runImagesList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	nodes, err := resolveNodes(ctx, r, imagesName, imagesNode)
+	if err != nil {
+		return err
+	}
+	images, err := cluster.ListImages(ctx, r, nodes...)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		fmt.Println("No images found.")
+		return nil
+	}
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Node != images[j].Node {
+			return images[i].Node < images[j].Node
+		}
+		return images[i].Ref < images[j].Ref
+	})
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	allNodes := imagesNode == "all"
+	if allNodes {
+		_, _ = fmt.Fprint(w, "NODE\t")
+	}
+	_, _ = fmt.Fprintln(w, "REF\tSIZE\tDIGEST")
+	for _, img := range images {
+		if allNodes {
+			_, _ = fmt.Fprintf(w, "%s\t", img.Node)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", img.Ref, img.Size, shortDigest(img.Digest))
+	}
+	return w.Flush()
+}
+
+func runImagesRm(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	nodes, err := resolveNodes(ctx, r, imagesName, imagesNode)
+	if err != nil {
+		return err
+	}
+	ref := cluster.QualifyImageRef(args[0])
+	var failed int
+	for _, node := range nodes {
+		removed, err := cluster.RemoveImage(ctx, r, node, ref)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("❌ %s: %v\n", node, err)
+		case removed:
+			fmt.Printf("✅ %s: removed %s\n", node, ref)
+		default:
+			fmt.Printf("%s: %s not present\n", node, ref)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %s on %d node(s)", ref, failed)
+	}
+	return nil
+}
+
+// resolveNodes is resolveNode that also accepts "all", which selects every running
+// node of the cluster.
+func resolveNodes(ctx context.Context, r runtime.Runtime, clusterName, node string) ([]string, error) {
+	if node != "all" {
+		name, err := resolveNode(ctx, r, clusterName, node)
+		if err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found or not running", clusterName)
+	}
+	nodes := make([]string, 0, len(list))
+	for _, c := range list {
+		nodes = append(nodes, c.Name)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// shortDigest shortens a sha256 digest to 12 hex characters, like image IDs in docker.
+func shortDigest(digest string) string {
+	hex, ok := strings.CutPrefix(digest, "sha256:")
+	if !ok || len(hex) <= 12 {
+		return digest
+	}
+	return "sha256:" + hex[:12]
+}
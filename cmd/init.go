@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter cluster config",
+	Long: `Write a commented example cluster config to get started with.
+The generated file documents the most common options (k0s version, inline k0s
+config, manifests, nodes, ports and mounts) and can be passed to
+'k0da create --config'.`,
+	Args: cobra.NoArgs,
+	RunE: runInit,
+}
+
+var (
+	initOutput string
+	initNodes  int
+	initForce  bool
+)
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "cluster.yaml", "file to write the config to, - for stdout")
+	initCmd.Flags().IntVar(&initNodes, "nodes", 1, "number of nodes: one controller plus workers")
+	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "overwrite an existing file")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	if initNodes < 1 {
+		return fmt.Errorf("--nodes must be at least 1")
+	}
+	content := renderInitConfig(initNodes)
+
+	if initOutput == "-" {
+		_, err := fmt.Fprint(cmd.OutOrStdout(), content)
+		return err
+	}
+	if _, err := os.Stat(initOutput); err == nil && !initForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", initOutput)
+	}
+	if err := os.WriteFile(initOutput, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "✅ Wrote %s, create the cluster with: k0da create --config %s\n", initOutput, initOutput)
+	return nil
+}
+
+// renderInitConfig returns a commented starter config with one controller and nodes-1 workers.
+func renderInitConfig(nodes int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `# k0da cluster config, see docs/user-guide/configuration.md for all options.
+apiVersion: %s
+kind: Cluster
+spec:
+  k0s:
+    # k0s version to run; alternatively set image: to a full image reference.
+    version: %s
+    # Extra arguments for every k0s process.
+    # args: ["--debug"]
+    # Inline k0s config, merged over the k0da defaults.
+    config:
+      spec:
+        telemetry:
+          enabled: false
+    # Manifests (files, directories or URLs) deployed into the cluster.
+    # manifests:
+    #   - ./manifests/
+  nodes:
+    - role: controller
+      # Publish extra container ports on the host; the API port is published automatically.
+      # ports:
+      #   - containerPort: 80
+      #     hostPort: 8080
+      # Mount host paths into the node.
+      # mounts:
+      #   - type: bind
+      #     source: /path/on/host
+      #     target: /path/in/node
+      #     options: ["ro"]
+      # env:
+      #   HTTP_PROXY: http://proxy.example.com:3128
+`, k0daconfig.APIVersion, k0daconfig.NormalizeVersionTag(k0daconfig.DefaultK0sVersion))
+	for i := 1; i < nodes; i++ {
+		b.WriteString("    - role: worker\n")
+	}
+	b.WriteString(`  options:
+    # Container network the nodes are attached to.
+    network: k0da
+`)
+	return b.String()
+}
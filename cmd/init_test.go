@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/makhov/k0da/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderInitConfig(t *testing.T) {
+	var cc config.ClusterConfig
+	require.NoError(t, yaml.Unmarshal([]byte(renderInitConfig(3)), &cc))
+	require.NoError(t, cc.Validate())
+
+	require.Len(t, cc.Spec.Nodes, 3)
+	assert.Equal(t, "controller", cc.Spec.Nodes[0].Role)
+	assert.Equal(t, "worker", cc.Spec.Nodes[1].Role)
+	assert.Equal(t, "worker", cc.Spec.Nodes[2].Role)
+	assert.NotEmpty(t, cc.Spec.K0s.Version)
+	assert.NotEmpty(t, cc.Spec.K0s.Config)
+
+	var single config.ClusterConfig
+	require.NoError(t, yaml.Unmarshal([]byte(renderInitConfig(1)), &single))
+	assert.Len(t, single.Spec.Nodes, 1)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect [cluster-name]",
+	Short: "Show full details of a k0da cluster",
+	Long: `Show full details of a k0da cluster as JSON: its nodes, network,
+the stored cluster config, the effective k0s config and the kubeconfig context.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runInspect,
+}
+
+var (
+	inspectName   string
+	inspectOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().StringVarP(&inspectName, "name", "n", DefaultClusterName, "name of the cluster to inspect")
+	inspectCmd.Flags().StringVarP(&inspectOutput, "output", "o", "json", "output format: json")
+}
+
+// ClusterDetail is the machine-readable form of `k0da inspect`.
+type ClusterDetail struct {
+	Name    string `json:"name"`
+	Context string `json:"context"`
+	Network string `json:"network,omitempty"`
+	// Labels are the user-defined cluster labels (create --label / spec.labels).
+	Labels map[string]string `json:"labels,omitempty"`
+	Nodes  []NodeDetail      `json:"nodes"`
+	// Config is the k0da cluster config the cluster was created or last updated with.
+	Config map[string]any `json:"config,omitempty"`
+	// K0sConfig is the effective k0s config mounted into the controller.
+	K0sConfig map[string]any `json:"k0s_config,omitempty"`
+}
+
+type NodeDetail struct {
+	Name        string            `json:"name"`
+	Role        string            `json:"role"`
+	ContainerID string            `json:"container_id"`
+	Image       string            `json:"image"`
+	Status      string            `json:"status"`
+	State       string            `json:"state"`
+	Health      string            `json:"health,omitempty"`
+	Ports       string            `json:"ports"`
+	Volume      string            `json:"volume"`
+	Labels      map[string]string `json:"labels"`
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	clusterName := inspectName
+	if len(args) > 0 {
+		clusterName = args[0]
+	}
+	if inspectOutput != "json" {
+		return fmt.Errorf("unsupported output format %q (expected json)", inspectOutput)
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+
+	detail, err := inspectCluster(ctx, r, clusterName)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(detail)
+}
+
+func inspectCluster(ctx context.Context, r runtime.Runtime, clusterName string) (*ClusterDetail, error) {
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("cluster '%s' not found", clusterName)
+	}
+
+	detail := &ClusterDetail{
+		Name:    clusterName,
+		Context: fmt.Sprintf("k0da-%s", clusterName),
+		Nodes:   make([]NodeDetail, 0, len(list)),
+	}
+	for _, c := range list {
+		if detail.Labels == nil {
+			detail.Labels = cluster.UserLabels(c)
+		}
+		detail.Nodes = append(detail.Nodes, NodeDetail{
+			Name:        c.Name,
+			Role:        c.Labels[k0daconfig.LabelNodeRole],
+			ContainerID: c.ID,
+			Image:       c.Image,
+			Status:      c.Status,
+			State:       c.State,
+			Health:      c.Health,
+			Ports:       c.Ports,
+			Volume:      cluster.NodeVolume(c),
+			Labels:      c.Labels,
+		})
+	}
+	sort.Slice(detail.Nodes, func(i, j int) bool { return detail.Nodes[i].Name < detail.Nodes[j].Name })
+
+	if detail.Config, err = readYAMLMap(paths.StoredConfigPath(clusterName)); err != nil {
+		return nil, fmt.Errorf("failed to read stored cluster config: %w", err)
+	}
+	if detail.K0sConfig, err = readYAMLMap(paths.ConfigPath(clusterName)); err != nil {
+		return nil, fmt.Errorf("failed to read effective k0s config: %w", err)
+	}
+
+	// Clusters created before the config was stored fall back to the default network.
+	detail.Network = k0daconfig.DefaultNetwork
+	if spec, ok := detail.Config["spec"].(map[string]any); ok {
+		if opts, ok := spec["options"].(map[string]any); ok {
+			if n, ok := opts["network"].(string); ok && n != "" {
+				detail.Network = n
+			}
+		}
+	}
+
+	return detail, nil
+}
+
+// readYAMLMap reads a YAML file into a generic map. A missing file yields a nil map.
+func readYAMLMap(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return out, nil
+}
@@ -3,8 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
+	"github.com/makhov/k0da/internal/paths"
 	"github.com/makhov/k0da/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -29,7 +29,7 @@ func init() {
 }
 
 func runKubeconfig(cmd *cobra.Command, args []string) error {
-	unifiedKubeconfigPath := filepath.Join(os.Getenv("HOME"), ".kube", "config")
+	unifiedKubeconfigPath := paths.Kubeconfig()
 
 	// Check if unified kubeconfig exists
 	if _, err := os.Stat(unifiedKubeconfigPath); os.IsNotExist(err) {
@@ -10,15 +10,23 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// setHome points the user home directory at dir for the test. os.UserHomeDir reads
+// $USERPROFILE on Windows, so both variables are set.
+func setHome(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
+
 func TestKubeconfigCommand(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
-	originalHome := os.Getenv("HOME")
-	_ = os.Setenv("HOME", tempDir)
-	defer func() { _ = os.Setenv("HOME", originalHome) }()
+	setHome(t, tempDir)
+
+	t.Setenv("KUBECONFIG", "")
 
 	// Create the unified kubeconfig directory
-	kubeconfigDir := filepath.Join(tempDir, ".k0da", "clusters")
+	kubeconfigDir := filepath.Join(tempDir, ".kube")
 	err := os.MkdirAll(kubeconfigDir, 0755)
 	require.NoError(t, err)
 
@@ -57,7 +65,7 @@ func TestKubeconfigCommand(t *testing.T) {
 	}
 
 	// Save the unified kubeconfig
-	unifiedKubeconfigPath := filepath.Join(kubeconfigDir, "kubeconfig")
+	unifiedKubeconfigPath := filepath.Join(kubeconfigDir, "config")
 	err = utils.SaveKubeconfig(unifiedKubeconfig, unifiedKubeconfigPath)
 	require.NoError(t, err)
 
@@ -92,12 +100,12 @@ func TestKubeconfigCommand(t *testing.T) {
 func TestKubeconfigCommandClusterNotFound(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
-	originalHome := os.Getenv("HOME")
-	_ = os.Setenv("HOME", tempDir)
-	defer func() { _ = os.Setenv("HOME", originalHome) }()
+	setHome(t, tempDir)
+
+	t.Setenv("KUBECONFIG", "")
 
 	// Create the unified kubeconfig directory
-	kubeconfigDir := filepath.Join(tempDir, ".k0da", "clusters")
+	kubeconfigDir := filepath.Join(tempDir, ".kube")
 	err := os.MkdirAll(kubeconfigDir, 0755)
 	require.NoError(t, err)
 
@@ -112,7 +120,7 @@ func TestKubeconfigCommandClusterNotFound(t *testing.T) {
 	}
 
 	// Save the unified kubeconfig
-	unifiedKubeconfigPath := filepath.Join(kubeconfigDir, "kubeconfig")
+	unifiedKubeconfigPath := filepath.Join(kubeconfigDir, "config")
 	err = utils.SaveKubeconfig(unifiedKubeconfig, unifiedKubeconfigPath)
 	require.NoError(t, err)
 
@@ -127,9 +135,8 @@ func TestKubeconfigCommandClusterNotFound(t *testing.T) {
 func TestKubeconfigCommandNoUnifiedKubeconfig(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
-	originalHome := os.Getenv("HOME")
-	_ = os.Setenv("HOME", tempDir)
-	defer func() { _ = os.Setenv("HOME", originalHome) }()
+	setHome(t, tempDir)
+	t.Setenv("KUBECONFIG", "")
 
 	// Test the kubeconfig command when no unified kubeconfig exists
 	kubeconfigClusterName = "test-cluster"
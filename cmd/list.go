@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
-	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/cluster"
 	"github.com/makhov/k0da/internal/runtime"
 	"github.com/spf13/cobra"
 )
@@ -23,8 +29,11 @@ This command shows clusters managed by k0da using container labels.`,
 }
 
 var (
-	all     bool
-	verbose bool
+	all         bool
+	verbose     bool
+	listFilters []string
+	listOutput  string
+	listState   string
 )
 
 func init() {
@@ -33,14 +42,42 @@ func init() {
 	// Here you will define your flags and configuration settings.
 	listCmd.Flags().BoolVarP(&all, "all", "a", false, "show all clusters including stopped ones")
 	listCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "show detailed information")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format: json or name (cluster names only, one per line)")
+	listCmd.Flags().StringVar(&listState, "state", "", "only show clusters that are running, stopped or all; stopped and all imply --all")
+	listCmd.Flags().StringArrayVar(&listFilters, "filter", nil, "filter clusters by name=<glob> or label=<key>=<value> (repeatable, AND-ed)")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	clusters, err := getK0daClusters(all)
+	if listOutput != "" && listOutput != "json" && listOutput != "name" {
+		return fmt.Errorf("unsupported output format %q (expected json or name)", listOutput)
+	}
+	filter, err := parseListFilters(listFilters)
+	if err != nil {
+		return err
+	}
+	switch listState {
+	case "", cluster.StateRunning, cluster.StateStopped, cluster.StateAll:
+	default:
+		return fmt.Errorf("invalid --state value %q (expected %s, %s or %s)", listState, cluster.StateRunning, cluster.StateStopped, cluster.StateAll)
+	}
+	filter.State = listState
+	includeStopped := all || listState == cluster.StateStopped || listState == cluster.StateAll
+	clusters, err := getK0daClusters(includeStopped, filter)
 	if err != nil {
 		return fmt.Errorf("failed to get clusters: %w", err)
 	}
 
+	if listOutput == "json" {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(ClusterList{SchemaVersion: ClusterListSchemaVersion, Clusters: clusters})
+	}
+
+	if listOutput == "name" {
+		printClusterNames(cmd.OutOrStdout(), clusters)
+		return nil
+	}
+
 	if len(clusters) == 0 {
 		fmt.Println("No k0da clusters found.")
 		return nil
@@ -55,75 +92,108 @@ func runList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-type ClusterInfo struct {
-	Name        string `json:"name"`
-	ContainerID string `json:"container_id"`
-	Image       string `json:"image"`
-	Status      string `json:"status"`
-	Ports       string `json:"ports"`
-	Created     string `json:"created"`
+// ClusterListSchemaVersion versions the JSON emitted by `k0da list -o json`. Fields are only
+// ever added within a schema version; renaming or removing one requires a new version.
+const ClusterListSchemaVersion = "v1"
+
+// ClusterList is the machine-readable form of `k0da list`, meant for editors and other tools.
+type ClusterList struct {
+	SchemaVersion string         `json:"schema_version"`
+	Clusters      []cluster.Info `json:"clusters"`
 }
 
-func getK0daClusters(includeStopped bool) ([]ClusterInfo, error) {
+// parseListFilters parses --filter values of the form name=<glob> and label=<key>=<value>.
+func parseListFilters(values []string) (cluster.Filter, error) {
+	f := cluster.Filter{Labels: map[string]string{}}
+	for _, v := range values {
+		kind, arg, ok := strings.Cut(strings.TrimSpace(v), "=")
+		if !ok || strings.TrimSpace(arg) == "" {
+			return f, fmt.Errorf("invalid filter %q (expected name=<glob> or label=<key>=<value>)", v)
+		}
+		switch kind {
+		case "name":
+			if _, err := path.Match(arg, ""); err != nil {
+				return f, fmt.Errorf("invalid name pattern %q: %w", arg, err)
+			}
+			f.NamePatterns = append(f.NamePatterns, arg)
+		case "label":
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return f, fmt.Errorf("invalid label filter %q (expected label=<key>=<value>)", v)
+			}
+			f.Labels[key] = value
+		default:
+			return f, fmt.Errorf("unknown filter %q (supported: name, label)", kind)
+		}
+	}
+	return f, nil
+}
+
+func getK0daClusters(includeStopped bool, filter cluster.Filter) ([]cluster.Info, error) {
 	ctx := context.Background()
-	b, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	b, err := detectRuntime(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return cluster.List(ctx, b, includeStopped, filter)
+}
 
-	selector := map[string]string{k0daconfig.LabelCluster: "true"}
-	list, err := b.ListContainersByLabel(ctx, selector, includeStopped)
-	if err != nil {
-		return nil, err
+// formatLabels renders labels as sorted key=value pairs, or "-" when there are none.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
 	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
 
-	// Group by cluster name; prefer controller node for display
-	grouped := map[string]runtime.ContainerInfo{}
-	for _, c := range list {
-		cluster := c.Name
-		if v, ok := c.Labels[k0daconfig.LabelClusterName]; ok && strings.TrimSpace(v) != "" {
-			cluster = v
-		}
-		if existing, ok := grouped[cluster]; ok {
-			role := strings.ToLower(c.Labels[k0daconfig.LabelNodeRole])
-			exrole := strings.ToLower(existing.Labels[k0daconfig.LabelNodeRole])
-			if exrole != "controller" && role == "controller" {
-				grouped[cluster] = c
-			}
-		} else {
-			grouped[cluster] = c
+// exitCodePattern matches the exit code in a status like "Exited (137) 1 hour ago".
+var exitCodePattern = regexp.MustCompile(`^\s*\w+\s+\((-?\d+)\)`)
+
+// formatState renders the normalized state of a cluster for `k0da list`, with the
+// health of a running cluster and the exit code of an exited one when they are known,
+// e.g. "running (healthy)" or "exited (137)".
+func formatState(c cluster.Info) string {
+	switch c.State {
+	case "":
+		return "-"
+	case runtime.StateRunning:
+		if c.Health != "" {
+			return c.State + " (" + c.Health + ")"
 		}
-	}
-	clusters := make([]ClusterInfo, 0, len(grouped))
-	for name, c := range grouped {
-		id := c.ID
-		if len(id) > 12 {
-			id = id[:12]
+	case runtime.StateExited:
+		if m := exitCodePattern.FindStringSubmatch(c.Status); m != nil {
+			return c.State + " (" + m[1] + ")"
 		}
-		clusters = append(clusters, ClusterInfo{
-			Name:        name,
-			ContainerID: id,
-			Image:       c.Image,
-			Status:      c.Status,
-			Ports:       c.Ports,
-			Created:     fmt.Sprintf("%d", c.Created),
-		})
 	}
+	return c.State
+}
 
-	return clusters, nil
+// printClusterNames prints one cluster name per line and nothing else, for scripts.
+func printClusterNames(w io.Writer, clusters []cluster.Info) {
+	for _, c := range clusters {
+		_, _ = fmt.Fprintln(w, c.Name)
+	}
 }
 
-func printSimpleList(clusters []ClusterInfo) {
+func printSimpleList(clusters []cluster.Info) {
 	fmt.Printf("Found %d k0da cluster(s):\n\n", len(clusters))
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "NAME\tSTATUS\tPORTS\tIMAGE")
-	_, _ = fmt.Fprintln(w, "----\t------\t-----\t-----")
+	_, _ = fmt.Fprintln(w, "NAME\tNODES\tSTATE\tAGE\tPORTS\tIMAGE")
+	_, _ = fmt.Fprintln(w, "----\t-----\t-----\t---\t-----\t-----")
 
+	now := time.Now()
 	for _, cluster := range clusters {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
 			cluster.Name,
-			cluster.Status,
+			cluster.Nodes,
+			formatState(cluster),
+			shortAge(cluster.Created, now),
 			cluster.Ports,
 			cluster.Image)
 	}
@@ -131,7 +201,7 @@ func printSimpleList(clusters []ClusterInfo) {
 	_ = w.Flush()
 }
 
-func printVerboseList(clusters []ClusterInfo) {
+func printVerboseList(clusters []cluster.Info) {
 	fmt.Printf("Found %d k0da cluster(s):\n\n", len(clusters))
 
 	for i, cluster := range clusters {
@@ -139,9 +209,63 @@ func printVerboseList(clusters []ClusterInfo) {
 		fmt.Printf("  Name:        %s\n", cluster.Name)
 		fmt.Printf("  Container:   %s\n", cluster.ContainerID)
 		fmt.Printf("  Image:       %s\n", cluster.Image)
+		fmt.Printf("  Nodes:       %d\n", cluster.Nodes)
+		fmt.Printf("  State:       %s\n", formatState(cluster))
 		fmt.Printf("  Status:      %s\n", cluster.Status)
 		fmt.Printf("  Ports:       %s\n", cluster.Ports)
-		fmt.Printf("  Created:     %s\n", cluster.Created)
+		fmt.Printf("  Labels:      %s\n", formatLabels(cluster.Labels))
+		fmt.Printf("  Created:     %s\n", formatCreated(cluster.Created, time.Now()))
 		fmt.Println()
 	}
 }
+
+// humanizeAge renders t relative to now, e.g. "3 hours ago", or "-" when unknown.
+func humanizeAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	if d < time.Minute {
+		return "less than a minute ago"
+	}
+	unit := func(n int, name string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", name)
+		}
+		return fmt.Sprintf("%d %ss ago", n, name)
+	}
+	switch {
+	case d < time.Hour:
+		return unit(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return unit(int(d/time.Hour), "hour")
+	default:
+		return unit(int(d/(24*time.Hour)), "day")
+	}
+}
+
+// shortAge renders the age of t in a compact kubectl-like form, e.g. "45s", "3h", "2d".
+func shortAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
+
+// formatCreated renders an RFC3339 timestamp together with its relative age.
+func formatCreated(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s)", t.Format(time.RFC3339), humanizeAge(t, now))
+}
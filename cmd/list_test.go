@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/runtime/runtimetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeAge(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		created  time.Time
+		expected string
+	}{
+		{time.Time{}, "-"},
+		{now.Add(-10 * time.Second), "less than a minute ago"},
+		{now.Add(-1 * time.Minute), "1 minute ago"},
+		{now.Add(-3 * time.Hour), "3 hours ago"},
+		{now.Add(-50 * time.Hour), "2 days ago"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, humanizeAge(tt.created, now))
+	}
+}
+
+func TestFormatCreated(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "-", formatCreated(time.Time{}, now))
+	assert.Equal(t, "2025-01-02T09:00:00Z (3 hours ago)", formatCreated(now.Add(-3*time.Hour), now))
+}
+
+func TestParseListFilters(t *testing.T) {
+	f, err := parseListFilters([]string{"name=dev-*", "label=team=blue", "label=env=ci"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "blue", "env": "ci"}, f.Labels)
+	assert.True(t, f.MatchName("dev-1"))
+	assert.False(t, f.MatchName("prod-1"))
+
+	for _, bad := range []string{"name", "name=", "label=team", "status=running", "name=[a"} {
+		_, err := parseListFilters([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestListFilterMatchNameMultiple(t *testing.T) {
+	f, err := parseListFilters([]string{"name=dev-*", "name=*-blue"})
+	assert.NoError(t, err)
+	assert.True(t, f.MatchName("dev-blue"))
+	assert.False(t, f.MatchName("dev-red"))
+	assert.True(t, cluster.Filter{}.MatchName("anything"))
+}
+
+func TestShortAge(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "-", shortAge(time.Time{}, now))
+	assert.Equal(t, "45s", shortAge(now.Add(-45*time.Second), now))
+	assert.Equal(t, "3h", shortAge(now.Add(-3*time.Hour), now))
+	assert.Equal(t, "2d", shortAge(now.Add(-50*time.Hour), now))
+}
+
+func TestClusterListJSONSchema(t *testing.T) {
+	node := runtimetest.Node("dev", "dev", "controller", runtime.StateRunning)
+	node.ID, node.Ports = "c0", "0.0.0.0:55131->6443/tcp"
+	clusters, err := cluster.List(context.Background(), &testRuntime{nodes: []runtime.ContainerInfo{node}}, true, cluster.Filter{})
+	assert.NoError(t, err)
+	data, err := json.Marshal(ClusterList{SchemaVersion: ClusterListSchemaVersion, Clusters: clusters})
+	assert.NoError(t, err)
+
+	// External tools depend on these keys; only add new ones within a schema version.
+	var out map[string]any
+	assert.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "v1", out["schema_version"])
+	c := out["clusters"].([]any)[0].(map[string]any)
+	for _, key := range []string{"name", "context", "api_endpoint", "container_id", "image", "status", "ports", "nodes", "created"} {
+		assert.Contains(t, c, key)
+	}
+	assert.Equal(t, "k0da-dev", c["context"])
+	assert.Equal(t, "https://127.0.0.1:55131", c["api_endpoint"])
+	assert.Equal(t, float64(1), c["nodes"])
+}
+
+func TestFormatState(t *testing.T) {
+	assert.Equal(t, "running (healthy)", formatState(cluster.Info{State: runtime.StateRunning, Status: "Up 3 minutes (healthy)", Health: "healthy"}))
+	assert.Equal(t, "running", formatState(cluster.Info{State: runtime.StateRunning, Status: "Restarting (1) 3 seconds ago"}))
+	assert.Equal(t, "exited (137)", formatState(cluster.Info{State: runtime.StateExited, Status: "Exited (137) 1 hour ago", Health: "unhealthy"}))
+	assert.Equal(t, "exited", formatState(cluster.Info{State: runtime.StateExited, Status: "Stopped"}))
+	assert.Equal(t, "created", formatState(cluster.Info{State: runtime.StateCreated, Status: "Created"}))
+	assert.Equal(t, "-", formatState(cluster.Info{}))
+}
+
+func TestPrintClusterNames(t *testing.T) {
+	var out bytes.Buffer
+	printClusterNames(&out, []cluster.Info{{Name: "dev", Nodes: 3}, {Name: "prod"}})
+	assert.Equal(t, "dev\nprod\n", out.String())
+
+	out.Reset()
+	printClusterNames(&out, nil)
+	assert.Empty(t, out.String())
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "team=blue,ttl=2h", formatLabels(map[string]string{"ttl": "2h", "team": "blue"}))
+	assert.Equal(t, "-", formatLabels(nil))
+}
@@ -1,13 +1,14 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/cluster"
 	"github.com/spf13/cobra"
 )
 
@@ -17,7 +18,11 @@ var loadCmd = &cobra.Command{
 }
 
 var (
-	loadName string
+	loadName         string
+	loadFromRegistry bool
+	loadUsername     string
+	loadPasswordIn   bool
+	loadForce        bool
 )
 
 var loadArchiveCmd = &cobra.Command{
@@ -32,11 +37,31 @@ var loadArchiveCmd = &cobra.Command{
 
 var loadImageCmd = &cobra.Command{
 	Use:   "image [image-ref]",
-	Short: "Pull and load a container image into cluster's containerd",
-	Args:  cobra.ExactArgs(1),
+	Short: "Load a container image into cluster's containerd",
+	Long: `Load a container image into cluster's containerd.
+
+By default the image is taken from the host's Docker/Podman, where it must
+already exist, e.g. after 'docker build' or 'docker pull'. With --from-registry
+the node's containerd pulls the image from its registry instead, bypassing the
+host runtime. For private registries pass --username, and the password either
+with --password-stdin or in $K0DA_REGISTRY_PASSWORD.
+
+An image the node already has with the same ID as on the host is not loaded
+again; use --force to load it anyway.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		imageRef := args[0]
-		return runLoadImage(loadName, imageRef)
+		if loadFromRegistry {
+			password, err := registryPassword(loadUsername, loadPasswordIn, os.Stdin)
+			if err != nil {
+				return err
+			}
+			return runLoadFromRegistry(loadName, imageRef, loadUsername, password)
+		}
+		if loadUsername != "" || loadPasswordIn {
+			return fmt.Errorf("--username and --password-stdin require --from-registry")
+		}
+		return runLoadImage(loadName, imageRef, loadForce)
 	},
 }
 
@@ -47,65 +72,85 @@ func init() {
 
 	// --name flag with default from constant
 	loadCmd.PersistentFlags().StringVarP(&loadName, "name", "n", DefaultClusterName, "name of the cluster")
+	loadImageCmd.Flags().BoolVar(&loadFromRegistry, "from-registry", false, "pull the image from its registry on the node instead of taking it from the host runtime")
+	loadImageCmd.Flags().StringVar(&loadUsername, "username", "", "registry username for --from-registry")
+	loadImageCmd.Flags().BoolVar(&loadPasswordIn, "password-stdin", false, "read the registry password for --from-registry from stdin")
+	loadImageCmd.Flags().BoolVar(&loadForce, "force", false, "load the image even if the node already has the same image")
 }
 
 func runLoadArchive(clusterName, src string) error {
 	ctx := context.Background()
-	b, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	b, err := detectRuntime(ctx)
 	if err != nil {
 		return err
 	}
-	abs, err := filepath.Abs(src)
+	imported, err := cluster.LoadArchive(ctx, b, clusterName, src)
 	if err != nil {
 		return err
 	}
-	if _, err := os.Stat(abs); err != nil {
-		return fmt.Errorf("source not found: %s", abs)
-	}
-	// Copy to container /tmp
-	inContainer := "/tmp/" + filepath.Base(abs)
-	if err := b.CopyToContainer(ctx, clusterName, abs, inContainer); err != nil {
-		return err
-	}
-	// Import via k0s ctr
-	out, code, _ := b.ExecInContainer(ctx, clusterName, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", inContainer})
-	if code != 0 {
-		return fmt.Errorf("import failed: %s", out)
-	}
-	fmt.Println("✅ archive loaded")
+	fmt.Printf("✅ archive loaded, imported: %s\n", strings.Join(imported, ", "))
 	return nil
 }
 
-func runLoadImage(clusterName, imageRef string) error {
-	ctx := context.Background()
-	b, err := runtime.Detect(ctx, runtime.DetectOptions{})
-	if err != nil {
-		return err
-	}
-	name := clusterName
+func runLoadImage(clusterName, imageRef string, force bool) error {
 	// If imageRef looks like a local tar file, delegate to archive path
 	if strings.HasSuffix(imageRef, ".tar") || strings.HasSuffix(imageRef, ".tar.gz") || strings.HasSuffix(imageRef, ".tgz") {
 		return runLoadArchive(clusterName, imageRef)
 	}
-	// Save local runtime image to a temporary tar and import it
-	tmpDir, err := os.MkdirTemp("", "k0da-img-*")
+	ctx := context.Background()
+	b, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	imported, loaded, err := cluster.LoadImage(ctx, b, clusterName, imageRef, force)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = os.RemoveAll(tmpDir) }()
-	tarPath := filepath.Join(tmpDir, "image.tar")
+	if !loaded {
+		fmt.Printf("✅ image already loaded: %s\n", strings.Join(imported, ", "))
+		return nil
+	}
+	fmt.Printf("✅ image loaded from local runtime, imported: %s\n", strings.Join(imported, ", "))
+	return nil
+}
 
-	if err := b.SaveImageToTar(ctx, imageRef, tarPath); err != nil {
-		return fmt.Errorf("failed to save local image: %w", err)
+// registryPassword returns the registry password, read from the first line of stdin
+// with --password-stdin or taken from $K0DA_REGISTRY_PASSWORD. It is never accepted
+// as a flag, which would leave it in the shell history and process list.
+func registryPassword(username string, fromStdin bool, stdin io.Reader) (string, error) {
+	if !fromStdin {
+		password := os.Getenv("K0DA_REGISTRY_PASSWORD")
+		if password != "" && username == "" {
+			return "", fmt.Errorf("K0DA_REGISTRY_PASSWORD requires --username")
+		}
+		return password, nil
+	}
+	if username == "" {
+		return "", fmt.Errorf("--password-stdin requires --username")
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read the password from stdin: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("--password-stdin: no password on stdin")
 	}
-	inContainer := "/tmp/" + filepath.Base(tarPath)
-	if err := b.CopyToContainer(ctx, name, tarPath, inContainer); err != nil {
-		return fmt.Errorf("failed to copy image tar: %w", err)
+	return password, nil
+}
+
+// runLoadFromRegistry makes the node's containerd pull the image itself, so the
+// host runtime is not involved at all.
+func runLoadFromRegistry(clusterName, imageRef, username, password string) error {
+	ctx := context.Background()
+	b, err := detectRuntime(ctx)
+	if err != nil {
+		return err
 	}
-	out, code, _ := b.ExecInContainer(ctx, name, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", inContainer})
-	if code != 0 {
-		return fmt.Errorf("import failed: %s", out)
+	imported, err := cluster.LoadFromRegistry(ctx, b, clusterName, imageRef, username, password)
+	if err != nil {
+		return err
 	}
-	fmt.Println("✅ image loaded from local runtime")
+	fmt.Printf("✅ image pulled from registry, imported: %s\n", strings.Join(imported, ", "))
 	return nil
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryPassword(t *testing.T) {
+	t.Setenv("K0DA_REGISTRY_PASSWORD", "")
+
+	password, err := registryPassword("bob", true, strings.NewReader("s3cret\r\nignored\n"))
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", password)
+
+	_, err = registryPassword("bob", true, strings.NewReader(""))
+	require.Error(t, err)
+	_, err = registryPassword("", true, strings.NewReader("s3cret\n"))
+	require.Error(t, err)
+
+	password, err = registryPassword("", false, nil)
+	require.NoError(t, err)
+	require.Empty(t, password)
+
+	t.Setenv("K0DA_REGISTRY_PASSWORD", "fromenv")
+	password, err = registryPassword("bob", false, nil)
+	require.NoError(t, err)
+	require.Equal(t, "fromenv", password)
+	_, err = registryPassword("", false, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [cluster-name]",
+	Short: "Print the logs of cluster nodes",
+	Long: `Print the logs of a k0da cluster node, the primary controller unless --node
+is given. With --all-nodes the logs of every node are interleaved by time and
+each line is prefixed with the node name, like 'docker compose logs'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLogs,
+}
+
+var (
+	logsName       string
+	logsNode       string
+	logsAllNodes   bool
+	logsTail       int
+	logsSince      string
+	logsFollow     bool
+	logsTimestamps bool
+)
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().StringVarP(&logsName, "name", "n", DefaultClusterName, "name of the cluster")
+	logsCmd.Flags().StringVar(&logsNode, "node", "", "node (container) name to show logs of (default: primary controller)")
+	logsCmd.Flags().BoolVar(&logsAllNodes, "all-nodes", false, "show the logs of all nodes, prefixed with the node name")
+	logsCmd.Flags().IntVar(&logsTail, "tail", 0, "number of lines to show from the end of the logs of each node (default: all)")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "show logs since a timestamp (e.g. 2024-01-02T13:23:37Z) or relative duration (e.g. 5m)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "follow log output")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "show timestamps")
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	clusterName := logsName
+	if len(args) > 0 {
+		clusterName = args[0]
+	}
+	if logsAllNodes && logsNode != "" {
+		return fmt.Errorf("--node and --all-nodes are mutually exclusive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	opts := runtime.LogsOptions{Since: logsSince, Tail: logsTail, Follow: logsFollow, Timestamps: logsTimestamps}
+
+	if !logsAllNodes {
+		node, err := resolveNode(ctx, r, clusterName, logsNode)
+		if err != nil {
+			return err
+		}
+		return r.ContainerLogs(ctx, node, opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	}
+
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("cluster '%s' not found", clusterName)
+	}
+	nodes := make([]string, 0, len(list))
+	for _, c := range list {
+		nodes = append(nodes, c.Name)
+	}
+	sort.Strings(nodes)
+
+	if logsFollow {
+		return followLogs(ctx, r, nodes, opts, cmd.OutOrStdout())
+	}
+	lines, err := collectLogs(ctx, r, nodes, opts)
+	if err != nil {
+		return err
+	}
+	writeLogLines(cmd.OutOrStdout(), lines, nodeNameWidth(nodes), logsTimestamps)
+	return nil
+}
+
+// logLine is a single log line of a node.
+type logLine struct {
+	Node string
+	// Time is when the line was logged; zero when the runtime gave no timestamp.
+	Time time.Time
+	Text string
+}
+
+// collectLogs fetches the logs of all nodes concurrently and merges them by time.
+func collectLogs(ctx context.Context, r runtime.Runtime, nodes []string, opts runtime.LogsOptions) ([]logLine, error) {
+	// Timestamps are always requested: they are what the lines are merged by.
+	opts.Timestamps = true
+	perNode := make([][]logLine, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if err := r.ContainerLogs(ctx, node, opts, &buf, &buf); err != nil {
+				errs[i] = fmt.Errorf("failed to get logs of node '%s': %w", node, err)
+				return
+			}
+			perNode[i] = parseLogLines(node, buf.String())
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mergeLogLines(perNode...), nil
+}
+
+// parseLogLines splits timestamped log output of node into lines. Lines without
+// a leading RFC 3339 timestamp inherit the time of the line before them.
+func parseLogLines(node, output string) []logLine {
+	var lines []logLine
+	var last time.Time
+	for _, raw := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if raw == "" {
+			continue
+		}
+		line := logLine{Node: node, Time: last, Text: raw}
+		if ts, text, ok := strings.Cut(raw, " "); ok {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				line.Time, line.Text = t, text
+				last = t
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// mergeLogLines orders the lines of several nodes by time. Lines of one node keep
+// their order, and so do lines logged at the same time.
+func mergeLogLines(perNode ...[]logLine) []logLine {
+	var all []logLine
+	for _, lines := range perNode {
+		all = append(all, lines...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+	return all
+}
+
+// writeLogLines prints lines prefixed with their node name padded to width.
+func writeLogLines(w io.Writer, lines []logLine, width int, timestamps bool) {
+	for _, l := range lines {
+		text := l.Text
+		if timestamps && !l.Time.IsZero() {
+			text = l.Time.Format(time.RFC3339Nano) + " " + text
+		}
+		fmt.Fprintf(w, "%-*s | %s\n", width, l.Node, text)
+	}
+}
+
+// followLogs streams the logs of all nodes until ctx is cancelled, prefixing each
+// line with its node name. Lines are printed as they arrive.
+func followLogs(ctx context.Context, r runtime.Runtime, nodes []string, opts runtime.LogsOptions, w io.Writer) error {
+	width := nodeNameWidth(nodes)
+	var mu sync.Mutex
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			pw := &prefixWriter{mu: &mu, w: w, prefix: fmt.Sprintf("%-*s | ", width, node)}
+			defer pw.Flush()
+			if err := r.ContainerLogs(ctx, node, opts, pw, pw); err != nil {
+				errs[i] = fmt.Errorf("failed to follow logs of node '%s': %w", node, err)
+			}
+		}(i, node)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nodeNameWidth(nodes []string) int {
+	width := 0
+	for _, n := range nodes {
+		if len(n) > width {
+			width = len(n)
+		}
+	}
+	return width
+}
+
+// prefixWriter writes complete lines with a prefix to w. Writers sharing mu never
+// interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.writeLine(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes a trailing partial line, if any.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(string(p.buf))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, strings.TrimRight(line, "\r"))
+}
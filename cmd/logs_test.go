@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectLogs_InterleavesByTime(t *testing.T) {
+	r := &testRuntime{logs: map[string]string{
+		"dev":          "2024-01-01T10:00:00.000000001Z starting controller\r\n2024-01-01T10:00:02Z api up\r\n",
+		"dev-worker-1": "2024-01-01T10:00:01Z joining\n  continued\n2024-01-01T10:00:03Z joined\n",
+	}}
+	lines, err := collectLogs(context.Background(), r, []string{"dev", "dev-worker-1"}, runtime.LogsOptions{Tail: 100})
+	require.NoError(t, err)
+	assert.True(t, r.opts.Timestamps, "timestamps are needed to merge")
+	assert.Equal(t, 100, r.opts.Tail)
+
+	var out bytes.Buffer
+	writeLogLines(&out, lines, nodeNameWidth([]string{"dev", "dev-worker-1"}), false)
+	assert.Equal(t, `dev          | starting controller
+dev-worker-1 | joining
+dev-worker-1 |   continued
+dev          | api up
+dev-worker-1 | joined
+`, out.String())
+}
+
+func TestParseLogLines_WithoutTimestamps(t *testing.T) {
+	lines := parseLogLines("n", "plain line\n\nanother\n")
+	require.Len(t, lines, 2)
+	assert.True(t, lines[0].Time.IsZero())
+	assert.Equal(t, "plain line", lines[0].Text)
+	assert.Equal(t, "another", lines[1].Text)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	pw := &prefixWriter{mu: &mu, w: &out, prefix: "n | "}
+	_, _ = pw.Write([]byte("first\r\nsec"))
+	_, _ = pw.Write([]byte("ond\npartial"))
+	assert.Equal(t, "n | first\nn | second\n", out.String())
+	pw.Flush()
+	assert.Equal(t, "n | first\nn | second\nn | partial\n", out.String())
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Print cluster inventory metrics in Prometheus text format",
+	Long: `Print gauges about all k0da clusters on this host in the Prometheus text
+exposition format: the number of clusters, nodes per cluster and role, whether
+each node is running and its age. Redirect the output into the directory of the
+node_exporter textfile collector to scrape it, e.g. from a cron job:
+
+  k0da metrics > /var/lib/node_exporter/k0da.prom.$$ && mv /var/lib/node_exporter/k0da.prom.$$ /var/lib/node_exporter/k0da.prom`,
+	Args: cobra.NoArgs,
+	RunE: runMetrics,
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+}
+
+func runMetrics(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelCluster: "true"}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list clusters: %w", err)
+	}
+	return writeMetrics(cmd.OutOrStdout(), list, time.Now())
+}
+
+// nodeMetric is one node container as seen by writeMetrics.
+type nodeMetric struct {
+	cluster, node, role string
+	running             bool
+	created             int64
+}
+
+// writeMetrics writes the inventory gauges for the node containers in list.
+// Series are sorted so that the output is stable between runs.
+func writeMetrics(w io.Writer, list []runtime.ContainerInfo, now time.Time) error {
+	nodes := make([]nodeMetric, 0, len(list))
+	perRole := map[[2]string]int{}
+	clusters := map[string]bool{}
+	for _, c := range list {
+		cluster := c.Labels[k0daconfig.LabelClusterName]
+		if cluster == "" {
+			cluster = c.Name
+		}
+		role := c.Labels[k0daconfig.LabelNodeRole]
+		clusters[cluster] = true
+		perRole[[2]string{cluster, role}]++
+		nodes = append(nodes, nodeMetric{
+			cluster: cluster,
+			node:    c.Name,
+			role:    role,
+			running: c.State == runtime.StateRunning,
+			created: c.Created,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].cluster != nodes[j].cluster {
+			return nodes[i].cluster < nodes[j].cluster
+		}
+		return nodes[i].node < nodes[j].node
+	})
+	keys := make([][2]string, 0, len(perRole))
+	for k := range perRole {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	var b strings.Builder
+	writeMetricHeader(&b, "k0da_clusters", "Number of k0da clusters.")
+	fmt.Fprintf(&b, "k0da_clusters %d\n", len(clusters))
+
+	writeMetricHeader(&b, "k0da_cluster_nodes", "Number of nodes of a cluster by role.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "k0da_cluster_nodes{cluster=%s,role=%s} %d\n", metricLabel(k[0]), metricLabel(k[1]), perRole[k])
+	}
+
+	writeMetricHeader(&b, "k0da_node_running", "Whether the node container is running (1) or stopped (0).")
+	for _, n := range nodes {
+		running := 0
+		if n.running {
+			running = 1
+		}
+		fmt.Fprintf(&b, "k0da_node_running{%s} %d\n", n.labels(), running)
+	}
+
+	writeMetricHeader(&b, "k0da_node_age_seconds", "Seconds since the node container was created.")
+	for _, n := range nodes {
+		if n.created <= 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "k0da_node_age_seconds{%s} %d\n", n.labels(), int64(now.Sub(time.Unix(n.created, 0)).Seconds()))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (n nodeMetric) labels() string {
+	return fmt.Sprintf("cluster=%s,node=%s,role=%s", metricLabel(n.cluster), metricLabel(n.node), metricLabel(n.role))
+}
+
+func writeMetricHeader(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// metricLabel quotes a label value for the Prometheus text format.
+func metricLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
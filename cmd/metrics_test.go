@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/runtime/runtimetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	now := time.Unix(10_000, 0)
+	node := func(cluster, name, role, state string, created int64) runtime.ContainerInfo {
+		n := runtimetest.Node(cluster, name, role, state)
+		n.Created = created
+		return n
+	}
+	list := []runtime.ContainerInfo{
+		node("foo", "foo-worker-2", "worker", runtime.StateExited, 9_000),
+		node("foo", "foo", "controller", runtime.StateRunning, 8_000),
+		node("foo", "foo-worker-1", "worker", runtime.StateRunning, 9_000),
+		node(`b"ar`, "bar", "controller", runtime.StateRunning, 0),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeMetrics(&out, list, now))
+	assert.Equal(t, `# HELP k0da_clusters Number of k0da clusters.
+# TYPE k0da_clusters gauge
+k0da_clusters 2
+# HELP k0da_cluster_nodes Number of nodes of a cluster by role.
+# TYPE k0da_cluster_nodes gauge
+k0da_cluster_nodes{cluster="b\"ar",role="controller"} 1
+k0da_cluster_nodes{cluster="foo",role="controller"} 1
+k0da_cluster_nodes{cluster="foo",role="worker"} 2
+# HELP k0da_node_running Whether the node container is running (1) or stopped (0).
+# TYPE k0da_node_running gauge
+k0da_node_running{cluster="b\"ar",node="bar",role="controller"} 1
+k0da_node_running{cluster="foo",node="foo",role="controller"} 1
+k0da_node_running{cluster="foo",node="foo-worker-1",role="worker"} 1
+k0da_node_running{cluster="foo",node="foo-worker-2",role="worker"} 0
+# HELP k0da_node_age_seconds Seconds since the node container was created.
+# TYPE k0da_node_age_seconds gauge
+k0da_node_age_seconds{cluster="foo",node="foo",role="controller"} 2000
+k0da_node_age_seconds{cluster="foo",node="foo-worker-1",role="worker"} 1000
+k0da_node_age_seconds{cluster="foo",node="foo-worker-2",role="worker"} 1000
+`, out.String())
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// renameCmd represents the rename command
+var renameCmd = &cobra.Command{
+	Use:   "rename <old-name> <new-name>",
+	Short: "Rename a k0da cluster",
+	Long: `Rename a k0da cluster without recreating it from scratch.
+Node containers named after the cluster are renamed and, because container
+labels can't be changed in place, recreated with the new cluster name in their
+labels. The cluster state directory and the kubeconfig context are renamed too.
+
+Node volumes keep their original names and the nodes keep their hostnames,
+which are the Kubernetes node names.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRename,
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	oldName, newName := args[0], args[1]
+	if strings.TrimSpace(newName) == "" {
+		return fmt.Errorf("new cluster name is required")
+	}
+	if oldName == newName {
+		return fmt.Errorf("cluster is already named '%s'", newName)
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := renameCluster(ctx, r, oldName, newName); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Cluster '%s' renamed to '%s'\n", oldName, newName)
+	return nil
+}
+
+func renameCluster(ctx context.Context, r runtime.Runtime, oldName, newName string) error {
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: oldName}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("cluster '%s' not found", oldName)
+	}
+	existing, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: newName}, true)
+	if err != nil {
+		return fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("cluster '%s' already exists", newName)
+	}
+
+	// Check every target name before changing anything.
+	for _, c := range list {
+		target := renamedNodeName(c.Name, oldName, newName)
+		if target == c.Name {
+			continue
+		}
+		if exists, err := r.ContainerExists(ctx, target); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("container '%s' already exists", target)
+		}
+	}
+	oldDir, newDir := paths.ClusterDir(oldName), paths.ClusterDir(newName)
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("cluster directory %s already exists", newDir)
+	}
+
+	// Move the state directory first: the recreated nodes bind-mount it from its new place.
+	if _, err := os.Stat(oldDir); err == nil {
+		if err := os.MkdirAll(filepath.Dir(newDir), 0755); err != nil {
+			return fmt.Errorf("failed to create clusters directory: %w", err)
+		}
+		if err := os.Rename(oldDir, newDir); err != nil {
+			return fmt.Errorf("failed to move cluster directory: %w", err)
+		}
+	}
+
+	for _, c := range list {
+		target := renamedNodeName(c.Name, oldName, newName)
+		if target != c.Name {
+			fmt.Printf("Renaming node '%s' to '%s'...\n", c.Name, target)
+			if err := r.RenameContainer(ctx, c.Name, target); err != nil {
+				return fmt.Errorf("failed to rename node '%s': %w", c.Name, err)
+			}
+		}
+		labels := map[string]string{
+			k0daconfig.LabelClusterName: newName,
+			k0daconfig.LabelNodeName:    renamedNodeName(c.Labels[k0daconfig.LabelNodeName], oldName, newName),
+			k0daconfig.LabelNodeVolume:  cluster.NodeVolume(c),
+		}
+		opts := runtime.RecreateOptions{Labels: labels, MountSources: map[string]string{oldDir: newDir}}
+		if err := r.RecreateContainer(ctx, target, opts); err != nil {
+			return fmt.Errorf("failed to update node '%s': %w", target, err)
+		}
+	}
+
+	if err := utils.RenameClusterInKubeconfig(oldName, newName); err != nil {
+		return fmt.Errorf("failed to rename kubeconfig context: %w", err)
+	}
+	return nil
+}
+
+// renamedNodeName maps a node name derived from the cluster name to the new cluster name.
+// Custom node names are kept.
+func renamedNodeName(nodeName, oldName, newName string) string {
+	if nodeName == oldName {
+		return newName
+	}
+	if strings.HasPrefix(nodeName, oldName+"-") {
+		return newName + strings.TrimPrefix(nodeName, oldName)
+	}
+	return nodeName
+}
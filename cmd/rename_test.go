@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameCluster(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	setHome(t, t.TempDir())
+	t.Setenv("KUBECONFIG", "")
+
+	r := &testRuntime{nodes: []runtime.ContainerInfo{
+		{Name: "tmp", Labels: map[string]string{k0daconfig.LabelClusterName: "tmp", k0daconfig.LabelNodeName: "tmp"}},
+		{Name: "my-worker", Labels: map[string]string{k0daconfig.LabelClusterName: "tmp", k0daconfig.LabelNodeName: "my-worker"}},
+	}}
+
+	require.NoError(t, renameCluster(context.Background(), r, "tmp", "dev"))
+	assert.Equal(t, []string{"rename tmp dev", "recreate dev", "recreate my-worker"}, r.calls)
+	assert.Equal(t, map[string]string{
+		k0daconfig.LabelClusterName: "dev",
+		k0daconfig.LabelNodeName:    "dev",
+		k0daconfig.LabelNodeVolume:  "tmp-var",
+	}, r.recreateOp["dev"].Labels)
+	assert.Equal(t, paths.ClusterDir("dev"), r.recreateOp["dev"].MountSources[paths.ClusterDir("tmp")])
+	assert.Equal(t, "my-worker-var", r.recreateOp["my-worker"].Labels[k0daconfig.LabelNodeVolume])
+
+	r.nodes = append(r.nodes, runtime.ContainerInfo{Name: "dev", Labels: map[string]string{k0daconfig.LabelClusterName: "dev"}})
+	assert.ErrorContains(t, renameCluster(context.Background(), r, "tmp", "dev"), "already exists")
+	assert.ErrorContains(t, renameCluster(context.Background(), r, "missing", "x"), "not found")
+}
+
+func TestRenamedNodeName(t *testing.T) {
+	assert.Equal(t, "prod", renamedNodeName("tmp", "tmp", "prod"))
+	assert.Equal(t, "prod-worker-0", renamedNodeName("tmp-worker-0", "tmp", "prod"))
+	assert.Equal(t, "my-ctrl", renamedNodeName("my-ctrl", "tmp", "prod"))
+	assert.Equal(t, "tmpx-worker-0", renamedNodeName("tmpx-worker-0", "tmp", "prod"))
+}
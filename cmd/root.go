@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
+	"github.com/makhov/k0da/internal/runtime"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	quiet   bool
+)
 
 const DefaultClusterName = "k0da-cluster"
 
@@ -24,6 +29,16 @@ using k0s as the distribution.`,
 	SilenceErrors: true,
 }
 
+// ExitCodeError is returned by commands that pass on the exit code of a process they
+// ran, e.g. the shell of 'k0da shell'. The process reported its failure itself.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() error {
@@ -37,7 +52,21 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.k0da.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .k0da.yaml in the home directory)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress informational output such as the selected runtime")
+}
+
+// detectRuntime detects the container runtime and reports which one was selected
+// (on stderr, so machine-readable stdout stays clean) unless --quiet is set.
+func detectRuntime(ctx context.Context) (runtime.Runtime, error) {
+	r, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Using %s\n", r.Describe())
+	}
+	return r, nil
 }
 
 // initConfig reads in config file and ENV variables if set.
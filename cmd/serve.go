@@ -0,0 +1,444 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/plugins"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP API for managing clusters",
+	Long: `Run an HTTP server exposing JSON endpoints to manage k0da clusters:
+
+  GET    /clusters         list all clusters, running or stopped
+  POST   /clusters         create a cluster, responds once it is created
+  DELETE /clusters/{name}  delete a cluster
+
+Every request needs an "Authorization: Bearer <token>" header. The token is
+taken from --token or $K0DA_SERVE_TOKEN, or generated and printed at startup.
+By default the server only listens on localhost.
+
+Configs sent over HTTP are restricted to what can't be pointed at the host:
+node mounts, devices, runtimes, extra networks and port host IPs, the host
+network, manifests, image bundles and the security options (capabilities,
+security options, runtime socket, kernel modules, cgroup namespace) are
+rejected, and node images must come from a repository allowed with
+--allow-image (default ` + k0daconfig.DefaultK0sImageRepo + `).
+
+Nodes run privileged by default, which gives them root on the host. The
+server only creates such clusters with --allow-privileged; without it, only
+configs setting options.privileged to false are accepted. Only pass it when
+everyone holding the token may have root on this machine.
+
+Progress of the operations is logged to stdout.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+var (
+	serveAddr            string
+	serveToken           string
+	serveAllowPrivileged bool
+	serveAllowImages     []string
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "bearer token clients must send (default $K0DA_SERVE_TOKEN, or a generated one)")
+	serveCmd.Flags().BoolVar(&serveAllowPrivileged, "allow-privileged", false, "create clusters with privileged nodes, which gives token holders root on the host")
+	serveCmd.Flags().StringSliceVar(&serveAllowImages, "allow-image", []string{k0daconfig.DefaultK0sImageRepo}, "image repository node images may come from (repeatable)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(serveToken)
+	if token == "" {
+		token = strings.TrimSpace(os.Getenv("K0DA_SERVE_TOKEN"))
+	}
+	if token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("failed to generate a token: %w", err)
+		}
+		token = hex.EncodeToString(b)
+		fmt.Printf("Generated API token: %s\n", token)
+	}
+
+	srv := &http.Server{
+		Addr:              serveAddr,
+		Handler:           (&server{r: r, token: token, allowPrivileged: serveAllowPrivileged, allowImages: serveAllowImages}).routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+	fmt.Printf("Serving the k0da API on %s using %s\n", serveAddr, r.Describe())
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// createClusterRequest is the body of POST /clusters.
+type createClusterRequest struct {
+	Name string `json:"name"`
+	// Config is a k0da cluster config in YAML, as passed to 'k0da create --config'.
+	Config string `json:"config,omitempty"`
+	// Set holds dotted.path=value overrides, like 'k0da create --set'.
+	Set    []string          `json:"set,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+	// TTL expires the cluster after a duration such as "2h", like 'k0da create --ttl'.
+	TTL string `json:"ttl,omitempty"`
+	// Wait defaults to true.
+	Wait    *bool  `json:"wait,omitempty"`
+	WaitFor string `json:"waitFor,omitempty"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// deleteClusterResponse is the body of a successful DELETE /clusters/{name}.
+type deleteClusterResponse struct {
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// server implements the `k0da serve` API on top of the functions behind the
+// create, list and delete commands.
+type server struct {
+	r runtime.Runtime
+	// token is the bearer token every request must carry.
+	token string
+	// allowPrivileged lets clients create clusters with privileged nodes.
+	allowPrivileged bool
+	// allowImages are the repositories node images may come from.
+	allowImages []string
+
+	mu sync.Mutex
+	// creating holds the names of clusters being created, so that concurrent
+	// requests for the same name can't both pass the existence check.
+	creating map[string]bool
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /clusters", s.handleList)
+	mux.HandleFunc("POST /clusters", s.handleCreate)
+	mux.HandleFunc("DELETE /clusters/{name}", s.handleDelete)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the server's bearer token.
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// reserve marks name as being created; it fails if another request already is.
+func (s *server) reserve(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.creating[name] {
+		return false
+	}
+	if s.creating == nil {
+		s.creating = map[string]bool{}
+	}
+	s.creating[name] = true
+	return true
+}
+
+func (s *server) release(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.creating, name)
+}
+
+func (s *server) handleList(w http.ResponseWriter, req *http.Request) {
+	clusters, err := s.listClusters(req.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, clusters)
+}
+
+func (s *server) handleCreate(w http.ResponseWriter, req *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, errors.New("content type must be application/json"))
+		return
+	}
+	var body createClusterRequest
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	opts, err := body.createOptions()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateRemoteConfig(opts.Config, s.allowImages); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Config.Spec.Options.IsPrivileged() && !s.allowPrivileged {
+		writeJSONError(w, http.StatusForbidden, errors.New("privileged nodes are not allowed by this server; set options.privileged to false"))
+		return
+	}
+	if !s.reserve(opts.Name) {
+		writeJSONError(w, http.StatusConflict, fmt.Errorf("cluster '%s' is already being created", opts.Name))
+		return
+	}
+	defer s.release(opts.Name)
+
+	ctx := req.Context()
+	existing, err := s.r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: opts.Name}, true)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(existing) > 0 {
+		writeJSONError(w, http.StatusConflict, fmt.Errorf("cluster '%s' already exists", opts.Name))
+		return
+	}
+	// Finish creating even if the client goes away, so no half-created cluster is left behind.
+	opts.Out = os.Stdout
+	if _, err := cluster.Create(context.WithoutCancel(ctx), s.r, opts); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	clusters, err := s.listClusters(ctx)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, c := range clusters {
+		if c.Name == opts.Name {
+			writeJSON(w, http.StatusCreated, c)
+			return
+		}
+	}
+	writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("cluster '%s' not found after creating it", opts.Name))
+}
+
+func (s *server) handleDelete(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+	if err := validateClusterName(name); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx := req.Context()
+	list, err := s.r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: name}, true)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(list) == 0 {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("cluster '%s' not found", name))
+		return
+	}
+	if err := cluster.Delete(context.WithoutCancel(ctx), s.r, name, os.Stdout); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deleteClusterResponse{Name: name, Deleted: true})
+}
+
+func (s *server) listClusters(ctx context.Context) ([]cluster.Info, error) {
+	clusters, err := cluster.List(ctx, s.r, true, cluster.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+	return clusters, nil
+}
+
+// createOptions validates the request and turns it into cluster.Create options,
+// with the same defaults as the create command.
+func (body createClusterRequest) createOptions() (cluster.CreateOptions, error) {
+	opts := cluster.CreateOptions{
+		Name:    strings.TrimSpace(body.Name),
+		Image:   k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.DefaultK0sVersion,
+		Env:     body.Env,
+		Wait:    body.Wait == nil || *body.Wait,
+		WaitFor: body.WaitFor,
+		Timeout: body.Timeout,
+	}
+	if opts.Name == "" {
+		opts.Name = DefaultClusterName
+	}
+	if err := validateClusterName(opts.Name); err != nil {
+		return opts, err
+	}
+	if opts.WaitFor == "" {
+		opts.WaitFor = cluster.WaitForAPI
+	}
+	if opts.WaitFor != cluster.WaitForAPI && opts.WaitFor != cluster.WaitForAll {
+		return opts, fmt.Errorf("invalid waitFor value %q (expected %s or %s)", opts.WaitFor, cluster.WaitForAPI, cluster.WaitForAll)
+	}
+	if opts.Timeout == "" {
+		opts.Timeout = "60s"
+	}
+	if body.TTL != "" {
+		ttl, err := time.ParseDuration(body.TTL)
+		if err != nil {
+			return opts, fmt.Errorf("invalid ttl: %w", err)
+		}
+		opts.TTL = ttl
+	}
+	for k, v := range body.Labels {
+		opts.Labels = append(opts.Labels, k+"="+v)
+	}
+	sort.Strings(opts.Labels)
+
+	cc, err := k0daconfig.ParseClusterConfig([]byte(body.Config), k0daconfig.LoadOptions{Set: body.Set})
+	if err != nil {
+		return opts, fmt.Errorf("failed to load cluster config: %w", err)
+	}
+	opts.Config = cc
+	return opts, nil
+}
+
+// validateRemoteConfig rejects the parts of a cluster config that reach into the host
+// running the server, which clients of the HTTP API must not control: host mounts,
+// devices, networks and addresses, files or URLs read by k0da, images from outside
+// allowImages, and the security settings of node containers. Whether nodes may run
+// privileged is up to the caller.
+func validateRemoteConfig(cc *k0daconfig.ClusterConfig, allowImages []string) error {
+	if cc.Spec.K0s.Image != "" && !slices.Contains(allowImages, imageRepository(cc.Spec.K0s.EffectiveImage())) {
+		return fmt.Errorf("k0s.image: %s is not from an allowed repository", cc.Spec.K0s.Image)
+	}
+	if cc.Spec.Options.Network == "host" {
+		return fmt.Errorf("options.network can't be the host network over HTTP")
+	}
+	for i, n := range cc.Spec.Nodes {
+		if len(n.Mounts) > 0 {
+			return fmt.Errorf("nodes[%d].mounts can't be set over HTTP", i)
+		}
+		if len(n.Devices) > 0 {
+			return fmt.Errorf("nodes[%d].devices can't be set over HTTP", i)
+		}
+		if n.Runtime != "" {
+			return fmt.Errorf("nodes[%d].runtime can't be set over HTTP", i)
+		}
+		if len(n.Networks) > 0 {
+			return fmt.Errorf("nodes[%d].networks can't be set over HTTP", i)
+		}
+		if n.Image != "" && !slices.Contains(allowImages, imageRepository(n.Image)) {
+			return fmt.Errorf("nodes[%d].image: %s is not from an allowed repository", i, n.Image)
+		}
+		for j, p := range n.Ports {
+			if p.HostIP != "" {
+				return fmt.Errorf("nodes[%d].ports[%d].hostIP can't be set over HTTP", i, j)
+			}
+		}
+	}
+	// The server's own plugins are added to the manifests by the config parser.
+	pluginPaths, err := plugins.PluginManifestList()
+	if err != nil {
+		return fmt.Errorf("failed to list plugins: %w", err)
+	}
+	for _, m := range cc.Spec.K0s.Manifests {
+		if !slices.Contains(pluginPaths, m.Path) {
+			return fmt.Errorf("k0s.manifests can't be set over HTTP")
+		}
+	}
+	if len(cc.Spec.K0s.ImageBundles) > 0 {
+		return fmt.Errorf("k0s.imageBundles can't be set over HTTP")
+	}
+	o := cc.Spec.Options
+	for name, set := range map[string]bool{
+		"securityOpt":        len(o.SecurityOpt) > 0,
+		"capAdd":             len(o.CapAdd) > 0,
+		"capDrop":            len(o.CapDrop) > 0,
+		"mountKernelModules": o.MountKernelModules != nil,
+		"mountRuntimeSocket": o.MountRuntimeSocket,
+		"cgroupns":           o.CgroupNS != "",
+	} {
+		if set {
+			return fmt.Errorf("options.%s can't be set over HTTP", name)
+		}
+	}
+	return nil
+}
+
+// imageRepository returns the repository of an image reference, without its tag or digest.
+func imageRepository(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// clusterNamePattern matches names that are valid container names and safe as a
+// directory name.
+var clusterNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func validateClusterName(name string) error {
+	if !clusterNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid cluster name %q", name)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	if errors.Is(err, context.Canceled) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
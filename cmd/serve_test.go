@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newServeTestServer(t *testing.T) (*httptest.Server, *testRuntime) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	t.Setenv("KUBECONFIG", "")
+	setHome(t, t.TempDir())
+	r := &testRuntime{nodes: []runtime.ContainerInfo{{
+		Name:   "dev",
+		Image:  "quay.io/k0sproject/k0s:v1.33.3-k0s.0",
+		Status: "Up 2 minutes",
+		State:  runtime.StateRunning,
+		Ports:  "127.0.0.1:50000->6443/tcp",
+		Labels: map[string]string{
+			k0daconfig.LabelCluster:     "true",
+			k0daconfig.LabelClusterName: "dev",
+			k0daconfig.LabelNodeRole:    "controller",
+		},
+	}}}
+	ts := httptest.NewServer((&server{r: r, token: serveTestToken, allowPrivileged: true, allowImages: []string{k0daconfig.DefaultK0sImageRepo}}).routes())
+	t.Cleanup(ts.Close)
+	return ts, r
+}
+
+const serveTestToken = "secret"
+
+// serveRequest sends an authorized request with a JSON body, if any.
+func serveRequest(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+serveTestToken)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestServe_ListClusters(t *testing.T) {
+	ts, _ := newServeTestServer(t)
+
+	resp := serveRequest(t, http.MethodGet, ts.URL+"/clusters", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var clusters []cluster.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&clusters))
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "dev", clusters[0].Name)
+	assert.Equal(t, "https://127.0.0.1:50000", clusters[0].APIEndpoint)
+}
+
+func TestServe_DeleteCluster(t *testing.T) {
+	ts, r := newServeTestServer(t)
+
+	resp := serveRequest(t, http.MethodDelete, ts.URL+"/clusters/missing", "")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = serveRequest(t, http.MethodDelete, ts.URL+"/clusters/dev", "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var body deleteClusterResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, deleteClusterResponse{Name: "dev", Deleted: true}, body)
+	assert.Equal(t, []string{"dev"}, r.removed)
+}
+
+func TestServe_CreateRejectsBadRequests(t *testing.T) {
+	ts, _ := newServeTestServer(t)
+
+	for body, status := range map[string]int{
+		`{"name": "dev"}`:                        http.StatusConflict,
+		`{"name": "../etc"}`:                     http.StatusBadRequest,
+		`{"name": "x", "waitFor": "soon"}`:       http.StatusBadRequest,
+		`{"name": "x", "ttl": "tomorrow"}`:       http.StatusBadRequest,
+		`{"name": "x", "config": "spec: [oops"}`: http.StatusBadRequest,
+		`{"name": "x", "unknown": true}`:         http.StatusBadRequest,
+		// Host access is not for HTTP clients.
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    mounts:\n    - {type: bind, source: /, target: /host}"}`: http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    devices: [/dev/kvm]"}`:                                   http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  k0s:\n    manifests: [/etc/shadow]"}`:                                                      http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  k0s:\n    imageBundles: [https://example.com/b.tar]"}`:                                     http.StatusBadRequest,
+		`{"name": "x", "set": ["spec.options.mountRuntimeSocket=true"]}`:                                                              http.StatusBadRequest,
+		`{"name": "x", "set": ["spec.options.capAdd=[SYS_ADMIN]"]}`:                                                                   http.StatusBadRequest,
+		`{"name": "x", "set": ["spec.k0s.image=docker.io/library/alpine:3"]}`:                                                         http.StatusBadRequest,
+		`{"name": "x", "set": ["spec.options.network=host"]}`:                                                                         http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    image: docker.io/library/alpine:3"}`:                     http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    runtime: runsc"}`:                                        http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    networks: [bridge]"}`:                                    http.StatusBadRequest,
+		`{"name": "x", "config": "spec:\n  nodes:\n  - role: controller\n    ports:\n    - {containerPort: 80, hostIP: 10.0.0.1}"}`:   http.StatusBadRequest,
+	} {
+		resp := serveRequest(t, http.MethodPost, ts.URL+"/clusters", body)
+		var e errorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, body)
+		assert.NotEmpty(t, e.Error, body)
+	}
+}
+
+func TestServe_CreateRequiresAllowPrivileged(t *testing.T) {
+	// The server of newServeTestServer allows privileged nodes.
+	ts, r := newServeTestServer(t)
+	ts = httptest.NewServer((&server{r: r, token: serveTestToken, allowImages: []string{k0daconfig.DefaultK0sImageRepo}}).routes())
+	t.Cleanup(ts.Close)
+
+	for _, body := range []string{
+		`{"name": "x"}`,
+		`{"name": "x", "set": ["spec.options.privileged=true"]}`,
+	} {
+		resp := serveRequest(t, http.MethodPost, ts.URL+"/clusters", body)
+		var e errorResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&e))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, body)
+		assert.Contains(t, e.Error, "privileged", body)
+	}
+}
+
+func TestValidateRemoteConfig_AllowedImages(t *testing.T) {
+	cc := &k0daconfig.ClusterConfig{}
+	cc.Spec.K0s.Image = "quay.io/k0sproject/k0s:v1.34.1-k0s.0"
+	cc.Spec.Nodes = []k0daconfig.NodeSpec{{Role: "controller", Image: "quay.io/k0sproject/k0s@sha256:0123"}, {Role: "worker", Image: "registry.corp/k0s:v1.34.1-k0s.0"}}
+	require.ErrorContains(t, validateRemoteConfig(cc, []string{k0daconfig.DefaultK0sImageRepo}), "nodes[1].image")
+	require.NoError(t, validateRemoteConfig(cc, []string{k0daconfig.DefaultK0sImageRepo, "registry.corp/k0s"}))
+
+	assert.Equal(t, "registry.corp:5000/k0s", imageRepository("registry.corp:5000/k0s:v1"))
+	assert.Equal(t, "registry.corp:5000/k0s", imageRepository("registry.corp:5000/k0s"))
+	assert.Equal(t, "quay.io/k0sproject/k0s", imageRepository("quay.io/k0sproject/k0s:v1@sha256:0123"))
+}
+
+func TestServe_RequiresToken(t *testing.T) {
+	ts, _ := newServeTestServer(t)
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/clusters", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, auth)
+	}
+}
+
+func TestServe_CreateRequiresJSON(t *testing.T) {
+	ts, _ := newServeTestServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/clusters", strings.NewReader(`{"name": "x"}`))
+	req.Header.Set("Authorization", "Bearer "+serveTestToken)
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestServe_CreateIsExclusivePerName(t *testing.T) {
+	s := &server{}
+	require.True(t, s.reserve("x"))
+	require.False(t, s.reserve("x"))
+	require.True(t, s.reserve("y"))
+	s.release("x")
+	require.True(t, s.reserve("x"))
+}
+
+func TestCreateClusterRequestDefaults(t *testing.T) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	opts, err := createClusterRequest{Labels: map[string]string{"team": "blue", "env": "ci"}, TTL: "2h"}.createOptions()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultClusterName, opts.Name)
+	assert.True(t, opts.Wait)
+	assert.Equal(t, cluster.WaitForAPI, opts.WaitFor)
+	assert.Equal(t, "60s", opts.Timeout)
+	assert.Equal(t, []string{"env=ci", "team=blue"}, opts.Labels)
+	assert.NotNil(t, opts.Config)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/makhov/k0da/internal/cluster"
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/spf13/cobra"
+)
+
+// shellCmd represents the shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell [cluster-name]",
+	Short: "Open an interactive shell in a cluster node",
+	Long: `Open an interactive shell in a node of a k0da cluster.
+The primary controller is used unless --node is given. bash is used when the
+node image ships it, sh otherwise.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runShell,
+}
+
+var (
+	shellName string
+	shellNode string
+)
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+
+	shellCmd.Flags().StringVarP(&shellName, "name", "n", DefaultClusterName, "name of the cluster")
+	shellCmd.Flags().StringVar(&shellNode, "node", "", "node (container) name to open the shell in (default: primary controller)")
+}
+
+func runShell(cmd *cobra.Command, args []string) error {
+	clusterName := shellName
+	if len(args) > 0 {
+		clusterName = args[0]
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+
+	node, err := resolveNode(ctx, r, clusterName, shellNode)
+	if err != nil {
+		return err
+	}
+
+	shell := "/bin/sh"
+	if _, exit, err := r.ExecInContainer(ctx, node, []string{"sh", "-c", "command -v bash"}); err == nil && exit == 0 {
+		shell = "/bin/bash"
+	}
+
+	exit, err := r.ExecInContainerInteractive(ctx, node, []string{shell})
+	if err != nil {
+		return fmt.Errorf("failed to open shell in node '%s': %w", node, err)
+	}
+	if exit != 0 {
+		return &ExitCodeError{Code: exit}
+	}
+	return nil
+}
+
+// resolveNode returns the container name of node in the cluster, or of the primary
+// controller when node is empty.
+func resolveNode(ctx context.Context, r runtime.Runtime, clusterName, node string) (string, error) {
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to list cluster nodes: %w", err)
+	}
+	if len(list) == 0 {
+		return "", fmt.Errorf("cluster '%s' not found or not running", clusterName)
+	}
+	if node == "" {
+		return cluster.PrimaryContainer(ctx, r, clusterName)
+	}
+	for _, c := range list {
+		if c.Name == node || c.Labels[k0daconfig.LabelNodeName] == node {
+			return c.Name, nil
+		}
+	}
+	return "", fmt.Errorf("node '%s' not found in cluster '%s'", node, clusterName)
+}
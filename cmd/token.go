@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage k0s join tokens of a cluster",
+}
+
+var (
+	tokenName     string
+	tokenRole     string
+	tokenListRole string
+	tokenExpiry   time.Duration
+	tokenOutput   string
+)
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a join token on the cluster's primary controller",
+	Long: `Create a k0s join token on the cluster's primary controller and print it.
+Use it to join external machines with 'k0s worker --token-file' or 'k0s controller
+--token-file', or to debug joining. With --output the token is written to a file
+readable only by the current user instead.`,
+	Args: cobra.NoArgs,
+	RunE: runTokenCreate,
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the outstanding join tokens of a cluster",
+	Args:    cobra.NoArgs,
+	RunE:    runTokenList,
+}
+
+var tokenInvalidateCmd = &cobra.Command{
+	Use:   "invalidate <id>...",
+	Short: "Revoke join tokens by ID",
+	Long: `Revoke join tokens by the ID shown by 'k0da token list', e.g. when a token
+has leaked. Nodes that already joined with the token are not affected.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runTokenInvalidate,
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenInvalidateCmd)
+
+	tokenCmd.PersistentFlags().StringVarP(&tokenName, "name", "n", DefaultClusterName, "name of the cluster")
+	tokenCreateCmd.Flags().StringVar(&tokenRole, "role", "worker", "role of the node joining with the token: worker or controller")
+	tokenCreateCmd.Flags().DurationVar(&tokenExpiry, "expiry", 0, "expire the token after this duration, e.g. 1h (default: k0s default)")
+	tokenListCmd.Flags().StringVar(&tokenListRole, "role", "", "only list tokens of this role: worker or controller")
+	tokenCreateCmd.Flags().StringVarP(&tokenOutput, "output", "o", "", "write the token to this file instead of stdout")
+}
+
+func runTokenCreate(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := cluster.CreateToken(ctx, r, tokenName, tokenRole, tokenExpiry)
+	if err != nil {
+		return err
+	}
+	if tokenOutput == "" {
+		fmt.Fprintln(cmd.OutOrStdout(), token)
+		return nil
+	}
+	if err := os.WriteFile(tokenOutput, []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
+	fmt.Printf("✅ %s token written to %s\n", tokenRole, tokenOutput)
+	return nil
+}
+
+func runTokenList(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	tokens, err := cluster.ListTokens(ctx, r, tokenName, tokenListRole)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		fmt.Println("No join tokens found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tROLE\tEXPIRES")
+	for _, t := range tokens {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", t.ID, t.Role, t.Expires)
+	}
+	return w.Flush()
+}
+
+func runTokenInvalidate(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cluster.InvalidateToken(ctx, r, tokenName, args...); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Invalidated %d token(s)\n", len(args))
+	return nil
+}
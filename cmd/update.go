@@ -3,12 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/makhov/k0da/internal/cluster"
 	k0daconfig "github.com/makhov/k0da/internal/config"
-	"github.com/makhov/k0da/internal/runtime"
-	"github.com/makhov/k0da/internal/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +26,8 @@ var (
 	updateClusterCfg string
 	updateImage      string
 	updateTimeout    string
+	updateExpandEnv  bool
+	updateSetValues  []string
 )
 
 func init() {
@@ -36,6 +36,8 @@ func init() {
 	updateCmd.Flags().StringVarP(&updateName, "name", "n", DefaultClusterName, "name of the cluster to update")
 	updateCmd.Flags().StringVarP(&updateClusterCfg, "config", "c", "", "cluster config file")
 	updateCmd.Flags().StringVarP(&updateImage, "image", "i", k0daconfig.DefaultK0sImageRepo+":"+k0daconfig.DefaultK0sVersion, "k0s image to use (overrides config)")
+	updateCmd.Flags().BoolVar(&updateExpandEnv, "expand-env", false, "expand ${VAR} and ${VAR:-default} in the config file from the environment")
+	updateCmd.Flags().StringArrayVar(&updateSetValues, "set", nil, "override a config value, e.g. spec.k0s.version=v1.34.0-k0s.0 (repeatable)")
 	updateCmd.Flags().StringVarP(&updateTimeout, "timeout", "t", "60s", "timeout for readiness wait")
 }
 
@@ -49,7 +51,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Load cluster config (always returns a valid config)
-	cc, err := k0daconfig.LoadClusterConfig(strings.TrimSpace(updateClusterCfg))
+	cc, err := k0daconfig.LoadClusterConfigWithOptions(strings.TrimSpace(updateClusterCfg), k0daconfig.LoadOptions{ExpandEnv: updateExpandEnv, Set: updateSetValues})
 	if err != nil {
 		return fmt.Errorf("failed to load cluster config: %w", err)
 	}
@@ -65,28 +67,13 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 
 	// Detect container backend
 	ctx := context.Background()
-	r, err := runtime.Detect(ctx, runtime.DetectOptions{})
+	r, err := detectRuntime(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Ensure cluster work dir exists
-	clusterDir := cc.ClusterDir(clusterName)
-	if err := os.MkdirAll(clusterDir, 0755); err != nil {
-		return fmt.Errorf("failed to create cluster directory: %w", err)
-	}
-
-	if err := utils.CopyManifestsToDir(cc, cc.ManifestDir(clusterName)); err != nil {
-		return fmt.Errorf("failed to stage manifests: %w", err)
-	}
-
-	err = cc.WriteEffectiveK0sConfig(clusterName)
-	if err != nil {
-		return fmt.Errorf("failed to write effective k0s config: %w", err)
-	}
-	// The config file should be mounted at /etc/k0s/k0s.yaml, so we can apply it directly
-	if out, exit, err := r.ExecInContainer(ctx, clusterName, []string{"k0s", "kc", "apply", "-f", "/etc/k0s/k0s.yaml"}); err != nil || exit != 0 {
-		return fmt.Errorf("failed to apply dynamic config via k0s: %v, out: %s", err, out)
+	if err := cluster.Update(ctx, r, cluster.UpdateOptions{Name: clusterName, Config: cc}); err != nil {
+		return err
 	}
 
 	fmt.Printf("✅ Cluster '%s' updated successfully!\n", clusterName)
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
 	"github.com/spf13/cobra"
 )
 
@@ -15,11 +19,79 @@ var (
 	BuildDate = ""
 )
 
+// VersionInfo is the machine-readable form of `k0da version`.
+type VersionInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildDate  string `json:"buildDate"`
+	K0sDefault string `json:"k0sDefault"`
+	// K0sStable and UpToDate are only populated with --check-latest.
+	K0sStable string `json:"k0sStable,omitempty"`
+	UpToDate  *bool  `json:"upToDate,omitempty"`
+	// RuntimeInfo is only populated with --runtime-info.
+	RuntimeInfo *RuntimeInfo `json:"runtimeInfo,omitempty"`
+}
+
+// RuntimeInfo holds the compiled-in defaults `k0da version --runtime-info` shows.
+type RuntimeInfo struct {
+	K0sImage string `json:"k0sImage"`
+	Network  string `json:"network"`
+	Home     string `json:"home"`
+	// Runtimes are the names of the registered runtime backends.
+	Runtimes []string `json:"runtimes"`
+}
+
+func runtimeInfo() *RuntimeInfo {
+	home, err := paths.Home()
+	if err != nil {
+		home = err.Error()
+	}
+	return &RuntimeInfo{
+		K0sImage: k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.NormalizeVersionTag(k0daconfig.DefaultK0sVersion),
+		Network:  k0daconfig.DefaultNetwork,
+		Home:     home,
+		Runtimes: runtime.Registered(),
+	}
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		w := cmd.OutOrStdout()
+		output, _ := cmd.Flags().GetString("output")
+		check, _ := cmd.Flags().GetBool("check-latest")
+		showRuntime, _ := cmd.Flags().GetBool("runtime-info")
+
+		switch output {
+		case "json":
+			info := VersionInfo{
+				Version:    Version,
+				Commit:     Commit,
+				BuildDate:  BuildDate,
+				K0sDefault: k0daconfig.NormalizeVersionTag(k0daconfig.DefaultK0sVersion),
+			}
+			if showRuntime {
+				info.RuntimeInfo = runtimeInfo()
+			}
+			if check {
+				client := &http.Client{Timeout: 3 * time.Second}
+				stable, err := k0daconfig.FetchStableK0sVersion(client)
+				if err != nil {
+					return fmt.Errorf("failed to check latest k0s version: %w", err)
+				}
+				info.K0sStable = k0daconfig.StableVersionAsImageTag(stable)
+				upToDate := info.K0sStable == info.K0sDefault
+				info.UpToDate = &upToDate
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		case "":
+		default:
+			return fmt.Errorf("unsupported output format %q (expected json)", output)
+		}
+
 		_, _ = fmt.Fprintf(w, "k0da %s", Version)
 		if Commit != "" {
 			_, _ = fmt.Fprintf(w, " (commit %s)", Commit)
@@ -29,7 +101,19 @@ var versionCmd = &cobra.Command{
 		}
 		_, _ = fmt.Fprintln(w)
 
-		if check, _ := cmd.Flags().GetBool("check-latest"); check {
+		if showRuntime {
+			ri := runtimeInfo()
+			runtimes := strings.Join(ri.Runtimes, ", ")
+			if runtimes == "" {
+				runtimes = "none"
+			}
+			_, _ = fmt.Fprintf(w, "Default k0s image: %s\n", ri.K0sImage)
+			_, _ = fmt.Fprintf(w, "Default network:   %s\n", ri.Network)
+			_, _ = fmt.Fprintf(w, "Home:              %s\n", ri.Home)
+			_, _ = fmt.Fprintf(w, "Runtime backends:  %s\n", runtimes)
+		}
+
+		if check {
 			client := &http.Client{Timeout: 3 * time.Second}
 			if stable, err := k0daconfig.FetchStableK0sVersion(client); err == nil {
 				stableTag := k0daconfig.StableVersionAsImageTag(stable)
@@ -43,10 +127,13 @@ var versionCmd = &cobra.Command{
 				_, _ = fmt.Fprintf(w, "Failed to check latest k0s version: %v\n", err)
 			}
 		}
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
 	versionCmd.Flags().Bool("check-latest", false, "check for the latest stable k0s version")
+	versionCmd.Flags().Bool("runtime-info", false, "show the compiled-in defaults: k0s image, network, home directory and runtime backends")
+	versionCmd.Flags().StringP("output", "o", "", "output format: json")
 }
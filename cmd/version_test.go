@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -14,10 +15,61 @@ func TestVersionCommand(t *testing.T) {
 	buf := new(bytes.Buffer)
 	versionCmd.SetOut(buf)
 	versionCmd.SetErr(buf)
-	// Call the Run function directly to avoid root command parsing
-	versionCmd.Run(versionCmd, []string{})
+	// Call the RunE function directly to avoid root command parsing
+	if err := versionCmd.RunE(versionCmd, []string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	out := buf.String()
 	if !strings.Contains(out, "k0da v1.2.3") || !strings.Contains(out, "abc1234") {
 		t.Fatalf("unexpected output: %q", out)
 	}
 }
+
+func TestVersionCommandJSON(t *testing.T) {
+	Version = "v1.2.3"
+	Commit = "abc1234"
+	BuildDate = "2025-01-01T00:00:00Z"
+
+	buf := new(bytes.Buffer)
+	versionCmd.SetOut(buf)
+	if err := versionCmd.Flags().Set("output", "json"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = versionCmd.Flags().Set("output", "") }()
+
+	if err := versionCmd.RunE(versionCmd, []string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var info VersionInfo
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.K0sDefault == "" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if info.UpToDate != nil || info.K0sStable != "" {
+		t.Fatalf("stable fields must be empty without --check-latest: %+v", info)
+	}
+}
+
+func TestVersionCommandRuntimeInfo(t *testing.T) {
+	t.Setenv("K0DA_HOME", "/tmp/k0da-home")
+	Version = "v1.2.3"
+
+	buf := new(bytes.Buffer)
+	versionCmd.SetOut(buf)
+	if err := versionCmd.Flags().Set("runtime-info", "true"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = versionCmd.Flags().Set("runtime-info", "false") }()
+
+	if err := versionCmd.RunE(versionCmd, []string{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Default k0s image: quay.io/k0sproject/k0s:", "Default network:   k0da", "Home:              /tmp/k0da-home", "Runtime backends:"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in output: %q", want, out)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// waitCmd represents the wait command
+var waitCmd = &cobra.Command{
+	Use:   "wait [cluster-name]",
+	Short: "Wait until a cluster is ready",
+	Long: `Block until a k0da cluster is ready, e.g. after 'k0da create --wait=false'.
+The condition is checked against the primary controller:
+
+  api    the Kubernetes API responds (default)
+  nodes  additionally, all nodes are Ready
+  all    additionally, all nodes and kube-system pods are Ready
+
+Once the API responds, the cluster is added to the kubeconfig if
+'k0da create --wait=false' couldn't do it yet. Exits non-zero when the
+condition is not met within --timeout, which covers all the checks together.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWait,
+}
+
+var (
+	waitName    string
+	waitCond    string
+	waitTimeout string
+)
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+
+	waitCmd.Flags().StringVarP(&waitName, "name", "n", DefaultClusterName, "name of the cluster to wait for")
+	waitCmd.Flags().StringVar(&waitCond, "for", cluster.WaitForAPI, "condition to wait for: api, nodes or all")
+	waitCmd.Flags().StringVarP(&waitTimeout, "timeout", "t", "2m", "how long to wait")
+}
+
+func runWait(cmd *cobra.Command, args []string) error {
+	clusterName := waitName
+	if len(args) > 0 {
+		clusterName = args[0]
+	}
+	if waitCond != cluster.WaitForAPI && waitCond != cluster.WaitForNodes && waitCond != cluster.WaitForAll {
+		return fmt.Errorf("invalid --for value %q (expected %s, %s or %s)", waitCond, cluster.WaitForAPI, cluster.WaitForNodes, cluster.WaitForAll)
+	}
+	timeout, err := time.ParseDuration(waitTimeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid --timeout value %q", waitTimeout)
+	}
+	deadline := time.Now().Add(timeout)
+	// remaining is the share of --timeout left for the next check.
+	remaining := func() string {
+		return time.Until(deadline).Round(time.Second).String()
+	}
+
+	ctx := context.Background()
+	r, err := detectRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	containerName, err := cluster.PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return err
+	}
+	running, err := r.ContainerIsRunning(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("cluster '%s' not found: %w", clusterName, err)
+	}
+	if !running {
+		return fmt.Errorf("cluster '%s' is not running", clusterName)
+	}
+
+	if err := utils.WaitForK0sReady(ctx, r, os.Stdout, containerName, remaining()); err != nil {
+		return err
+	}
+	// `create --wait=false` may not have managed to write the kubeconfig yet.
+	if !hasKubeconfigContext(clusterName) {
+		if err := utils.AddClusterToKubeconfig(ctx, r, clusterName, containerName); err != nil {
+			return fmt.Errorf("failed to add cluster to kubeconfig: %w", err)
+		}
+	}
+	if waitCond == cluster.WaitForNodes || waitCond == cluster.WaitForAll {
+		if err := utils.WaitForNodesReady(ctx, r, os.Stdout, containerName, remaining()); err != nil {
+			return err
+		}
+	}
+	if waitCond == cluster.WaitForAll {
+		if err := utils.WaitForSystemPodsReady(ctx, r, os.Stdout, containerName, remaining()); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("✅ Cluster '%s' is ready\n", clusterName)
+	return nil
+}
+
+// hasKubeconfigContext reports whether the default kubeconfig has a context for the cluster.
+func hasKubeconfigContext(clusterName string) bool {
+	kc, err := utils.LoadKubeconfig(paths.Kubeconfig())
+	if err != nil {
+		return false
+	}
+	for _, c := range kc.Contexts {
+		if c.Name == "k0da-"+clusterName {
+			return true
+		}
+	}
+	return false
+}
@@ -467,3 +467,32 @@ func TestE2E_LocalPathProvisioner_DefaultConfig(t *testing.T) {
 	require.Equalf(t, 0, code, "kubectl get deployment status failed (%d):\n%s", code, out)
 	require.Equal(t, "True", strings.TrimSpace(out), "local-path-provisioner deployment should be available")
 }
+
+func TestE2E_MetricsServer_TopNodes(t *testing.T) {
+	k0daBin := getBinaryPath(t)
+	name := "k0da-e2e-metrics-" + strings.ReplaceAll(time.Now().Format("150405.000"), ".", "")
+
+	// Ensure cleanup
+	t.Cleanup(func() {
+		_, _ = runCmd(t, k0daBin, "delete", "--name", name)
+	})
+
+	out, code := runCmd(t, k0daBin, "create", "--name", name, "--timeout", "180s", "--with-metrics-server")
+	require.Equalf(t, 0, code, "create failed (%d):\n%s", code, out)
+
+	kubeconfig := getKubeconfigPath(t)
+	ctx := "k0da-" + name
+
+	// metrics-server needs a scrape or two before the metrics API has data
+	t.Log("Waiting for node metrics...")
+	deadline := time.Now().Add(4 * time.Minute)
+	for time.Now().Before(deadline) {
+		out, code = runKubectl(t, kubeconfig, ctx, "top", "nodes", "--no-headers")
+		if code == 0 && strings.Contains(out, name) {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+	require.Equalf(t, 0, code, "kubectl top nodes failed (%d):\n%s", code, out)
+	require.Containsf(t, out, name, "no metrics for node %q:\n%s", name, out)
+}
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// Apply copies manifest files into the cluster's primary node and applies them with
+// `k0s kubectl apply`, in the given order. Unlike the manifests in the cluster config
+// they are not staged, so k0s doesn't reapply them. It returns the kubectl output.
+func Apply(ctx context.Context, r runtime.Runtime, clusterName string, files []string) (string, error) {
+	if len(files) == 0 {
+		return "", fmt.Errorf("no manifests to apply")
+	}
+	primary, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return "", err
+	}
+	// Every file keeps its name in a directory of its own and is passed with its own -f,
+	// so kubectl applies them in order whatever their extension; out of a directory it
+	// would only pick .yaml, .yml and .json files.
+	dir := fmt.Sprintf("/tmp/k0da-apply-%d", time.Now().UnixNano())
+	dsts := make([]string, len(files))
+	mkdir := []string{"mkdir", "-p"}
+	for i, f := range files {
+		dsts[i] = fmt.Sprintf("%s/%d/%s", dir, i, filepath.Base(f))
+		mkdir = append(mkdir, path.Dir(dsts[i]))
+	}
+	if out, exit, err := r.ExecInContainer(ctx, primary, mkdir); err != nil || exit != 0 {
+		return "", execError(fmt.Sprintf("failed to prepare %s on %s", dir, primary), out, exit, err)
+	}
+	defer func() { _, _, _ = r.ExecInContainer(context.WithoutCancel(ctx), primary, []string{"rm", "-rf", dir}) }()
+
+	cmd := []string{"k0s", "kubectl", "apply"}
+	for i, f := range files {
+		if err := r.CopyToContainer(ctx, primary, f, dsts[i]); err != nil {
+			return "", fmt.Errorf("failed to copy %s: %w", f, err)
+		}
+		cmd = append(cmd, "-f", dsts[i])
+	}
+	out, exit, err := r.ExecInContainer(ctx, primary, cmd)
+	if err != nil || exit != 0 {
+		return out, execError("kubectl apply failed", out, exit, err)
+	}
+	return out, nil
+}
+
+// execError describes a failed exec: its error when it could not be run, else its exit
+// code and output.
+func execError(msg, out string, exit int, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	return fmt.Errorf("%s (exit code %d): %s", msg, exit, strings.TrimSpace(out))
+}
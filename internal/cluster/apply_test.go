@@ -0,0 +1,31 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+func TestApply(t *testing.T) {
+	r := &testRuntime{nodes: []runtime.ContainerInfo{{Name: "dev"}}}
+	_, err := Apply(context.Background(), r, "dev", []string{"manifests/app.yaml", "other/app.yaml", "crds.txt"})
+	require.NoError(t, err)
+	require.Len(t, r.execs, 3)
+	dir := strings.TrimSuffix(r.execs[0][2], "/0")
+	assert.Equal(t, []string{"mkdir", "-p", dir + "/0", dir + "/1", dir + "/2"}, r.execs[0])
+	// Files keep their names, so kubectl takes any extension, and are applied in order.
+	assert.Equal(t, []string{dir + "/0/app.yaml", dir + "/1/app.yaml", dir + "/2/crds.txt"}, r.copied)
+	assert.Equal(t, []string{"k0s", "kubectl", "apply", "-f", dir + "/0/app.yaml", "-f", dir + "/1/app.yaml", "-f", dir + "/2/crds.txt"}, r.execs[1])
+	assert.Equal(t, []string{"rm", "-rf", dir}, r.execs[2])
+
+	r = &testRuntime{nodes: []runtime.ContainerInfo{{Name: "dev"}}, out: "error: no objects passed to apply\n", exit: 1}
+	_, err = Apply(context.Background(), r, "dev", []string{"app.yaml"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "<nil>")
+	assert.Contains(t, err.Error(), "(exit code 1): error: no objects passed to apply")
+}
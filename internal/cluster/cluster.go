@@ -0,0 +1,92 @@
+// Package cluster implements the k0da cluster operations independently of the CLI.
+// The cobra commands in cmd and the `k0da serve` API are thin wrappers around it.
+// Embedders outside this module use them through the Manager of pkg/k0da, whose example
+// drives them against a fake runtime.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// progress returns the writer for progress messages, io.Discard when w is nil.
+func progress(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+const (
+	// WaitForAPI waits only until the Kubernetes API responds.
+	WaitForAPI = "api"
+	// WaitForAll additionally waits for all kube-system pods (DNS, CNI) to be Ready.
+	WaitForAll = "all"
+	// WaitForNodes additionally waits for all nodes to be Ready; only used by `k0da wait`.
+	WaitForNodes = "nodes"
+)
+
+// PrimaryContainer returns the container name of the cluster's primary node. Clusters created
+// before the primary was labelled use the cluster name.
+func PrimaryContainer(ctx context.Context, r runtime.Runtime, clusterName string) (string, error) {
+	list, err := r.ListContainersByLabel(ctx, map[string]string{
+		k0daconfig.LabelClusterName: clusterName,
+		k0daconfig.LabelNodePrimary: "true",
+	}, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to find primary node: %w", err)
+	}
+	if len(list) > 0 {
+		return list[0].Name, nil
+	}
+	return clusterName, nil
+}
+
+// defaultNodeVolume is the name of the volume created for a node's /var.
+func defaultNodeVolume(containerName string) string {
+	return fmt.Sprintf("%s-var", containerName)
+}
+
+// NodeVolume returns the /var volume of a node container, which keeps its original
+// name when the node is renamed.
+func NodeVolume(c runtime.ContainerInfo) string {
+	if v := c.Labels[k0daconfig.LabelNodeVolume]; v != "" {
+		return v
+	}
+	return defaultNodeVolume(c.Name)
+}
+
+// Network returns the container network of a cluster from its stored config, or the
+// default network when the config is missing.
+func Network(clusterName string) string {
+	cc, err := k0daconfig.LoadClusterConfig(paths.StoredConfigPath(clusterName))
+	if err != nil || cc.Spec.Options.Network == "" {
+		return k0daconfig.DefaultNetwork
+	}
+	return cc.Spec.Options.Network
+}
+
+// APIServerAddress returns the options.apiServerAddress of a cluster from its stored
+// config, which create sets to the host of a remote runtime; empty when the config is
+// missing or doesn't set it.
+func APIServerAddress(clusterName string) string {
+	cc, err := k0daconfig.LoadClusterConfig(paths.StoredConfigPath(clusterName))
+	if err != nil {
+		return ""
+	}
+	return cc.Spec.Options.APIServerAddress
+}
+
+// NodeIP returns the IP address of a node container on its cluster's network.
+func NodeIP(ctx context.Context, r runtime.Runtime, clusterName, container string) (string, error) {
+	ip, err := r.ContainerIP(ctx, container, Network(clusterName))
+	if err != nil {
+		return "", fmt.Errorf("failed to get IP of node %s: %w", container, err)
+	}
+	return ip, nil
+}
@@ -0,0 +1,1100 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/utils"
+)
+
+// CreateOptions are the inputs of Create, taken from the create flags or a
+// `k0da serve` request.
+type CreateOptions struct {
+	Name   string
+	Config *k0daconfig.ClusterConfig
+	// Image is the k0s image of additional nodes that don't set one.
+	Image string
+	// Labels are key=value cluster labels on top of those in Config.
+	Labels []string
+	// Env is applied to all nodes; node env in Config wins.
+	Env map[string]string
+	// Networks are connected to all nodes in addition to the cluster network.
+	Networks []string
+	TTL      time.Duration
+	Wait     bool
+	WaitFor  string
+	Timeout  string
+	// Out receives progress messages, such as the nodes being created; nil discards them.
+	Out io.Writer
+}
+
+// Result describes a created cluster, as printed by `k0da create -o json`.
+type Result struct {
+	Name string `json:"name"`
+	// Context is the kubeconfig context; empty when nodes joined an external controller.
+	Context string `json:"context,omitempty"`
+	// Server is the host URL of the Kubernetes API.
+	Server string `json:"server,omitempty"`
+	// APIPort is the host port the API is published on, 0 when it is not published.
+	APIPort int      `json:"api_port,omitempty"`
+	Nodes   []string `json:"nodes"`
+	// Ingress is the host URL of the built-in ingress controller over http, empty when
+	// options.ingress doesn't install one.
+	Ingress string `json:"ingress,omitempty"`
+}
+
+// Create creates a cluster from a loaded config, reporting progress on opts.Out.
+func Create(ctx context.Context, r runtime.Runtime, opts CreateOptions) (*Result, error) {
+	clusterName, cc, out := opts.Name, opts.Config, progress(opts.Out)
+	if err := applyClusterLabels(cc, opts.Labels); err != nil {
+		return nil, err
+	}
+
+	if err := checkNodeDevices(cc); err != nil {
+		return nil, err
+	}
+	// Sharing the host cgroup namespace is the escape hatch for cgroup v1 hosts.
+	if cc.Spec.Options.CgroupNS != "host" {
+		if err := utils.CheckCgroupV2(); err != nil {
+			return nil, err
+		}
+	}
+	extras := nodeExtras{Env: opts.Env, Labels: map[string]string{}, Networks: opts.Networks}
+	if opts.TTL < 0 {
+		return nil, fmt.Errorf("ttl must not be negative")
+	}
+	if opts.TTL > 0 {
+		extras.Labels[k0daconfig.LabelClusterExpires] = time.Now().Add(opts.TTL).UTC().Format(time.RFC3339)
+	}
+
+	// Determine final image with precedence: config > user-flag override > fetched stable > default
+	var finalImage string
+	if cc.Spec.K0s.Image != "" || cc.Spec.K0s.Version != "" {
+		finalImage = cc.Spec.K0s.EffectiveImage()
+	} else {
+		client := &http.Client{Timeout: 3 * time.Second}
+		if stable, err := k0daconfig.FetchStableK0sVersion(client); err == nil && strings.TrimSpace(stable) != "" {
+			finalImage = k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.NormalizeVersionTag(stable)
+		} else {
+			finalImage = k0daconfig.DefaultK0sImageRepo + ":" + k0daconfig.DefaultK0sVersion
+		}
+	}
+
+	fmt.Fprintf(out, "Creating k0s cluster '%s'...\n", clusterName)
+	for _, w := range cc.Warnings() {
+		fmt.Fprintf(out, "Warning: %s\n", w)
+	}
+	if cc.Spec.Options.APIServerAddress == "" {
+		if host := runtime.RemoteHost(r); host != "" {
+			// The API is published on the runtime's machine, not on this one.
+			fmt.Fprintf(out, "Using %s as the API server address of the remote runtime; set --api-server-address to override\n", host)
+			cc.Spec.Options.APIServerAddress = host
+		}
+	}
+
+	// Create cluster directory
+	clusterDir := cc.ClusterDir(clusterName)
+	if err := os.MkdirAll(clusterDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cluster directory: %w", err)
+	}
+
+	// Bundles are checked and downloaded first, so that a bad one fails before the
+	// cluster's config is stored.
+	bundles, err := utils.StageImageBundles(cc, paths.ImageBundlesDir(clusterName))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cc.WriteEffectiveK0sConfig(clusterName); err != nil {
+		return nil, fmt.Errorf("failed to write effective k0s config: %w", err)
+	}
+	if err := cc.WriteWorkerK0sConfig(clusterName); err != nil {
+		return nil, fmt.Errorf("failed to write worker k0s config: %w", err)
+	}
+	if err := cc.WriteRegistryAuthConfig(clusterName); err != nil {
+		return nil, fmt.Errorf("failed to write registry auth config: %w", err)
+	}
+	if err := cc.WriteStoredConfig(clusterName); err != nil {
+		return nil, fmt.Errorf("failed to store cluster config: %w", err)
+	}
+	extras.Mounts = imageBundleMounts(bundles)
+	if len(cc.Spec.K0s.RegistryAuth) > 0 {
+		extras.Mounts = append(extras.Mounts, runtime.Mount{Type: "bind", Source: cc.RegistryAuthPath(clusterName), Target: "/etc/k0s/containerd.d/k0da-registry-auth.toml", Options: []string{"ro"}})
+	}
+
+	// Pull each image once up front instead of once per node
+	extras.PullPolicy = prePullImages(ctx, r, out, clusterImages(cc, finalImage, opts.Image), cc.Spec.Options.PullPolicy)
+
+	result := &Result{Name: clusterName, Nodes: nodeContainerNames(cc, clusterName)}
+	if len(result.Nodes) == 0 {
+		result.Nodes = []string{cc.PrimaryNodeName(clusterName)}
+	}
+
+	if cc.ExternalControlPlane() {
+		fmt.Fprintln(out, "Joining worker nodes to the external controller...")
+		if err := joinAdditionalNodes(ctx, r, out, clusterName, finalImage, opts.Wait, opts.Timeout, cc, extras); err != nil {
+			return nil, fmt.Errorf("failed to join worker nodes: %w", err)
+		}
+		fmt.Fprintf(out, "✅ Worker nodes of cluster '%s' joined the external controller!\n", clusterName)
+		result.Server = cc.Spec.K0s.Server
+		return result, nil
+	}
+
+	// Create the primary node/container using backend
+	api, err := createK0sCluster(ctx, r, out, clusterName, finalImage, opts.Wait, opts.Timeout, cc, extras)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k0s cluster: %w", err)
+	}
+	result.Server = apiServerURL(api, cc.Spec.Options.APIServerAddress)
+	result.APIPort = api.HostPort
+	result.Context = fmt.Sprintf("k0da-%s", clusterName)
+
+	// If multinode defined, join additional nodes to the primary
+	if len(cc.Spec.Nodes) > 1 {
+		if err := joinAdditionalNodes(ctx, r, out, clusterName, opts.Image, opts.Wait, opts.Timeout, cc, extras); err != nil {
+			return nil, fmt.Errorf("failed to join additional nodes: %w", err)
+		}
+	}
+
+	if opts.Wait && opts.WaitFor == WaitForAll {
+		if err := utils.WaitForSystemPodsReady(ctx, r, out, cc.PrimaryNodeName(clusterName), opts.Timeout); err != nil {
+			return nil, fmt.Errorf("cluster failed to become ready: %w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "✅ Cluster '%s' created successfully!\n", clusterName)
+	fmt.Fprintf(out, "To use this cluster, run: kubectl config use-context k0da-%s\n", clusterName)
+
+	if cc.Spec.Options.ExposeDNS {
+		if hostIP, port, err := r.GetPortMapping(ctx, cc.PrimaryNodeName(clusterName), utils.DNSNodePort, "udp"); err == nil && port != 0 {
+			fmt.Fprintln(out, utils.DNSHostInstructions(hostIP, port))
+		} else {
+			fmt.Fprintf(out, "Warning: cluster DNS was requested but its port mapping could not be determined: %v\n", err)
+		}
+	}
+	if cc.Spec.Options.IngressEnabled() {
+		primary := cc.PrimaryNodeName(clusterName)
+		httpIP, httpPort, err := r.GetPortMapping(ctx, primary, k0daconfig.IngressHTTPNodePort, "tcp")
+		if err == nil && httpPort != 0 {
+			result.Ingress = hostURL("http", httpIP, httpPort, cc.Spec.Options.APIServerAddress)
+			fmt.Fprintf(out, "Ingress (%s): %s\n", cc.Spec.Options.Ingress, result.Ingress)
+			if httpsIP, httpsPort, err := r.GetPortMapping(ctx, primary, k0daconfig.IngressHTTPSNodePort, "tcp"); err == nil && httpsPort != 0 {
+				fmt.Fprintf(out, "Ingress (%s): %s\n", cc.Spec.Options.Ingress, hostURL("https", httpsIP, httpsPort, cc.Spec.Options.APIServerAddress))
+			}
+		} else {
+			fmt.Fprintf(out, "Warning: ingress was requested but its port mapping could not be determined: %v\n", err)
+		}
+	}
+
+	return result, nil
+}
+
+// createK0sCluster starts the primary controller and returns the host binding of its API port.
+func createK0sCluster(ctx context.Context, b runtime.Runtime, out io.Writer, name, image string, wait bool, timeout string, cc *k0daconfig.ClusterConfig, extras nodeExtras) (runtime.PortSpec, error) {
+	var none runtime.PortSpec
+	containerName := cc.PrimaryNodeName(name)
+	hostname := containerName
+
+	fmt.Fprintf(out, "Creating container '%s' with image '%s' using %s...\n", containerName, image, b.Name())
+
+	// Ensure manifests directory exists on host for k0s manifests and copy manifests into it
+	hostK0daManifestsPath := cc.ManifestDir(name)
+	if err := utils.CopyManifestsToDir(cc, hostK0daManifestsPath); err != nil {
+		return none, fmt.Errorf("failed to stage manifests: %w", err)
+	}
+
+	// Build mounts
+	mounts := runtime.Mounts{
+		runtime.Mount{Type: "volume", Source: defaultNodeVolume(containerName), Target: "/var"},
+	}
+	if hostMounts := hostKernelMounts(cc.Spec.Options); len(hostMounts) > 0 {
+		mounts = append(mounts, hostMounts...)
+	} else if cc.Spec.Options.MountKernelModules == nil {
+		fmt.Fprintf(out, "Skipping %s mount: not available on this %s host\n", kernelModulesPath, goruntime.GOOS)
+	}
+	// Mount manifests directory into k0s manifests path
+	mounts = append(mounts, runtime.Mount{Type: "bind", Source: hostK0daManifestsPath, Target: "/var/lib/k0s/manifests/k0da"})
+	mounts = append(mounts, runtime.Mount{Type: "bind", Source: cc.ConfigPath(name), Target: "/etc/k0s/k0s.yaml", Options: []string{"ro"}})
+	mounts = append(mounts, extras.Mounts...)
+	if cc.Spec.Options.MountRuntimeSocket {
+		socket, err := runtime.SocketMount(ctx, b)
+		if err != nil {
+			return none, err
+		}
+		fmt.Fprintf(out, "Mounting the runtime socket %s at %s\n", socket.Source, socket.Target)
+		mounts = append(mounts, socket)
+	}
+
+	// Node overrides/extensions
+	node := cc.PickPrimaryNode()
+	if node != nil {
+		for _, m := range node.Mounts {
+			mounts = append(mounts, runtime.Mount{Type: m.Type, Source: m.Source, Target: m.Target, Options: m.Options})
+		}
+	}
+
+	// Build command args
+	cmdArgs := buildK0sControllerArgs(cc, node, true)
+
+	// Ports, Env, Labels
+	publish := buildPublishPortsFromNode(node)
+	publish = ensureAPIExposed(publish)
+	if cc.Spec.Options.ExposeDNS {
+		publish = ensureDNSExposed(publish)
+	}
+	if cc.Spec.Options.IngressEnabled() {
+		publish = ensureIngressExposed(publish)
+	}
+	env := buildEnvFromNode(node, extras.Env)
+	labels := NodeLabels(name, containerName, "controller", cc.Spec.Labels, node)
+	for k, v := range extras.Labels {
+		labels[k] = v
+	}
+	labels[k0daconfig.LabelNodePrimary] = "true"
+
+	// Effective image with node override
+	effectiveImage := image
+	if node != nil && strings.TrimSpace(node.Image) != "" {
+		effectiveImage = node.Image
+	}
+
+	// Ensure network exists and attach container to it (kind-like shared network)
+	networkName := cc.Spec.Options.Network
+	if err := ensureClusterNetwork(ctx, b, cc); err != nil {
+		return none, err
+	}
+
+	api, err := runWithAPIPort(ctx, b, out, runtime.RunContainerOptions{
+		Name:           containerName,
+		Hostname:       nodeHostname(node, hostname),
+		NetworkAliases: nodeNetworkAliases(containerName, nodeHostname(node, hostname), node),
+		IP:             nodeIP(node),
+		DNS:            nodeDNS(node),
+		DNSSearch:      nodeDNSSearch(node),
+		ExtraHosts:     nodeExtraHosts(node),
+		Image:          effectiveImage,
+		Args:           cmdArgs,
+		Env:            env,
+		Labels:         labels,
+		Mounts:         mounts,
+		Privileged:     cc.Spec.Options.IsPrivileged(),
+		SecurityOpt:    cc.Spec.Options.SecurityOpt,
+		CapAdd:         cc.Spec.Options.CapAdd,
+		CapDrop:        cc.Spec.Options.CapDrop,
+		Publish:        publish,
+		Network:        networkName,
+		Ulimits:        buildUlimits(cc),
+		RestartPolicy:  cc.Spec.Options.RestartPolicy,
+		PullPolicy:     extras.PullPolicy,
+		CgroupNS:       cc.Spec.Options.CgroupNS,
+		Devices:        nodeDevices(node),
+		OCIRuntime:     nodeRuntime(node),
+		Healthcheck:    nodeHealthcheck(cc),
+	})
+	if err != nil {
+		return none, fmt.Errorf("failed to create container: %w", err)
+	}
+	if err := connectNodeNetworks(ctx, b, out, containerName, node, extras, networkName); err != nil {
+		return none, err
+	}
+
+	fmt.Fprintf(out, "✅ Container created successfully\n")
+
+	if wait {
+		fmt.Fprintln(out, "Waiting for cluster to be ready...")
+		if err := utils.WaitForK0sReady(ctx, b, out, containerName, timeout); err != nil {
+			if p, ok := b.(*runtime.Podman); ok && p.Rootless() {
+				return none, fmt.Errorf("cluster failed to become ready under rootless podman (try 'podman machine set --rootful'): %w", err)
+			}
+			return none, fmt.Errorf("cluster failed to become ready: %w", err)
+		}
+		fmt.Fprintln(out, "✅ Cluster is ready!")
+
+		// Add cluster to unified kubeconfig
+		if err := addClusterToKubeconfig(ctx, b, name, containerName, apiServerURL(api, cc.Spec.Options.APIServerAddress)); err != nil {
+			return none, fmt.Errorf("failed to add cluster to kubeconfig: %w", err)
+		}
+	} else {
+		// k0s writes the admin kubeconfig early during startup, long before the API
+		// is ready, so the cluster can be recorded without waiting for it.
+		if err := addClusterToKubeconfigEventually(ctx, b, name, containerName, apiServerURL(api, cc.Spec.Options.APIServerAddress)); err != nil {
+			fmt.Fprintf(out, "Warning: kubeconfig not written yet: %v\n", err)
+			fmt.Fprintf(out, "Run 'k0da wait %s' to write it once the cluster is up\n", name)
+		}
+	}
+
+	return api, nil
+}
+
+// addClusterToKubeconfig adds the cluster with the given API server URL, as returned
+// by runWithAPIPort, so the port mapping doesn't need to be looked up again. Without
+// one it falls back to asking the runtime.
+func addClusterToKubeconfig(ctx context.Context, b runtime.Runtime, clusterName, containerName, server string) error {
+	if server == "" {
+		return utils.AddClusterToKubeconfig(ctx, b, clusterName, containerName)
+	}
+	return utils.AddClusterToKubeconfigWithServer(ctx, b, clusterName, containerName, server)
+}
+
+// addClusterToKubeconfigEventually retries addClusterToKubeconfig for a freshly
+// started controller whose admin kubeconfig may take a while to appear.
+func addClusterToKubeconfigEventually(ctx context.Context, b runtime.Runtime, clusterName, containerName, server string) error {
+	var lastErr error
+	for i := 0; i < 3; i++ { // each attempt retries for ~10s itself
+		if lastErr = addClusterToKubeconfig(ctx, b, clusterName, containerName, server); lastErr == nil {
+			return nil
+		}
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return lastErr
+}
+
+// clusterImages returns the distinct images of all nodes. Nodes without their own image
+// use primaryImage if they are the primary or join an external control plane, otherwise
+// nodeImage.
+func clusterImages(cc *k0daconfig.ClusterConfig, primaryImage, nodeImage string) []string {
+	if len(cc.Spec.Nodes) == 0 {
+		return []string{primaryImage}
+	}
+	primary := cc.PickPrimaryNode()
+	seen := map[string]bool{}
+	var images []string
+	for i := range cc.Spec.Nodes {
+		n := &cc.Spec.Nodes[i]
+		img := strings.TrimSpace(n.Image)
+		if img == "" {
+			img = nodeImage
+			if n == primary || cc.ExternalControlPlane() {
+				img = primaryImage
+			}
+		}
+		if img != "" && !seen[img] {
+			seen[img] = true
+			images = append(images, img)
+		}
+	}
+	return images
+}
+
+// prePullImages pulls the node images as the pull policy asks, before any node is created,
+// and returns the policy to run nodes with. Images pulled here are not pulled again per
+// node; those that failed to pull are left to the nodes.
+func prePullImages(ctx context.Context, r runtime.Runtime, out io.Writer, images []string, policy string) string {
+	if policy == runtime.PullNever {
+		return policy
+	}
+	nodePolicy := runtime.PullMissing
+	for _, img := range images {
+		if policy != runtime.PullAlways {
+			if exists, err := r.ImageExists(ctx, img); err == nil && exists {
+				continue
+			}
+		}
+		fmt.Fprintf(out, "Pulling image '%s'...\n", img)
+		if err := r.PullImage(ctx, img); err != nil {
+			fmt.Fprintf(out, "Warning: failed to pull image '%s', nodes will try again: %v\n", img, err)
+			nodePolicy = policy
+		}
+	}
+	return nodePolicy
+}
+
+// joinAdditionalNodes creates tokens on the primary node and starts additional nodes defined in the config.
+// With an external control plane all nodes are started and joined with spec.k0s.joinToken instead.
+func joinAdditionalNodes(ctx context.Context, b runtime.Runtime, out io.Writer, clusterName, image string, wait bool, timeout string, cc *k0daconfig.ClusterConfig, extras nodeExtras) error {
+	primary := cc.PrimaryNodeName(clusterName)
+	tokensDir := paths.TokensDir(clusterName)
+	if err := os.MkdirAll(tokensDir, 0755); err != nil {
+		return fmt.Errorf("create tokens dir: %w", err)
+	}
+
+	networkName := k0daconfig.DefaultNetwork
+	if cc != nil {
+		networkName = cc.Spec.Options.Network
+	}
+	if err := ensureClusterNetwork(ctx, b, cc); err != nil {
+		return err
+	}
+
+	external := cc.ExternalControlPlane()
+	var externalToken string
+	if external {
+		var err error
+		if externalToken, err = externalJoinToken(cc); err != nil {
+			return err
+		}
+	}
+
+	primaryNode := cc.PickPrimaryNode()
+	names := nodeContainerNames(cc, clusterName)
+	for i := range cc.Spec.Nodes {
+		n := &cc.Spec.Nodes[i]
+		if !external && primaryNode != nil && &cc.Spec.Nodes[i] == primaryNode {
+			continue
+		}
+		role := nodeRole(n)
+		token := externalToken
+		if !external {
+			var err error
+			if token, err = createJoinToken(ctx, b, primary, role, 0); err != nil {
+				return err
+			}
+		}
+		nodeName := names[i]
+		hostTokenPath := filepath.Join(tokensDir, nodeName+".token")
+		if err := os.WriteFile(hostTokenPath, []byte(token+"\n"), 0600); err != nil {
+			return fmt.Errorf("write token file: %v", err)
+		}
+
+		var cmdArgs []string
+		switch role {
+		case "controller":
+			cmdArgs = buildK0sControllerArgs(cc, n, false)
+		default:
+			cmdArgs = buildK0sWorkerArgs(cc, n)
+		}
+
+		mounts := runtime.Mounts{
+			runtime.Mount{Type: "volume", Source: defaultNodeVolume(nodeName), Target: "/var"},
+			runtime.Mount{Type: "bind", Source: hostTokenPath, Target: "/etc/k0s/join.token", Options: []string{"ro"}},
+		}
+		if role == "worker" && len(cc.Spec.K0s.WorkerConfig) > 0 {
+			mounts = append(mounts, runtime.Mount{Type: "bind", Source: cc.WorkerConfigPath(clusterName), Target: "/etc/k0s/k0s.yaml", Options: []string{"ro"}})
+		}
+		mounts = append(mounts, hostKernelMounts(cc.Spec.Options)...)
+		mounts = append(mounts, extras.Mounts...)
+
+		publish := buildPublishPortsFromNode(n)
+		// Env, Labels
+		env := buildEnvFromNode(n, extras.Env)
+		labels := NodeLabels(clusterName, nodeName, role, cc.Spec.Labels, n)
+		for k, v := range extras.Labels {
+			labels[k] = v
+		}
+
+		effectiveImage := image
+		if strings.TrimSpace(n.Image) != "" {
+			effectiveImage = n.Image
+		}
+
+		_, err := b.RunContainer(ctx, runtime.RunContainerOptions{
+			Name:           nodeName,
+			Hostname:       nodeHostname(n, nodeName),
+			NetworkAliases: nodeNetworkAliases(nodeName, nodeHostname(n, nodeName), n),
+			IP:             nodeIP(n),
+			DNS:            nodeDNS(n),
+			DNSSearch:      nodeDNSSearch(n),
+			ExtraHosts:     nodeExtraHosts(n),
+			Image:          effectiveImage,
+			Args:           cmdArgs,
+			Env:            env,
+			Labels:         labels,
+			Mounts:         mounts,
+			Privileged:     cc.Spec.Options.IsPrivileged(),
+			SecurityOpt:    cc.Spec.Options.SecurityOpt,
+			CapAdd:         cc.Spec.Options.CapAdd,
+			CapDrop:        cc.Spec.Options.CapDrop,
+			Publish:        publish,
+			Network:        networkName,
+			Ulimits:        buildUlimits(cc),
+			RestartPolicy:  cc.Spec.Options.RestartPolicy,
+			PullPolicy:     extras.PullPolicy,
+			CgroupNS:       cc.Spec.Options.CgroupNS,
+			Devices:        nodeDevices(n),
+			OCIRuntime:     nodeRuntime(n),
+			Healthcheck:    nodeHealthcheck(cc),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start node %s: %w", nodeName, err)
+		}
+		if err := connectNodeNetworks(ctx, b, out, nodeName, n, extras, networkName); err != nil {
+			return err
+		}
+		if wait {
+			// Only wait for controller nodes; workers don't expose the same status
+			if role == "controller" {
+				if err := utils.WaitForK0sReady(ctx, b, out, nodeName, timeout); err != nil {
+					return fmt.Errorf("node %s failed to become ready: %w", nodeName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// externalJoinToken returns spec.k0s.joinToken, pointed at spec.k0s.server when set.
+func externalJoinToken(cc *k0daconfig.ClusterConfig) (string, error) {
+	token := strings.TrimSpace(cc.Spec.K0s.JoinToken)
+	server := strings.TrimSpace(cc.Spec.K0s.Server)
+	if server == "" {
+		return token, nil
+	}
+	token, err := utils.SetJoinTokenServer(token, server)
+	if err != nil {
+		return "", fmt.Errorf("k0s.joinToken: %w", err)
+	}
+	return token, nil
+}
+
+// ensureClusterNetwork makes sure the cluster network is usable: it is created when missing
+// unless the config marks it as user-managed, in which case it must already exist. With
+// options.networkSubnet set, the network must have that subnet, which an existing one
+// keeps whatever the config says.
+func ensureClusterNetwork(ctx context.Context, b runtime.Runtime, cc *k0daconfig.ClusterConfig) error {
+	networkName := k0daconfig.DefaultNetwork
+	var opts runtime.NetworkOptions
+	if cc != nil {
+		networkName = cc.Spec.Options.Network
+		opts.Subnet = cc.Spec.Options.NetworkSubnet
+	}
+	if cc == nil || cc.Spec.Options.ShouldCreateNetwork() {
+		if err := b.EnsureNetwork(ctx, networkName, opts); err != nil {
+			return fmt.Errorf("failed to ensure network: %w", err)
+		}
+	} else {
+		exists, err := b.NetworkExists(ctx, networkName)
+		if err != nil {
+			return fmt.Errorf("failed to check network %q: %w", networkName, err)
+		}
+		if !exists {
+			return fmt.Errorf("network %q does not exist and networkCreate is disabled; create it first or remove the %s prefix", networkName, k0daconfig.ExistingNetworkPrefix)
+		}
+	}
+	if opts.Subnet == "" {
+		return nil
+	}
+	subnets, err := b.NetworkSubnets(ctx, networkName)
+	if err != nil {
+		return err
+	}
+	if !hasSubnet(subnets, opts.Subnet) {
+		return fmt.Errorf("network %q has subnet %s, not options.networkSubnet %s; use a network name of its own or drop networkSubnet", networkName, strings.Join(subnets, ", "), opts.Subnet)
+	}
+	return nil
+}
+
+// hasSubnet reports whether subnets contains the network of cidr, comparing them
+// normalized, e.g. 172.30.0.1/24 is 172.30.0.0/24.
+func hasSubnet(subnets []string, cidr string) bool {
+	_, want, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	for _, s := range subnets {
+		if _, n, err := net.ParseCIDR(s); err == nil && n.String() == want.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// buildK0sControllerArgs builds k0s controller command arguments
+func buildK0sControllerArgs(cc *k0daconfig.ClusterConfig, node *k0daconfig.NodeSpec, isPrimary bool) []string {
+	cmdArgs := []string{"k0s", "controller", "--enable-dynamic-config"}
+	if !cc.Spec.Options.MetricsServer {
+		cmdArgs = append(cmdArgs, "--disable-components=metrics-server")
+	}
+	cmdArgs = append(cmdArgs, "--ignore-pre-flight-checks")
+
+	// Add role-specific arguments
+	if len(cc.Spec.Nodes) == 1 {
+		cmdArgs = append(cmdArgs, "--single")
+	} else {
+		cmdArgs = append(cmdArgs, "--enable-worker", "--no-taints")
+	}
+
+	if !isPrimary {
+		cmdArgs = append(cmdArgs, "--token-file", "/etc/k0s/join.token")
+	}
+	cmdArgs = append(cmdArgs, "--config", "/etc/k0s/k0s.yaml")
+
+	// Add global k0s args
+	if len(cc.Spec.K0s.Args) > 0 {
+		cmdArgs = append(cmdArgs, cc.Spec.K0s.Args...)
+	}
+
+	// Add node-specific args
+	if node != nil && len(node.Args) > 0 {
+		cmdArgs = append(cmdArgs, node.Args...)
+	}
+
+	return cmdArgs
+}
+
+// buildK0sWorkerArgs builds the k0s command of a worker node. spec.k0s.args only apply
+// to controllers; the node's own args come last.
+func buildK0sWorkerArgs(cc *k0daconfig.ClusterConfig, node *k0daconfig.NodeSpec) []string {
+	cmdArgs := []string{"k0s", "worker", "--token-file", "/etc/k0s/join.token"}
+	if len(cc.Spec.K0s.WorkerConfig) > 0 {
+		cmdArgs = append(cmdArgs, "--config", "/etc/k0s/k0s.yaml")
+	}
+	if node != nil && len(node.Args) > 0 {
+		cmdArgs = append(cmdArgs, node.Args...)
+	}
+	return cmdArgs
+}
+
+// Helpers
+func buildPublishPortsFromNode(node *k0daconfig.NodeSpec) []runtime.PortSpec {
+	publish := []runtime.PortSpec{}
+	if node != nil && len(node.Ports) > 0 {
+		for _, p := range node.Ports {
+			proto := strings.ToLower(p.Protocol)
+			if proto == "" {
+				proto = "tcp"
+			}
+			publish = append(publish, runtime.PortSpec{ContainerPort: p.ContainerPort, Protocol: proto, HostIP: p.HostIP, HostPort: p.HostPort})
+		}
+	}
+	return publish
+}
+
+func ensureAPIExposed(publish []runtime.PortSpec) []runtime.PortSpec {
+	hasAPI := false
+	for _, ps := range publish {
+		if ps.ContainerPort == 6443 && (ps.Protocol == "" || strings.ToLower(ps.Protocol) == "tcp") {
+			hasAPI = true
+			break
+		}
+	}
+	if !hasAPI {
+		publish = append(publish, runtime.PortSpec{ContainerPort: 6443, Protocol: "tcp"})
+	}
+	return publish
+}
+
+// apiPortAttempts bounds how often runWithAPIPort picks other host ports.
+const apiPortAttempts = 5
+
+// runWithAPIPort runs a controller container, publishing the API, and any other port
+// without a host port such as the DNS and ingress ones, on freshly allocated host
+// ports, and returns the API port binding. The ports are only probed free, so a
+// concurrent create can take one before the runtime binds it; the half-created
+// container is then removed and other ports are tried.
+func runWithAPIPort(ctx context.Context, b runtime.Runtime, out io.Writer, opts runtime.RunContainerOptions) (runtime.PortSpec, error) {
+	api := -1
+	for i, ps := range opts.Publish {
+		if ps.ContainerPort == 6443 && (ps.Protocol == "" || strings.ToLower(ps.Protocol) == "tcp") {
+			api = i
+			break
+		}
+	}
+	binding := func() runtime.PortSpec {
+		if api < 0 {
+			return runtime.PortSpec{}
+		}
+		return opts.Publish[api]
+	}
+
+	publish := opts.Publish
+	for attempt := 1; ; attempt++ {
+		opts.Publish = append([]runtime.PortSpec(nil), publish...)
+		ports, err := allocateHostPorts(opts.Publish)
+		if err != nil {
+			return binding(), err
+		}
+		for _, port := range ports {
+			defer utils.ReleaseHostPort(port)
+		}
+		_, err = b.RunContainer(ctx, opts)
+		if err == nil || len(ports) == 0 || !runtime.IsPortInUse(err) || attempt == apiPortAttempts {
+			return binding(), err
+		}
+		fmt.Fprintf(out, "Host ports %v were taken before the node could bind them, retrying with other ports...\n", ports)
+		if rmErr := b.RemoveContainer(ctx, opts.Name); rmErr != nil {
+			return binding(), err
+		}
+	}
+}
+
+// allocateHostPorts sets a free host port on every port of publish that has none and
+// returns the allocated ports. The protocols of one container port share the host
+// port, as the DNS port is published over both udp and tcp.
+func allocateHostPorts(publish []runtime.PortSpec) ([]int, error) {
+	var ports []int
+	shared := map[string]int{}
+	for i, ps := range publish {
+		if ps.HostPort != 0 {
+			continue
+		}
+		key := net.JoinHostPort(ps.HostIP, strconv.Itoa(ps.ContainerPort))
+		port, ok := shared[key]
+		if !ok {
+			var err error
+			if port, err = utils.AllocateHostPort(ps.HostIP); err != nil {
+				for _, p := range ports {
+					utils.ReleaseHostPort(p)
+				}
+				return nil, fmt.Errorf("failed to allocate a host port for container port %d: %w", ps.ContainerPort, err)
+			}
+			shared[key] = port
+			ports = append(ports, port)
+		}
+		publish[i].HostPort = port
+	}
+	return ports, nil
+}
+
+// apiServerURL is the URL of a published API port at address, or at the host IP it is
+// bound to when address is empty; wildcard host IPs are reached via localhost. It is
+// empty when the API is not published.
+func apiServerURL(api runtime.PortSpec, address string) string {
+	if api.HostPort == 0 {
+		return ""
+	}
+	return hostURL("https", api.HostIP, api.HostPort, address)
+}
+
+// hostURL is the URL of a port published on hostIP, reached at address when it is set.
+func hostURL(scheme, hostIP string, port int, address string) string {
+	host := address
+	if host == "" {
+		host = hostIP
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// ensureDNSExposed publishes the CoreDNS node port over both udp and tcp on the same
+// host port, which runWithAPIPort allocates.
+func ensureDNSExposed(publish []runtime.PortSpec) []runtime.PortSpec {
+	for _, ps := range publish {
+		if ps.ContainerPort == utils.DNSNodePort {
+			return publish
+		}
+	}
+	return append(publish,
+		runtime.PortSpec{ContainerPort: utils.DNSNodePort, Protocol: "udp", HostIP: "127.0.0.1"},
+		runtime.PortSpec{ContainerPort: utils.DNSNodePort, Protocol: "tcp", HostIP: "127.0.0.1"},
+	)
+}
+
+// ensureIngressExposed publishes the http and https node ports of the built-in ingress
+// controller on all host interfaces, like the API, unless they are published already.
+// runWithAPIPort allocates their host ports.
+func ensureIngressExposed(publish []runtime.PortSpec) []runtime.PortSpec {
+	for _, nodePort := range []int{k0daconfig.IngressHTTPNodePort, k0daconfig.IngressHTTPSNodePort} {
+		if slices.ContainsFunc(publish, func(ps runtime.PortSpec) bool { return ps.ContainerPort == nodePort }) {
+			continue
+		}
+		publish = append(publish, runtime.PortSpec{ContainerPort: nodePort, Protocol: "tcp"})
+	}
+	return publish
+}
+
+// nodeHealthcheck returns the container healthcheck of nodes when options.healthcheck is
+// set. k0s needs a while to start, failures during the start period don't count.
+func nodeHealthcheck(cc *k0daconfig.ClusterConfig) *runtime.Healthcheck {
+	if !cc.Spec.Options.Healthcheck {
+		return nil
+	}
+	return &runtime.Healthcheck{
+		Command:     []string{"k0s", "status"},
+		Interval:    10 * time.Second,
+		Timeout:     5 * time.Second,
+		StartPeriod: time.Minute,
+		Retries:     3,
+	}
+}
+
+// buildUlimits converts the validated options.ulimits into runtime ulimits, sorted by name.
+func buildUlimits(cc *k0daconfig.ClusterConfig) []runtime.Ulimit {
+	names := make([]string, 0, len(cc.Spec.Options.Ulimits))
+	for name := range cc.Spec.Options.Ulimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]runtime.Ulimit, 0, len(names))
+	for _, name := range names {
+		soft, hard, err := k0daconfig.ParseUlimit(cc.Spec.Options.Ulimits[name])
+		if err != nil {
+			continue // rejected by Validate
+		}
+		out = append(out, runtime.Ulimit{Name: name, Soft: soft, Hard: hard})
+	}
+	return out
+}
+
+func nodeRole(node *k0daconfig.NodeSpec) string {
+	role := strings.ToLower(strings.TrimSpace(node.Role))
+	if role == "" {
+		role = "worker"
+	}
+	return role
+}
+
+// nodeContainerNames returns the container name for every node in cc, index-aligned with
+// cc.Spec.Nodes. Configured names win; the primary defaults to the cluster name and other
+// nodes to "<cluster>-<role>-<n>", where n is the node's position among nodes of the same
+// role, so names stay stable no matter how roles are interleaved.
+func nodeContainerNames(cc *k0daconfig.ClusterConfig, clusterName string) []string {
+	primary := cc.PickPrimaryNode()
+	perRole := map[string]int{}
+	names := make([]string, len(cc.Spec.Nodes))
+	for i := range cc.Spec.Nodes {
+		n := &cc.Spec.Nodes[i]
+		role := nodeRole(n)
+		idx := perRole[role]
+		perRole[role]++
+		switch {
+		case strings.TrimSpace(n.Name) != "":
+			names[i] = strings.TrimSpace(n.Name)
+		case n == primary:
+			names[i] = clusterName
+		default:
+			names[i] = fmt.Sprintf("%s-%s-%d", clusterName, role, idx)
+		}
+	}
+	return names
+}
+
+// nodeHostname returns the configured hostname override or def.
+func nodeHostname(node *k0daconfig.NodeSpec, def string) string {
+	if node != nil && strings.TrimSpace(node.Hostname) != "" {
+		return strings.TrimSpace(node.Hostname)
+	}
+	return def
+}
+
+// nodeNetworkAliases returns the names besides the container name that a node must be
+// resolvable by from other nodes: its hostname (which k0s registers as the node name)
+// and its configured name.
+func nodeNetworkAliases(containerName, hostname string, node *k0daconfig.NodeSpec) []string {
+	candidates := []string{hostname}
+	if node != nil {
+		candidates = append(candidates, strings.TrimSpace(node.Name))
+	}
+	var aliases []string
+	seen := map[string]bool{containerName: true}
+	for _, c := range candidates {
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		aliases = append(aliases, c)
+	}
+	return aliases
+}
+
+func nodeDNS(node *k0daconfig.NodeSpec) []string {
+	if node == nil {
+		return nil
+	}
+	return node.DNS
+}
+
+func nodeDNSSearch(node *k0daconfig.NodeSpec) []string {
+	if node == nil {
+		return nil
+	}
+	return node.DNSSearch
+}
+
+func nodeExtraHosts(node *k0daconfig.NodeSpec) []string {
+	if node == nil {
+		return nil
+	}
+	return node.ExtraHosts
+}
+
+func nodeDevices(node *k0daconfig.NodeSpec) []string {
+	if node == nil {
+		return nil
+	}
+	return node.Devices
+}
+
+func nodeRuntime(node *k0daconfig.NodeSpec) string {
+	if node == nil {
+		return ""
+	}
+	return strings.TrimSpace(node.Runtime)
+}
+
+func nodeIP(node *k0daconfig.NodeSpec) string {
+	if node == nil {
+		return ""
+	}
+	return strings.TrimSpace(node.IP)
+}
+
+// checkNodeDevices makes sure every requested device exists on the host before any container is started.
+func checkNodeDevices(cc *k0daconfig.ClusterConfig) error {
+	for i, n := range cc.Spec.Nodes {
+		for _, dev := range n.Devices {
+			d := runtime.ParseDevice(dev)
+			if d.HostPath == "" {
+				return fmt.Errorf("nodes[%d]: empty device path", i)
+			}
+			if _, err := os.Stat(d.HostPath); err != nil {
+				return fmt.Errorf("nodes[%d]: device %s is not available on the host: %w", i, d.HostPath, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyClusterLabels merges --label key=value flags into the cluster labels of cc.
+func applyClusterLabels(cc *k0daconfig.ClusterConfig, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if cc.Spec.Labels == nil {
+		cc.Spec.Labels = map[string]string{}
+	}
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("invalid label %q (expected key=value)", v)
+		}
+		cc.Spec.Labels[strings.TrimSpace(key)] = value
+	}
+	if err := cc.Validate(); err != nil {
+		return fmt.Errorf("invalid cluster config: %w", err)
+	}
+	return nil
+}
+
+// nodeExtras are values from create flags that apply to every node of the cluster.
+type nodeExtras struct {
+	// Env comes from --env-file; the node's own env wins.
+	Env map[string]string
+	// Labels are k0da-managed labels such as the expiry set by --ttl.
+	Labels map[string]string
+	// Mounts are added to every node, such as the image bundles.
+	Mounts runtime.Mounts
+	// Networks come from --attach-network and are connected to every node.
+	Networks []string
+	// PullPolicy is the pull policy of the node containers, see prePullImages.
+	PullPolicy string
+}
+
+// nodeNetworks returns the additional networks of a node: those from --attach-network
+// followed by the node's own, without duplicates and without the cluster network.
+func nodeNetworks(node *k0daconfig.NodeSpec, extras nodeExtras, clusterNetwork string) []string {
+	var networks []string
+	all := extras.Networks
+	if node != nil {
+		all = append(slices.Clone(all), node.Networks...)
+	}
+	for _, n := range all {
+		if n == clusterNetwork || slices.Contains(networks, n) {
+			continue
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
+
+// connectNodeNetworks connects a started node to its additional networks, creating
+// missing ones.
+func connectNodeNetworks(ctx context.Context, b runtime.Runtime, out io.Writer, container string, node *k0daconfig.NodeSpec, extras nodeExtras, clusterNetwork string) error {
+	for _, n := range nodeNetworks(node, extras, clusterNetwork) {
+		if err := b.EnsureNetwork(ctx, n, runtime.NetworkOptions{}); err != nil {
+			return fmt.Errorf("failed to ensure network %s: %w", n, err)
+		}
+		if err := b.ConnectNetwork(ctx, container, n); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Connected %s to network %s\n", container, n)
+	}
+	return nil
+}
+
+// imageBundleMounts mounts the image bundles into the directory k0s imports images from
+// on start, keeping their order.
+func imageBundleMounts(bundles []string) runtime.Mounts {
+	var mounts runtime.Mounts
+	for i, b := range bundles {
+		target := fmt.Sprintf("/var/lib/k0s/images/%03d_%s", i, filepath.Base(b))
+		mounts = append(mounts, runtime.Mount{Type: "bind", Source: b, Target: target, Options: []string{"ro"}})
+	}
+	return mounts
+}
+
+// buildEnvFromNode merges baseEnv (from --env-file) with the node's env; the node wins.
+func buildEnvFromNode(node *k0daconfig.NodeSpec, baseEnv map[string]string) runtime.EnvVars {
+	merged := make(map[string]string, len(baseEnv))
+	for k, v := range baseEnv {
+		merged[k] = v
+	}
+	if node != nil {
+		for k, v := range node.Env {
+			merged[k] = v
+		}
+	}
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var env runtime.EnvVars
+	for _, k := range keys {
+		env = append(env, runtime.EnvVar{Name: k, Value: merged[k]})
+	}
+	return env
+}
+
+func NodeLabels(clusterName, nodeName, role string, clusterLabels map[string]string, node *k0daconfig.NodeSpec) map[string]string {
+	labels := map[string]string{k0daconfig.LabelCluster: "true", k0daconfig.LabelClusterName: clusterName, k0daconfig.LabelClusterType: "k0s", k0daconfig.LabelNodeName: nodeName, k0daconfig.LabelNodeRole: role, k0daconfig.LabelNodeVolume: defaultNodeVolume(nodeName)}
+	if len(clusterLabels) > 0 {
+		keys := make([]string, 0, len(clusterLabels))
+		for k, v := range clusterLabels {
+			labels[k] = v
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		labels[k0daconfig.LabelClusterLabels] = strings.Join(keys, ",")
+	}
+	if node != nil && len(node.Labels) > 0 {
+		for k, v := range node.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// kernelModulesPath is where k0s looks for kernel modules, mounted from the host.
+const kernelModulesPath = "/lib/modules"
+
+// hostPathExists reports whether path exists on the host; tests replace it.
+var hostPathExists = func(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// hostKernelMounts returns the host paths k0s needs from a Linux host. Unless
+// options.mountKernelModules forces it either way, /lib/modules is mounted only when
+// it exists on the host: it is missing on Windows, macOS and some VM-based runtimes.
+func hostKernelMounts(opts k0daconfig.OptionsSpec) runtime.Mounts {
+	mount := opts.MountKernelModules == nil && goruntime.GOOS != "windows" && hostPathExists(kernelModulesPath)
+	if opts.MountKernelModules != nil {
+		mount = *opts.MountKernelModules
+	}
+	if !mount {
+		return nil
+	}
+	return runtime.Mounts{
+		runtime.Mount{Type: "bind", Source: kernelModulesPath, Target: kernelModulesPath, Options: []string{"ro"}},
+	}
+}
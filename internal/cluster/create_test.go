@@ -0,0 +1,478 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	goruntime "runtime"
+	"sync"
+	"testing"
+
+	"github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildK0sControllerArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		cc        *config.ClusterConfig
+		node      *config.NodeSpec
+		isPrimary bool
+		expected  []string
+	}{
+		{
+			name: "primary single node",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s:   config.K0sSpec{},
+				},
+			},
+			node:      &config.NodeSpec{Name: "node1", Role: "controller"},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single", "--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+		{
+			name: "primary with metrics-server",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes:   []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					Options: config.OptionsSpec{MetricsServer: true},
+				},
+			},
+			node:      &config.NodeSpec{Name: "node1", Role: "controller"},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--ignore-pre-flight-checks",
+				"--single", "--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+		{
+			name: "primary multi node",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{
+						{Name: "node1", Role: "controller"},
+						{Name: "node2", Role: "controller"},
+					},
+					K0s: config.K0sSpec{},
+				},
+			},
+			node:      &config.NodeSpec{Name: "node1", Role: "controller"},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--enable-worker", "--no-taints",
+				"--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+		{
+			name: "secondary controller node",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{
+						{Name: "node1", Role: "controller"},
+						{Name: "node2", Role: "controller"},
+					},
+					K0s: config.K0sSpec{},
+				},
+			},
+			node:      &config.NodeSpec{Name: "node2", Role: "controller"},
+			isPrimary: false,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--enable-worker", "--no-taints",
+				"--token-file", "/etc/k0s/join.token",
+				"--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+		{
+			name: "with global k0s args",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s: config.K0sSpec{
+						Args: []string{"--debug", "--data-dir=/custom/data"},
+					},
+				},
+			},
+			node:      &config.NodeSpec{Name: "node1", Role: "controller"},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single",
+				"--config", "/etc/k0s/k0s.yaml",
+				"--debug", "--data-dir=/custom/data",
+			},
+		},
+		{
+			name: "with node-specific args",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s:   config.K0sSpec{},
+				},
+			},
+			node: &config.NodeSpec{
+				Name: "node1",
+				Role: "controller",
+				Args: []string{"--custom-arg=value", "--another-arg"},
+			},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single",
+				"--config", "/etc/k0s/k0s.yaml",
+				"--custom-arg=value", "--another-arg",
+			},
+		},
+		{
+			name: "with both global and node-specific args",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s: config.K0sSpec{
+						Args: []string{"--global-arg=value"},
+					},
+				},
+			},
+			node: &config.NodeSpec{
+				Name: "node1",
+				Role: "controller",
+				Args: []string{"--node-arg=value"},
+			},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single",
+				"--config", "/etc/k0s/k0s.yaml",
+				"--global-arg=value",
+				"--node-arg=value",
+			},
+		},
+		{
+			name: "secondary controller with args",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{
+						{Name: "node1", Role: "controller"},
+						{Name: "node2", Role: "controller"},
+					},
+					K0s: config.K0sSpec{
+						Args: []string{"--global-arg=value"},
+					},
+				},
+			},
+			node: &config.NodeSpec{
+				Name: "node2",
+				Role: "controller",
+				Args: []string{"--node-arg=value"},
+			},
+			isPrimary: false,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--enable-worker", "--no-taints",
+				"--token-file", "/etc/k0s/join.token",
+				"--config", "/etc/k0s/k0s.yaml",
+				"--global-arg=value",
+				"--node-arg=value",
+			},
+		},
+		{
+			name: "nil node",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s:   config.K0sSpec{},
+				},
+			},
+			node:      nil,
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single",
+				"--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+		{
+			name: "empty global and node args",
+			cc: &config.ClusterConfig{
+				Spec: config.Spec{
+					Nodes: []config.NodeSpec{{Name: "node1", Role: "controller"}},
+					K0s: config.K0sSpec{
+						Args: []string{},
+					},
+				},
+			},
+			node: &config.NodeSpec{
+				Name: "node1",
+				Role: "controller",
+				Args: []string{},
+			},
+			isPrimary: true,
+			expected: []string{
+				"k0s", "controller",
+				"--enable-dynamic-config", "--disable-components=metrics-server", "--ignore-pre-flight-checks",
+				"--single",
+				"--config", "/etc/k0s/k0s.yaml",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := buildK0sControllerArgs(tt.cc, tt.node, tt.isPrimary)
+			assert.Equal(t, tt.expected, result, "buildK0sControllerArgs() = %v, want %v", result, tt.expected)
+		})
+	}
+}
+
+func TestBuildK0sWorkerArgs(t *testing.T) {
+	cc := &config.ClusterConfig{Spec: config.Spec{K0s: config.K0sSpec{Args: []string{"--global-arg"}}}}
+	node := &config.NodeSpec{Role: "worker", Args: []string{"--labels=a=b"}}
+	assert.Equal(t, []string{"k0s", "worker", "--token-file", "/etc/k0s/join.token", "--labels=a=b"}, buildK0sWorkerArgs(cc, node))
+
+	cc.Spec.K0s.WorkerConfig = map[string]any{"spec": map[string]any{}}
+	assert.Equal(t, []string{"k0s", "worker", "--token-file", "/etc/k0s/join.token", "--config", "/etc/k0s/k0s.yaml", "--labels=a=b"}, buildK0sWorkerArgs(cc, node))
+}
+
+func TestClusterImages(t *testing.T) {
+	cc := &config.ClusterConfig{}
+	assert.Equal(t, []string{"k0s:stable"}, clusterImages(cc, "k0s:stable", "k0s:flag"))
+
+	cc.Spec.Nodes = []config.NodeSpec{
+		{Role: "controller"},
+		{Role: "worker"},
+		{Role: "worker"},
+		{Role: "worker", Image: "k0s:custom"},
+		{Role: "worker", Image: "k0s:stable"},
+	}
+	assert.Equal(t, []string{"k0s:stable", "k0s:flag", "k0s:custom"}, clusterImages(cc, "k0s:stable", "k0s:flag"))
+
+	cc.Spec.Nodes = []config.NodeSpec{{Role: "worker"}, {Role: "worker"}}
+	cc.Spec.K0s.JoinToken = "H4sI"
+	assert.Equal(t, []string{"k0s:stable"}, clusterImages(cc, "k0s:stable", "k0s:flag"))
+}
+
+func TestNodeNetworkAliases(t *testing.T) {
+	// Configured name differs from the container name.
+	node := &config.NodeSpec{Name: "my-ctrl", Role: "controller"}
+	assert.Equal(t, []string{"my-ctrl"}, nodeNetworkAliases("demo", nodeHostname(node, "demo"), node))
+
+	// The primary container is named after its configured name, so no alias is needed.
+	cc := &config.ClusterConfig{Spec: config.Spec{Nodes: []config.NodeSpec{*node}}}
+	primary := cc.PrimaryNodeName("demo")
+	assert.Equal(t, "my-ctrl", primary)
+	assert.Empty(t, nodeNetworkAliases(primary, nodeHostname(node, primary), node))
+
+	// Hostname override and configured name are both resolvable.
+	node = &config.NodeSpec{Name: "w1", Role: "worker", Hostname: "w1.corp.internal"}
+	assert.Equal(t, []string{"w1.corp.internal"}, nodeNetworkAliases("w1", nodeHostname(node, "w1"), node))
+
+	// Generated names need no aliases.
+	node = &config.NodeSpec{Role: "worker"}
+	assert.Empty(t, nodeNetworkAliases("demo-worker-0", nodeHostname(node, "demo-worker-0"), node))
+}
+
+func TestEnsureClusterNetwork_Subnet(t *testing.T) {
+	cc := &config.ClusterConfig{}
+	cc.Spec.Options.Network = "k0da"
+	r := &testRuntime{subnets: map[string][]string{"k0da": {"172.18.0.0/16"}, "static": {"172.30.0.0/24"}}}
+	require.NoError(t, ensureClusterNetwork(context.Background(), r, cc))
+
+	// An existing network keeps its subnet, whatever the config says.
+	cc.Spec.Options.NetworkSubnet = "172.30.0.0/24"
+	require.ErrorContains(t, ensureClusterNetwork(context.Background(), r, cc), `network "k0da" has subnet 172.18.0.0/16`)
+
+	cc.Spec.Options.Network = "static"
+	require.NoError(t, ensureClusterNetwork(context.Background(), r, cc))
+	create := false
+	cc.Spec.Options.NetworkCreate = &create
+	require.NoError(t, ensureClusterNetwork(context.Background(), r, cc))
+	assert.Equal(t, []string{"ensure k0da", "ensure k0da", "ensure static"}, r.calls)
+}
+
+func TestConnectNodeNetworks(t *testing.T) {
+	node := &config.NodeSpec{Networks: []string{"db", "k0da", "cache"}}
+	extras := nodeExtras{Networks: []string{"cache", "shared"}}
+	assert.Equal(t, []string{"cache", "shared", "db"}, nodeNetworks(node, extras, "k0da"))
+	assert.Equal(t, []string{"cache", "shared"}, nodeNetworks(nil, extras, "k0da"))
+	assert.Equal(t, []string{"cache", "shared"}, extras.Networks, "extras must not be modified")
+
+	r := &testRuntime{}
+	require.NoError(t, connectNodeNetworks(context.Background(), r, io.Discard, "demo", &config.NodeSpec{Networks: []string{"db"}}, nodeExtras{}, "k0da"))
+	assert.Equal(t, []string{"ensure db", "connect demo db"}, r.calls)
+}
+
+func TestNodeContainerNames_MixedRoles(t *testing.T) {
+	cc := &config.ClusterConfig{Spec: config.Spec{Nodes: []config.NodeSpec{
+		{Role: "worker"},
+		{Role: "controller"},
+		{Role: "worker"},
+		{Role: "controller"},
+		{Name: "edge", Role: "worker"},
+		{Role: "worker"},
+	}}}
+	expected := []string{"demo-worker-0", "demo", "demo-worker-1", "demo-controller-1", "edge", "demo-worker-3"}
+	assert.Equal(t, expected, nodeContainerNames(cc, "demo"))
+	// Deterministic across calls, e.g. when re-running for recreate.
+	assert.Equal(t, expected, nodeContainerNames(cc, "demo"))
+
+	named := &config.ClusterConfig{Spec: config.Spec{Nodes: []config.NodeSpec{
+		{Name: "ctrl", Role: "controller"},
+		{Role: ""},
+	}}}
+	assert.Equal(t, []string{"ctrl", "demo-worker-0"}, nodeContainerNames(named, "demo"))
+}
+
+func TestBuildEnvFromNode_ConfigWins(t *testing.T) {
+	node := &config.NodeSpec{Env: map[string]string{"A": "node", "C": "3"}}
+	env := buildEnvFromNode(node, map[string]string{"A": "file", "B": "2"})
+	assert.Equal(t, []string{"A=node", "B=2", "C=3"}, env.ToOSStrings())
+	assert.Nil(t, buildEnvFromNode(nil, nil))
+}
+
+func TestNodesWithoutRuntimeSocket(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(paths.HomeEnv, home)
+	t.Setenv("KUBECONFIG", filepath.Join(home, "kubeconfig"))
+	cc := &config.ClusterConfig{Kind: "Cluster", APIVersion: config.APIVersion}
+	cc.Spec.Nodes = []config.NodeSpec{{Name: "demo", Role: "controller"}, {Name: "w1", Role: "worker"}}
+	require.NoError(t, cc.Validate())
+	r := &testRuntime{out: "token", outs: map[string]string{
+		"k0s kubeconfig admin": "clusters:\n- name: k0s\n  cluster:\n    server: https://localhost:6443\ncontexts:\n- name: k0s\nusers:\n- name: k0s\n",
+	}}
+
+	_, err := createK0sCluster(context.Background(), r, io.Discard, "demo", "k0s", false, "", cc, nodeExtras{})
+	require.NoError(t, err)
+	// Workers don't get the socket, even with the option set.
+	cc.Spec.Options.MountRuntimeSocket = true
+	require.NoError(t, joinAdditionalNodes(context.Background(), r, io.Discard, "demo", "k0s", false, "", cc, nodeExtras{}))
+
+	require.Len(t, r.runs, 2)
+	for _, opts := range r.runs {
+		for _, m := range opts.Mounts {
+			assert.NotContains(t, m.Target, ".sock", opts.Name)
+		}
+	}
+}
+
+func TestHostKernelMounts(t *testing.T) {
+	exists := true
+	orig := hostPathExists
+	hostPathExists = func(string) bool { return exists }
+	defer func() { hostPathExists = orig }()
+
+	on, off := true, false
+	if goruntime.GOOS != "windows" {
+		assert.Len(t, hostKernelMounts(config.OptionsSpec{}), 1)
+	}
+
+	exists = false
+	assert.Empty(t, hostKernelMounts(config.OptionsSpec{}))
+	assert.Len(t, hostKernelMounts(config.OptionsSpec{MountKernelModules: &on}), 1)
+
+	exists = true
+	assert.Empty(t, hostKernelMounts(config.OptionsSpec{MountKernelModules: &off}))
+}
+
+func TestApplyClusterLabels(t *testing.T) {
+	cc := &config.ClusterConfig{Spec: config.Spec{Labels: map[string]string{"team": "red", "env": "ci"}}}
+	assert.NoError(t, applyClusterLabels(cc, []string{"team=blue", "ttl=2h"}))
+	assert.Equal(t, map[string]string{"team": "blue", "env": "ci", "ttl": "2h"}, cc.Spec.Labels)
+
+	assert.Error(t, applyClusterLabels(&config.ClusterConfig{}, []string{"team"}))
+	assert.Error(t, applyClusterLabels(&config.ClusterConfig{}, []string{"k0da.cluster.name=x"}))
+}
+
+func TestRunWithAPIPort_Concurrent(t *testing.T) {
+	r := &testRuntime{bound: map[int]string{}, failOnce: map[string]bool{"node-0": true, "node-1": true}}
+
+	const n = 16
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = runWithAPIPort(context.Background(), r, io.Discard, runtime.RunContainerOptions{
+				Name:    fmt.Sprintf("node-%d", i),
+				Publish: []runtime.PortSpec{{ContainerPort: 6443, HostIP: "127.0.0.1", Protocol: "tcp"}},
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, r.bound, n, "every node must get its own API port")
+	assert.ElementsMatch(t, []string{"node-0", "node-1"}, r.removed)
+}
+
+func TestRunWithAPIPort_ConfiguredPortNotRetried(t *testing.T) {
+	r := &testRuntime{bound: map[int]string{6443: "other"}}
+	_, err := runWithAPIPort(context.Background(), r, io.Discard, runtime.RunContainerOptions{
+		Name:    "node",
+		Publish: []runtime.PortSpec{{ContainerPort: 6443, HostPort: 6443}},
+	})
+	require.True(t, runtime.IsPortInUse(err))
+	assert.Empty(t, r.removed)
+}
+
+func TestRunWithAPIPort_ReturnsBinding(t *testing.T) {
+	r := &testRuntime{bound: map[int]string{}}
+	api, err := runWithAPIPort(context.Background(), r, io.Discard, runtime.RunContainerOptions{
+		Name:    "node",
+		Publish: []runtime.PortSpec{{ContainerPort: 6443, HostIP: "127.0.0.1", Protocol: "tcp"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "node", r.bound[api.HostPort])
+	assert.Equal(t, fmt.Sprintf("https://127.0.0.1:%d", api.HostPort), apiServerURL(api, ""))
+}
+
+func TestRunWithAPIPort_AllocatesOtherPorts(t *testing.T) {
+	r := &testRuntime{bound: map[int]string{}, failOnce: map[string]bool{"node": true}}
+	publish := ensureIngressExposed(ensureDNSExposed([]runtime.PortSpec{{ContainerPort: 6443, Protocol: "tcp"}}))
+	_, err := runWithAPIPort(context.Background(), r, io.Discard, runtime.RunContainerOptions{Name: "node", Publish: publish})
+	require.NoError(t, err)
+	// API, DNS over udp and tcp on one port, and the two ingress ports.
+	assert.Len(t, r.bound, 4)
+	assert.Equal(t, []string{"node"}, r.removed)
+	assert.Zero(t, publish[1].HostPort, "the caller's ports are left alone")
+}
+
+func TestAPIServerURL(t *testing.T) {
+	assert.Equal(t, "https://127.0.0.1:6443", apiServerURL(runtime.PortSpec{ContainerPort: 6443, HostPort: 6443}, ""))
+	assert.Equal(t, "https://127.0.0.1:40001", apiServerURL(runtime.PortSpec{HostIP: "0.0.0.0", HostPort: 40001}, ""))
+	assert.Equal(t, "https://[::1]:40001", apiServerURL(runtime.PortSpec{HostIP: "::1", HostPort: 40001}, ""))
+	assert.Equal(t, "https://build-box.lan:40001", apiServerURL(runtime.PortSpec{HostIP: "0.0.0.0", HostPort: 40001}, "build-box.lan"))
+	assert.Empty(t, apiServerURL(runtime.PortSpec{}, "build-box.lan"))
+}
+
+func TestEnsureIngressExposed(t *testing.T) {
+	publish := ensureIngressExposed([]runtime.PortSpec{{ContainerPort: config.IngressHTTPNodePort, HostPort: 8080}})
+	require.Len(t, publish, 2)
+	assert.Equal(t, 8080, publish[0].HostPort)
+	assert.Equal(t, config.IngressHTTPSNodePort, publish[1].ContainerPort)
+	assert.Zero(t, publish[1].HostPort)
+
+	assert.Equal(t, "http://127.0.0.1:8080", hostURL("http", "0.0.0.0", 8080, ""))
+	assert.Equal(t, "https://build-box.lan:8443", hostURL("https", "", 8443, "build-box.lan"))
+}
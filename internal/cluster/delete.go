@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/utils"
+)
+
+// Delete removes all nodes of a cluster with their volumes, its kubeconfig context
+// and its state directory, printing progress to out; nil discards it.
+func Delete(ctx context.Context, r runtime.Runtime, clusterName string, out io.Writer) error {
+	out = progress(out)
+	// Find all containers for this cluster and delete them
+	list, err := r.ListContainersByLabel(ctx, map[string]string{k0daconfig.LabelClusterName: clusterName}, true)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("cluster '%s' not found", clusterName)
+	}
+	// Stop running containers first
+	for _, c := range list {
+		running, err := r.ContainerIsRunning(ctx, c.Name)
+		if err == nil && running {
+			fmt.Fprintf(out, "Stopping node '%s'...\n", c.Name)
+			_ = r.StopContainer(ctx, c.Name)
+		}
+	}
+	for _, c := range list {
+		fmt.Fprintf(out, "Deleting node '%s'...\n", c.Name)
+		if err := r.RemoveContainer(ctx, c.Name); err != nil {
+			fmt.Fprintf(out, "Warning: failed to remove container %s: %v\n", c.Name, err)
+		}
+		// Remove its volume
+		volName := NodeVolume(c)
+		if exists, _ := r.VolumeExists(ctx, volName); exists {
+			fmt.Fprintf(out, "Removing volume '%s'...\n", volName)
+			if err := r.RemoveVolume(ctx, volName); err != nil {
+				fmt.Fprintf(out, "Warning: failed to remove volume '%s': %v\n", volName, err)
+			}
+		}
+	}
+
+	// Remove cluster from unified kubeconfig
+	if err := utils.RemoveClusterFromKubeconfig(clusterName); err != nil {
+		fmt.Fprintf(out, "Warning: failed to remove cluster from kubeconfig: %v\n", err)
+	}
+
+	// Remove cluster working directory under $K0DA_HOME/clusters/<name>
+	dir := paths.ClusterDir(clusterName)
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(out, "Warning: failed to remove cluster directory %s: %v\n", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// testRuntime is the Runtime the tests of this package share. It keeps just enough
+// state for the calls they make; other Runtime methods are not used.
+type testRuntime struct {
+	runtime.Runtime
+	mu sync.Mutex
+
+	// nodes are returned by ListContainersByLabel.
+	nodes []runtime.ContainerInfo
+	// calls records network calls as "ensure <network>" and "connect <container> <network>".
+	calls []string
+	// subnets are the subnets of the existing networks.
+	subnets map[string][]string
+
+	// out and exit are the output and exit code of ExecInContainer, unless outs has an
+	// output for the command joined by spaces. It records the container and args of the
+	// last call and the args of all calls in execs.
+	out       string
+	outs      map[string]string
+	exit      int
+	container string
+	args      []string
+	execs     [][]string
+	// copied records the destinations of CopyToContainer.
+	copied []string
+
+	// present are the images on the host, which all have imageID; pulls of failPull fail.
+	present  map[string]bool
+	imageID  string
+	failPull string
+	pulled   []string
+
+	// image is saved by SaveImage; streamArgs and stdin record what is piped into
+	// ExecInContainerStream, which answers like `ctr images import`.
+	image      *imageStream
+	streamArgs []string
+	stdin      string
+
+	// bound maps the published host ports to the containers binding them. RunContainer
+	// fails for ports that are already bound, like a container runtime would, and once
+	// for the names in failOnce.
+	bound    map[int]string
+	failOnce map[string]bool
+	removed  []string
+	// runs records the options of the containers RunContainer started.
+	runs []runtime.RunContainerOptions
+}
+
+type imageStream struct {
+	io.Reader
+	closed bool
+}
+
+func (s *imageStream) Close() error { s.closed = true; return nil }
+
+func (r *testRuntime) Name() string { return "test" }
+
+func (r *testRuntime) ListContainersByLabel(context.Context, map[string]string, bool) ([]runtime.ContainerInfo, error) {
+	return r.nodes, nil
+}
+
+func (r *testRuntime) EnsureNetwork(_ context.Context, name string, _ runtime.NetworkOptions) error {
+	r.calls = append(r.calls, "ensure "+name)
+	return nil
+}
+
+func (r *testRuntime) NetworkExists(_ context.Context, name string) (bool, error) {
+	_, ok := r.subnets[name]
+	return ok, nil
+}
+
+func (r *testRuntime) NetworkSubnets(_ context.Context, name string) ([]string, error) {
+	return r.subnets[name], nil
+}
+
+func (r *testRuntime) ConnectNetwork(_ context.Context, container, network string) error {
+	r.calls = append(r.calls, "connect "+container+" "+network)
+	return nil
+}
+
+func (r *testRuntime) ExecInContainer(_ context.Context, container string, args []string) (string, int, error) {
+	r.container, r.args = container, args
+	r.execs = append(r.execs, args)
+	if out, ok := r.outs[strings.Join(args, " ")]; ok {
+		return out, 0, nil
+	}
+	return r.out, r.exit, nil
+}
+
+func (r *testRuntime) CopyToContainer(_ context.Context, _ string, _ string, dst string) error {
+	r.copied = append(r.copied, dst)
+	return nil
+}
+
+func (r *testRuntime) ImageExists(_ context.Context, ref string) (bool, error) {
+	return r.present[ref], nil
+}
+
+func (r *testRuntime) ImageID(context.Context, string) (string, error) {
+	return r.imageID, nil
+}
+
+func (r *testRuntime) PullImage(_ context.Context, ref string) error {
+	r.pulled = append(r.pulled, ref)
+	if ref == r.failPull {
+		return fmt.Errorf("pull access denied")
+	}
+	return nil
+}
+
+func (r *testRuntime) SaveImage(context.Context, string) (io.ReadCloser, error) {
+	return r.image, nil
+}
+
+func (r *testRuntime) ExecInContainerStream(_ context.Context, _ string, command []string, stdin io.Reader, stdout, _ io.Writer) (int, error) {
+	r.streamArgs = command
+	b, err := io.ReadAll(stdin)
+	r.stdin = string(b)
+	_, _ = io.WriteString(stdout, "unpacking localhost/app:dev (sha256:0123)...done\n")
+	return 0, err
+}
+
+func (r *testRuntime) RunContainer(_ context.Context, opts runtime.RunContainerOptions) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ps := range opts.Publish {
+		if owner, ok := r.bound[ps.HostPort]; (ok && owner != opts.Name) || r.failOnce[opts.Name] {
+			delete(r.failOnce, opts.Name)
+			return "", fmt.Errorf("Bind for 0.0.0.0:%d failed: port is already allocated (owner %q)", ps.HostPort, owner)
+		}
+	}
+	if r.bound == nil {
+		r.bound = map[int]string{}
+	}
+	for _, ps := range opts.Publish {
+		r.bound[ps.HostPort] = opts.Name
+	}
+	r.runs = append(r.runs, opts)
+	return opts.Name, nil
+}
+
+func (r *testRuntime) RemoveContainer(_ context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, name)
+	return nil
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// Image is an image present in a node's containerd.
+type Image struct {
+	Node   string `json:"node"`
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+	Size   string `json:"size"`
+}
+
+// ListImages returns the images in the k8s.io containerd namespace of the given node
+// containers, which is where the kubelet pulls images to.
+func ListImages(ctx context.Context, r runtime.Runtime, nodes ...string) ([]Image, error) {
+	var images []Image
+	for _, node := range nodes {
+		out, exit, err := r.ExecInContainer(ctx, node, []string{"k0s", "ctr", "-n", "k8s.io", "images", "ls"})
+		if err != nil || exit != 0 {
+			return nil, fmt.Errorf("failed to list images on %s: %v %s", node, err, strings.TrimSpace(out))
+		}
+		for _, img := range parseImageList(out) {
+			img.Node = node
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// parseImageList parses the table printed by `ctr images ls`: a header line starting
+// with REF, then ref, type, digest, size (a number and a unit), platforms and labels.
+// The sha256: and name@sha256: digest refs that the CRI plugin adds next to each
+// tagged image are skipped.
+func parseImageList(out string) []Image {
+	var images []Image
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] == "REF" || strings.HasPrefix(fields[0], "sha256:") || strings.Contains(fields[0], "@sha256:") {
+			continue
+		}
+		images = append(images, Image{Ref: fields[0], Digest: fields[2], Size: fields[3] + " " + fields[4]})
+	}
+	return images
+}
+
+// RemoveImage removes ref from the k8s.io containerd namespace of the node container.
+// It reports whether the image was there; a missing image is not an error.
+func RemoveImage(ctx context.Context, r runtime.Runtime, node, ref string) (bool, error) {
+	out, exit, err := r.ExecInContainer(ctx, node, []string{"k0s", "ctr", "-n", "k8s.io", "images", "rm", ref})
+	if err != nil || exit != 0 {
+		return false, fmt.Errorf("failed to remove image on %s: %v %s", node, err, strings.TrimSpace(out))
+	}
+	// ctr prints the refs it removed and only logs a warning for the missing ones.
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == ref {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageList(t *testing.T) {
+	out := `REF                                                                                      TYPE                                                      DIGEST                                                                  SIZE      PLATFORMS                                       LABELS
+docker.io/library/nginx:1.27                                                             application/vnd.oci.image.index.v1+json                   sha256:4f2c0ed8b5e4a2a5b3e44ad3e2e7b8f0f6b3b0f8e4f1d3a5c6b7d8e9f0a1b2c3 67.7 MiB  linux/386,linux/amd64,linux/arm64               io.cri-containerd.image=managed
+docker.io/library/nginx@sha256:4f2c0ed8b5e4a2a5b3e44ad3e2e7b8f0f6b3b0f8e4f1d3a5c6b7d8e9f0a1b2c3 application/vnd.oci.image.index.v1+json                   sha256:4f2c0ed8b5e4a2a5b3e44ad3e2e7b8f0f6b3b0f8e4f1d3a5c6b7d8e9f0a1b2c3 67.7 MiB  linux/386,linux/amd64,linux/arm64               io.cri-containerd.image=managed
+sha256:9bea9f2796e236cb18c2b3ad561ff29f655d1001f9ec7247a0bc5e08d25652a1                  application/vnd.docker.distribution.manifest.v2+json      sha256:1ac2a4f3b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2 742.5 KiB linux/amd64                                     io.cri-containerd.image=managed,io.cri-containerd.pinned=pinned
+`
+	images := parseImageList(out)
+	assert.Len(t, images, 1)
+	assert.Equal(t, Image{
+		Ref:    "docker.io/library/nginx:1.27",
+		Digest: "sha256:4f2c0ed8b5e4a2a5b3e44ad3e2e7b8f0f6b3b0f8e4f1d3a5c6b7d8e9f0a1b2c3",
+		Size:   "67.7 MiB",
+	}, images[0])
+
+	assert.Empty(t, parseImageList(""))
+}
+
+func TestRemoveImage(t *testing.T) {
+	r := &testRuntime{out: "docker.io/library/nginx:latest\n"}
+	removed, err := RemoveImage(context.Background(), r, "dev", "docker.io/library/nginx:latest")
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, []string{"k0s", "ctr", "-n", "k8s.io", "images", "rm", "docker.io/library/nginx:latest"}, r.args)
+
+	r.out = `time="2025-01-02T12:00:00Z" level=warning msg="docker.io/library/nginx:latest: image not found"` + "\n"
+	removed, err = RemoveImage(context.Background(), r, "dev", "docker.io/library/nginx:latest")
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestPrePullImages(t *testing.T) {
+	ctx := context.Background()
+	images := []string{"k0s:a", "k0s:b"}
+
+	r := &testRuntime{present: map[string]bool{"k0s:a": true}}
+	assert.Equal(t, runtime.PullMissing, prePullImages(ctx, r, io.Discard, images, runtime.PullMissing))
+	assert.Equal(t, []string{"k0s:b"}, r.pulled)
+
+	// Pulled once here, nodes don't pull again.
+	r = &testRuntime{present: map[string]bool{"k0s:a": true}}
+	assert.Equal(t, runtime.PullMissing, prePullImages(ctx, r, io.Discard, images, runtime.PullAlways))
+	assert.Equal(t, images, r.pulled)
+
+	// Nodes retry what failed to pull.
+	r = &testRuntime{failPull: "k0s:b"}
+	assert.Equal(t, runtime.PullAlways, prePullImages(ctx, r, io.Discard, images, runtime.PullAlways))
+
+	r = &testRuntime{}
+	assert.Equal(t, runtime.PullNever, prePullImages(ctx, r, io.Discard, images, runtime.PullNever))
+	assert.Empty(t, r.pulled)
+}
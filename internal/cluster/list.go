@@ -0,0 +1,188 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// Info describes a cluster, as shown by `k0da list`.
+type Info struct {
+	Name    string `json:"name"`
+	Context string `json:"context"` // kubeconfig context
+	// APIEndpoint is the host URL of the Kubernetes API; empty when the port is not published.
+	APIEndpoint string `json:"api_endpoint"`
+	ContainerID string `json:"container_id"`
+	Image       string `json:"image"`
+	// Status is the status of the representative node as the runtime reports it,
+	// e.g. "Up 3 minutes" or "Exited (0) 2 minutes ago".
+	Status string `json:"status"`
+	// Health is the healthcheck result of the representative node (healthy, unhealthy or
+	// starting); empty when the cluster was created without options.healthcheck.
+	Health string `json:"health,omitempty"`
+	// State is the normalized state of the representative node, see runtime.State*.
+	State   string    `json:"state"`
+	Ports   string    `json:"ports"`
+	Nodes   int       `json:"nodes"`
+	Created time.Time `json:"created"` // earliest node; zero when the runtime did not report it
+	// Labels are the user-defined cluster labels (create --label / spec.labels).
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Filter narrows the cluster list. Labels are pushed down to the runtime selector,
+// name patterns and state are matched against the clusters after grouping.
+type Filter struct {
+	Labels       map[string]string
+	NamePatterns []string
+	// State is StateRunning or StateStopped to only keep clusters whose representative
+	// node is, or is not, running; empty or StateAll keeps all.
+	State string
+}
+
+// States accepted by Filter.State.
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+	StateAll     = "all"
+)
+
+// MatchState reports whether a cluster with the given node state passes the state filter.
+func (f Filter) MatchState(state string) bool {
+	switch f.State {
+	case StateRunning:
+		return state == runtime.StateRunning
+	case StateStopped:
+		return state != runtime.StateRunning
+	}
+	return true
+}
+
+// MatchName reports whether the cluster name matches all name patterns.
+func (f Filter) MatchName(name string) bool {
+	for _, p := range f.NamePatterns {
+		if ok, _ := path.Match(p, name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the k0da clusters matching filter, sorted by name. Clusters whose
+// nodes are all stopped are only included when includeStopped is set.
+func List(ctx context.Context, r runtime.Runtime, includeStopped bool, filter Filter) ([]Info, error) {
+	selector := map[string]string{k0daconfig.LabelCluster: "true"}
+	for k, v := range filter.Labels {
+		selector[k] = v
+	}
+	list, err := r.ListContainersByLabel(ctx, selector, includeStopped)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(list, filter), nil
+}
+
+// summarize groups node containers by cluster name into one Info per cluster.
+// The controller node is preferred as the representative for display, nodes are counted
+// and the cluster creation time is that of its earliest node.
+func summarize(list []runtime.ContainerInfo, filter Filter) []Info {
+	grouped := map[string]runtime.ContainerInfo{}
+	nodes := map[string]int{}
+	earliest := map[string]int64{}
+	for _, c := range list {
+		cluster := c.Name
+		if v, ok := c.Labels[k0daconfig.LabelClusterName]; ok && strings.TrimSpace(v) != "" {
+			cluster = v
+		}
+		nodes[cluster]++
+		if c.Created > 0 && (earliest[cluster] == 0 || c.Created < earliest[cluster]) {
+			earliest[cluster] = c.Created
+		}
+		if existing, ok := grouped[cluster]; ok {
+			role := strings.ToLower(c.Labels[k0daconfig.LabelNodeRole])
+			exrole := strings.ToLower(existing.Labels[k0daconfig.LabelNodeRole])
+			if exrole != "controller" && role == "controller" {
+				grouped[cluster] = c
+			}
+		} else {
+			grouped[cluster] = c
+		}
+	}
+	clusters := make([]Info, 0, len(grouped))
+	for name, c := range grouped {
+		if !filter.MatchName(name) || !filter.MatchState(c.State) {
+			continue
+		}
+		id := c.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		clusters = append(clusters, Info{
+			Name:        name,
+			Context:     fmt.Sprintf("k0da-%s", name),
+			APIEndpoint: apiServerURL(apiPortFromPorts(c.Ports), APIServerAddress(name)),
+			ContainerID: id,
+			Image:       c.Image,
+			Status:      c.Status,
+			Health:      c.Health,
+			State:       c.State,
+			Ports:       c.Ports,
+			Nodes:       nodes[name],
+			Created:     createdTime(earliest[name]),
+			Labels:      UserLabels(c),
+		})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+
+	return clusters
+}
+
+// UserLabels returns the user-defined cluster labels recorded on a node container.
+func UserLabels(c runtime.ContainerInfo) map[string]string {
+	keys := c.Labels[k0daconfig.LabelClusterLabels]
+	if keys == "" {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, k := range strings.Split(keys, ",") {
+		if v, ok := c.Labels[k]; ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// apiPortFromPorts finds the host binding of the API port (6443/tcp) in a human-readable
+// port list like "0.0.0.0:55131->6443/tcp, ..."; its HostPort is 0 when there is none.
+func apiPortFromPorts(ports string) runtime.PortSpec {
+	for _, p := range strings.Split(ports, ",") {
+		host, target, ok := strings.Cut(strings.TrimSpace(p), "->")
+		if !ok || target != "6443/tcp" {
+			continue
+		}
+		idx := strings.LastIndex(host, ":")
+		if idx == -1 {
+			continue
+		}
+		port, err := strconv.Atoi(host[idx+1:])
+		if err != nil {
+			continue
+		}
+		return runtime.PortSpec{ContainerPort: 6443, Protocol: "tcp", HostIP: strings.Trim(host[:idx], "[]"), HostPort: port}
+	}
+	return runtime.PortSpec{}
+}
+
+// createdTime converts runtime-reported unix seconds into a time; 0 means unknown.
+func createdTime(unix int64) time.Time {
+	if unix <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,124 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/runtime/runtimetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeClusters_CountsNodesAndAge(t *testing.T) {
+	list := []runtime.ContainerInfo{
+		{ID: "w0", Name: "dev-worker-0", Created: 200, Labels: map[string]string{config.LabelClusterName: "dev", config.LabelNodeRole: "worker"}},
+		{ID: "c0", Name: "dev", Created: 100, Labels: map[string]string{config.LabelClusterName: "dev", config.LabelNodeRole: "controller"}},
+		{ID: "w1", Name: "dev-worker-1", Created: 300, Labels: map[string]string{config.LabelClusterName: "dev", config.LabelNodeRole: "worker"}},
+		{ID: "x0", Name: "solo", Labels: map[string]string{config.LabelClusterName: "solo", config.LabelNodeRole: "controller"}},
+	}
+	clusters := summarize(list, Filter{})
+	assert.Len(t, clusters, 2)
+
+	assert.Equal(t, "dev", clusters[0].Name)
+	assert.Equal(t, "c0", clusters[0].ContainerID)
+	assert.Equal(t, 3, clusters[0].Nodes)
+	assert.Equal(t, time.Unix(100, 0), clusters[0].Created)
+
+	assert.Equal(t, "solo", clusters[1].Name)
+	assert.Equal(t, 1, clusters[1].Nodes)
+	assert.True(t, clusters[1].Created.IsZero())
+}
+
+func TestAPIPortFromPorts(t *testing.T) {
+	assert.Equal(t, "https://127.0.0.1:55131", apiServerURL(apiPortFromPorts("0.0.0.0:55131->6443/tcp"), ""))
+	assert.Equal(t, "https://127.0.0.1:40001", apiServerURL(apiPortFromPorts("127.0.0.1:8080->80/tcp, :::40001->6443/tcp"), ""))
+	assert.Equal(t, "https://192.168.1.5:6443", apiServerURL(apiPortFromPorts("192.168.1.5:6443->6443/tcp"), ""))
+	assert.Empty(t, apiServerURL(apiPortFromPorts("0.0.0.0:8080->80/tcp"), ""))
+	assert.Empty(t, apiServerURL(apiPortFromPorts(""), ""))
+}
+
+func TestSummarizeClusters_APIServerAddress(t *testing.T) {
+	t.Setenv(paths.HomeEnv, t.TempDir())
+	cc := &config.ClusterConfig{Kind: "Cluster", APIVersion: config.APIVersion}
+	cc.Spec.Options.APIServerAddress = "build-box.lan"
+	require.NoError(t, cc.WriteStoredConfig("remote"))
+
+	list := []runtime.ContainerInfo{
+		runtimetest.Node("remote", "remote", "controller", runtime.StateRunning),
+		runtimetest.Node("local", "local", "controller", runtime.StateRunning),
+	}
+	list[0].Ports = "0.0.0.0:40001->6443/tcp"
+	list[1].Ports = "0.0.0.0:40002->6443/tcp"
+	clusters := summarize(list, Filter{})
+	require.Len(t, clusters, 2)
+	// Like the kubeconfig server create writes.
+	assert.Equal(t, "https://127.0.0.1:40002", clusters[0].APIEndpoint)
+	assert.Equal(t, "https://build-box.lan:40001", clusters[1].APIEndpoint)
+}
+
+func TestSummarizeClusters_Labels(t *testing.T) {
+	labels := NodeLabels("dev", "dev", "controller", map[string]string{"team": "blue", "ttl": "2h"}, &config.NodeSpec{Labels: map[string]string{"gpu": "true"}})
+	assert.Equal(t, "team,ttl", labels[config.LabelClusterLabels])
+	assert.Equal(t, "true", labels["gpu"])
+
+	clusters := summarize([]runtime.ContainerInfo{{Name: "dev", Labels: labels}}, Filter{})
+	assert.Equal(t, map[string]string{"team": "blue", "ttl": "2h"}, clusters[0].Labels)
+}
+
+func TestFilterMatchName(t *testing.T) {
+	f := Filter{NamePatterns: []string{"dev-*", "*-blue"}}
+	assert.True(t, f.MatchName("dev-blue"))
+	assert.False(t, f.MatchName("dev-red"))
+	assert.True(t, Filter{}.MatchName("anything"))
+}
+
+func TestSummarizeClusters_IncludesStopped(t *testing.T) {
+	clusters := summarize([]runtime.ContainerInfo{
+		{Name: "dev", Status: "Exited (0) 2 minutes ago", State: runtime.StateExited, Labels: map[string]string{config.LabelClusterName: "dev", config.LabelNodeRole: "controller"}},
+		{Name: "dev-worker-0", Status: "Exited (0) 2 minutes ago", State: runtime.StateExited, Labels: map[string]string{config.LabelClusterName: "dev", config.LabelNodeRole: "worker"}},
+		{Name: "prod", Status: "Up 3 minutes", State: runtime.StateRunning, Labels: map[string]string{config.LabelClusterName: "prod", config.LabelNodeRole: "controller"}},
+	}, Filter{})
+	assert.Len(t, clusters, 2)
+	// The raw status is kept for display next to the normalized state.
+	assert.Equal(t, "Exited (0) 2 minutes ago", clusters[0].Status)
+	assert.Equal(t, runtime.StateExited, clusters[0].State)
+	assert.Equal(t, "Up 3 minutes", clusters[1].Status)
+	assert.Equal(t, runtime.StateRunning, clusters[1].State)
+}
+
+func TestSummarizeClusters_StateFilter(t *testing.T) {
+	node := runtimetest.Node
+	list := []runtime.ContainerInfo{
+		node("dev", "dev", "controller", runtime.StateRunning),
+		node("dev", "dev-worker-0", "worker", runtime.StateExited),
+		node("old", "old", "controller", runtime.StateExited),
+		node("new", "new-worker-0", "worker", runtime.StateRunning),
+		node("new", "new", "controller", runtime.StateCreated),
+	}
+	names := func(clusters []Info) []string {
+		var out []string
+		for _, c := range clusters {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"dev", "new", "old"}, names(summarize(list, Filter{})))
+	assert.Equal(t, []string{"dev", "new", "old"}, names(summarize(list, Filter{State: StateAll})))
+	// The controller represents the cluster, whatever state its workers are in.
+	assert.Equal(t, []string{"dev"}, names(summarize(list, Filter{State: StateRunning})))
+	assert.Equal(t, []string{"new", "old"}, names(summarize(list, Filter{State: StateStopped})))
+}
+
+func TestSummarizeClusters_Health(t *testing.T) {
+	clusters := summarize([]runtime.ContainerInfo{
+		{Name: "dev", Status: "Up 3 minutes (unhealthy)", State: runtime.StateRunning, Health: "unhealthy", Labels: map[string]string{config.LabelClusterName: "dev"}},
+		{Name: "old", Status: "Exited (0) 2 minutes ago", State: runtime.StateExited, Health: "unhealthy", Labels: map[string]string{config.LabelClusterName: "old"}},
+		{Name: "plain", Status: "Up 3 minutes", State: runtime.StateRunning, Labels: map[string]string{config.LabelClusterName: "plain"}},
+	}, Filter{})
+	assert.Equal(t, "unhealthy", clusters[0].Health)
+	assert.Equal(t, "unhealthy", clusters[1].Health)
+	assert.Empty(t, clusters[2].Health)
+}
@@ -0,0 +1,323 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// LoadArchive imports a tar archive or OCI layout dir into the containerd of the
+// cluster's primary node and returns the image references it imported.
+func LoadArchive(ctx context.Context, r runtime.Runtime, clusterName, src string) ([]string, error) {
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("source not found: %s", abs)
+	}
+	name, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	// Copy to container /tmp
+	inContainer := "/tmp/" + filepath.Base(abs)
+	if err := r.CopyToContainer(ctx, name, abs, inContainer); err != nil {
+		return nil, err
+	}
+	// Import via k0s ctr
+	out, code, _ := r.ExecInContainer(ctx, name, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", inContainer})
+	if code != 0 {
+		return nil, fmt.Errorf("import failed: %s", out)
+	}
+	return verifyImages(ctx, r, name, parseImportedRefs(out))
+}
+
+// LoadImage copies an image from the host runtime into the containerd of the cluster's
+// primary node and returns the image references it imported. Refs ending in .tar,
+// .tar.gz or .tgz are loaded as archives. Runtimes implementing runtime.ImageSaver
+// stream the image into the node without a temporary tar file. Unless force is set, an
+// image the node already has under its name with the same ID, see loadedRefs, is not
+// loaded again and loaded is false.
+func LoadImage(ctx context.Context, r runtime.Runtime, clusterName, imageRef string, force bool) (refs []string, loaded bool, err error) {
+	if strings.HasSuffix(imageRef, ".tar") || strings.HasSuffix(imageRef, ".tar.gz") || strings.HasSuffix(imageRef, ".tgz") {
+		refs, err := LoadArchive(ctx, r, clusterName, imageRef)
+		return refs, err == nil, err
+	}
+	name, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return nil, false, err
+	}
+	exists, err := r.ImageExists(ctx, imageRef)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to check image: %w", err)
+	}
+	if !exists {
+		return nil, false, fmt.Errorf("image '%s' not found locally; pull it first with '%s pull %s' or use --from-registry to pull it on the node", imageRef, r.Name(), imageRef)
+	}
+	id, err := r.ImageID(ctx, imageRef)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	if !force {
+		if refs := loadedRefs(ctx, r, name, nodeImageRefs(imageRef), id); len(refs) > 0 {
+			return refs, false, nil
+		}
+	}
+
+	out, err := importImage(ctx, r, name, imageRef)
+	if err != nil {
+		return nil, false, err
+	}
+	// The names the image was saved with are the ones it gets on the node; podman, for
+	// one, tags local images as localhost/<name>.
+	refs, err = verifyImages(ctx, r, name, parseImportedRefs(out))
+	if err != nil {
+		return nil, false, err
+	}
+	return refs, true, nil
+}
+
+// importImage saves imageRef from the host runtime, imports it into the node and
+// returns the output of `ctr images import`.
+func importImage(ctx context.Context, r runtime.Runtime, node, imageRef string) (string, error) {
+	if s, ok := r.(runtime.ImageSaver); ok {
+		return streamImage(ctx, r, s, node, imageRef)
+	}
+	// Podman and nerdctl are driven through their CLI, which can't hand out a save as a
+	// stream; piping `save` into `exec -i` would hide which of the two failed. Save local
+	// runtime image to a temporary tar and import it
+	tmpDir, err := os.MkdirTemp("", "k0da-img-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	tarPath := filepath.Join(tmpDir, "image.tar")
+
+	if err := r.SaveImageToTar(ctx, imageRef, tarPath); err != nil {
+		return "", fmt.Errorf("failed to save local image: %w", err)
+	}
+	inContainer := "/tmp/" + filepath.Base(tarPath)
+	if err := r.CopyToContainer(ctx, node, tarPath, inContainer); err != nil {
+		return "", fmt.Errorf("failed to copy image tar: %w", err)
+	}
+	out, code, _ := r.ExecInContainer(ctx, node, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", inContainer})
+	if code != 0 {
+		return "", fmt.Errorf("import failed: %s", out)
+	}
+	return out, nil
+}
+
+// nodeImageRefs returns the names a host image gets in the node's containerd: the
+// qualified ref, and for refs without a registry localhost/<ref>, which is how podman
+// names local images.
+func nodeImageRefs(imageRef string) []string {
+	refs := []string{QualifyImageRef(imageRef)}
+	if first, _, found := strings.Cut(imageRef, "/"); !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		refs = append(refs, "localhost/"+imageRef)
+	}
+	return refs
+}
+
+// loadedRefs returns the refs the node's containerd has for the host image id. The
+// image ID is the digest of the image config, or with Docker's containerd image store
+// the digest of the image index, so a ref matches when the target digest `ctr images
+// ls` reports is id or the manifest it points to has id as config.
+func loadedRefs(ctx context.Context, r runtime.Runtime, node string, refs []string, id string) []string {
+	cmd := []string{"k0s", "ctr", "-n", "k8s.io", "images", "ls"}
+	for _, ref := range refs {
+		cmd = append(cmd, "name=="+ref)
+	}
+	out, code, _ := r.ExecInContainer(ctx, node, cmd)
+	if code != 0 {
+		return nil
+	}
+	var loaded []string
+	for ref, digest := range parseImageDigests(out) {
+		if digest == id || slices.Contains(configDigests(ctx, r, node, digest), id) {
+			loaded = append(loaded, ref)
+		}
+	}
+	sort.Strings(loaded)
+	return loaded
+}
+
+// parseImageDigests maps the refs of `ctr images ls` output to their target digests,
+// the third column.
+func parseImageDigests(out string) map[string]string {
+	digests := map[string]string{}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			digests[fields[0]] = fields[2]
+		}
+	}
+	return digests
+}
+
+// configDigests returns the config digests of the manifest with the given digest in
+// the node's content store, or of the manifests of an image index.
+func configDigests(ctx context.Context, r runtime.Runtime, node, digest string) []string {
+	m, ok := nodeManifest(ctx, r, node, digest)
+	if !ok {
+		return nil
+	}
+	if m.Config.Digest != "" {
+		return []string{m.Config.Digest}
+	}
+	var configs []string
+	for _, desc := range m.Manifests {
+		if m, ok := nodeManifest(ctx, r, node, desc.Digest); ok && m.Config.Digest != "" {
+			configs = append(configs, m.Config.Digest)
+		}
+	}
+	return configs
+}
+
+// imageManifest holds the fields of an image manifest or index that configDigests reads.
+type imageManifest struct {
+	Config    struct{ Digest string }
+	Manifests []struct{ Digest string }
+}
+
+// nodeManifest reads a manifest or index from the node's content store.
+func nodeManifest(ctx context.Context, r runtime.Runtime, node, digest string) (imageManifest, bool) {
+	var m imageManifest
+	out, code, _ := r.ExecInContainer(ctx, node, []string{"k0s", "ctr", "-n", "k8s.io", "content", "get", digest})
+	if code != 0 || json.Unmarshal([]byte(out), &m) != nil {
+		return m, false
+	}
+	return m, true
+}
+
+// streamImage pipes the image saved by s straight into `ctr images import` on the node,
+// so no temporary tar file is written on the host, and returns the output of ctr.
+func streamImage(ctx context.Context, r runtime.Runtime, s runtime.ImageSaver, node, imageRef string) (string, error) {
+	rc, err := s.SaveImage(ctx, imageRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to save local image: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+	var out bytes.Buffer
+	code, err := r.ExecInContainerStream(ctx, node, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", "-"}, rc, &out, &out)
+	if err != nil {
+		return "", fmt.Errorf("import failed: %w", err)
+	}
+	if code != 0 {
+		return "", fmt.Errorf("import failed: %s", out.String())
+	}
+	return out.String(), nil
+}
+
+// LoadFromRegistry makes the containerd of the cluster's primary node pull the image
+// itself, so the host runtime is not involved at all.
+func LoadFromRegistry(ctx context.Context, r runtime.Runtime, clusterName, imageRef, username, password string) ([]string, error) {
+	if password != "" && username == "" {
+		return nil, fmt.Errorf("a registry password requires --username")
+	}
+	name, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	cmd := registryPullCommand(imageRef, username)
+	var out string
+	var code int
+	if username == "" {
+		out, code, _ = r.ExecInContainer(ctx, name, cmd)
+	} else {
+		// ctr only takes the password on its command line or from a terminal. It is typed
+		// into its prompt, so that it doesn't show up in any process list.
+		var buf bytes.Buffer
+		code, err = r.ExecInContainerTTY(ctx, name, cmd, strings.NewReader(password+"\n"), &buf)
+		if err != nil {
+			return nil, fmt.Errorf("pull failed: %w", err)
+		}
+		out = strings.ReplaceAll(buf.String(), password, "<redacted>")
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("pull failed: %s", out)
+	}
+	return verifyImages(ctx, r, name, []string{QualifyImageRef(imageRef)})
+}
+
+// registryPullCommand returns the ctr command that pulls imageRef into the
+// k8s.io namespace. ctr needs fully qualified references. With a username ctr
+// prompts for the password.
+func registryPullCommand(imageRef, username string) []string {
+	cmd := []string{"k0s", "ctr", "-n", "k8s.io", "images", "pull"}
+	if username != "" {
+		cmd = append(cmd, "--user", username)
+	}
+	return append(cmd, QualifyImageRef(imageRef))
+}
+
+// QualifyImageRef expands short Docker Hub references the way Docker does,
+// e.g. nginx:1.27 becomes docker.io/library/nginx:1.27.
+func QualifyImageRef(ref string) string {
+	first, rest, found := strings.Cut(ref, "/")
+	if !found {
+		return "docker.io/library/" + ref
+	}
+	if strings.ContainsAny(first, ".:") || first == "localhost" || rest == "" {
+		return ref
+	}
+	return "docker.io/" + ref
+}
+
+// verifyImages checks that the refs are present in the node's containerd and
+// returns the ones that are. It fails if none of them is, which usually means
+// the archive was tagged differently than expected.
+func verifyImages(ctx context.Context, r runtime.Runtime, node string, refs []string) ([]string, error) {
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no tagged images were imported; images saved by ID have no name, load them by a tag instead")
+	}
+	cmd := []string{"k0s", "ctr", "-n", "k8s.io", "images", "ls", "-q"}
+	for _, ref := range refs {
+		cmd = append(cmd, "name=="+ref)
+	}
+	out, code, _ := r.ExecInContainer(ctx, node, cmd)
+	if code != 0 {
+		return nil, fmt.Errorf("failed to list images: %s", out)
+	}
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		if ref := strings.TrimSpace(line); ref != "" {
+			found = append(found, ref)
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("image %s not found in the cluster after import", strings.Join(refs, ", "))
+	}
+	return found, nil
+}
+
+// parseImportedRefs extracts image references from `ctr images import` output,
+// which reports them as "unpacking <ref> (sha256:...)...done" or "<ref> saved"
+// depending on the containerd version.
+func parseImportedRefs(out string) []string {
+	var refs []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		var ref string
+		switch {
+		case len(fields) >= 2 && fields[0] == "unpacking":
+			ref = fields[1]
+		case len(fields) >= 2 && fields[1] == "saved":
+			ref = fields[0]
+		}
+		if ref != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,121 @@
+package cluster
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+func TestQualifyImageRef(t *testing.T) {
+	assert.Equal(t, "docker.io/library/nginx:1.27", QualifyImageRef("nginx:1.27"))
+	assert.Equal(t, "docker.io/library/nginx@sha256:abc", QualifyImageRef("nginx@sha256:abc"))
+	assert.Equal(t, "docker.io/bitnami/redis:7", QualifyImageRef("bitnami/redis:7"))
+	assert.Equal(t, "ghcr.io/org/app:v1", QualifyImageRef("ghcr.io/org/app:v1"))
+	assert.Equal(t, "localhost:5000/app", QualifyImageRef("localhost:5000/app"))
+	assert.Equal(t, "localhost/app", QualifyImageRef("localhost/app"))
+}
+
+func TestRegistryPullCommand(t *testing.T) {
+	assert.Equal(t,
+		[]string{"k0s", "ctr", "-n", "k8s.io", "images", "pull", "docker.io/library/nginx:1.27"},
+		registryPullCommand("nginx:1.27", ""))
+	assert.Equal(t,
+		[]string{"k0s", "ctr", "-n", "k8s.io", "images", "pull", "--user", "bob", "registry.corp:5000/app:v1"},
+		registryPullCommand("registry.corp:5000/app:v1", "bob"))
+}
+
+func TestParseImportedRefs(t *testing.T) {
+	out := "unpacking docker.io/library/nginx:1.27 (sha256:0123)...done\n" +
+		"unpacking docker.io/myorg/app:v1 (sha256:4567)...done\n"
+	assert.Equal(t, []string{"docker.io/library/nginx:1.27", "docker.io/myorg/app:v1"}, parseImportedRefs(out))
+
+	out = "docker.io/library/nginx:1.27 saved\n" +
+		"application/vnd.oci.image.index.v1+json sha256:0123\n" +
+		"Importing\telapsed: 0.2 s\ttotal:   0.0 B\t(0.0 B/s)\n"
+	assert.Equal(t, []string{"docker.io/library/nginx:1.27"}, parseImportedRefs(out))
+
+	assert.Empty(t, parseImportedRefs(""))
+}
+
+func TestStreamImage(t *testing.T) {
+	r := &testRuntime{image: &imageStream{Reader: strings.NewReader("image tar")}}
+	out, err := streamImage(context.Background(), r, r, "demo", "app:dev")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k0s", "ctr", "-n", "k8s.io", "images", "import", "-"}, r.streamArgs)
+	assert.Equal(t, []string{"localhost/app:dev"}, parseImportedRefs(out))
+	assert.Equal(t, "image tar", r.stdin)
+	assert.True(t, r.image.closed)
+}
+
+func TestNodeImageRefs(t *testing.T) {
+	assert.Equal(t, []string{"docker.io/library/app:dev", "localhost/app:dev"}, nodeImageRefs("app:dev"))
+	assert.Equal(t, []string{"docker.io/org/app:dev", "localhost/org/app:dev"}, nodeImageRefs("org/app:dev"))
+	assert.Equal(t, []string{"ghcr.io/org/app:dev"}, nodeImageRefs("ghcr.io/org/app:dev"))
+	assert.Equal(t, []string{"localhost/app:dev"}, nodeImageRefs("localhost/app:dev"))
+}
+
+func TestLoadImage_SkipsLoadedImage(t *testing.T) {
+	const (
+		ls      = "k0s ctr -n k8s.io images ls name==docker.io/library/app:dev name==localhost/app:dev"
+		header  = "REF TYPE DIGEST SIZE PLATFORMS LABELS\n"
+		listed  = header + "localhost/app:dev application/vnd.oci.image.manifest.v1+json sha256:m1 7.5 MiB linux/amd64 io.cri-containerd.image=managed\n"
+		indexed = header + "docker.io/library/app:dev application/vnd.oci.image.index.v1+json sha256:i1 7.5 MiB linux/amd64 -\n"
+	)
+	newRuntime := func(list string) *testRuntime {
+		return &testRuntime{
+			nodes:   []runtime.ContainerInfo{{Name: "demo"}},
+			present: map[string]bool{"app:dev": true},
+			imageID: "sha256:c1",
+			image:   &imageStream{Reader: strings.NewReader("image tar")},
+			outs: map[string]string{
+				ls: list,
+				"k0s ctr -n k8s.io content get sha256:m1":                `{"config":{"digest":"sha256:c1"}}`,
+				"k0s ctr -n k8s.io content get sha256:i1":                `{"manifests":[{"digest":"sha256:m0"},{"digest":"sha256:m1"}]}`,
+				"k0s ctr -n k8s.io content get sha256:m0":                `{"config":{"digest":"sha256:c0"}}`,
+				"k0s ctr -n k8s.io images ls -q name==localhost/app:dev": "localhost/app:dev\n",
+			},
+		}
+	}
+
+	// The podman name of the image points to a manifest with the host image ID as config.
+	r := newRuntime(listed)
+	refs, loaded, err := LoadImage(context.Background(), r, "demo", "app:dev", false)
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, []string{"localhost/app:dev"}, refs)
+
+	// One manifest of an index has it.
+	r = newRuntime(indexed)
+	refs, loaded, err = LoadImage(context.Background(), r, "demo", "app:dev", false)
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, []string{"docker.io/library/app:dev"}, refs)
+
+	// With Docker's containerd image store the image ID is the target digest.
+	r = newRuntime(indexed)
+	r.imageID = "sha256:i1"
+	_, loaded, err = LoadImage(context.Background(), r, "demo", "app:dev", false)
+	require.NoError(t, err)
+	assert.False(t, loaded)
+
+	// An image replaced under the same name is loaded again, as is any image with force.
+	r = newRuntime(listed)
+	r.imageID = "sha256:c2"
+	refs, loaded, err = LoadImage(context.Background(), r, "demo", "app:dev", false)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, []string{"localhost/app:dev"}, refs)
+
+	r = newRuntime(listed)
+	_, loaded, err = LoadImage(context.Background(), r, "demo", "app:dev", true)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	for _, args := range r.execs {
+		assert.NotEqual(t, ls, strings.Join(args, " "), "force doesn't look at the node's images")
+	}
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// CreateToken mints a k0s join token for the given role (worker or controller) on the
+// cluster's primary node. A zero expiry keeps the k0s default.
+func CreateToken(ctx context.Context, r runtime.Runtime, clusterName, role string, expiry time.Duration) (string, error) {
+	if role != "worker" && role != "controller" {
+		return "", fmt.Errorf("invalid role %q (expected worker or controller)", role)
+	}
+	if expiry < 0 {
+		return "", fmt.Errorf("expiry must not be negative")
+	}
+	primary, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return "", err
+	}
+	return createJoinToken(ctx, r, primary, role, expiry)
+}
+
+// createJoinToken runs `k0s token create` in the given controller container.
+func createJoinToken(ctx context.Context, r runtime.Runtime, container, role string, expiry time.Duration) (string, error) {
+	args := []string{"k0s", "token", "create", "--role=" + role}
+	if expiry > 0 {
+		args = append(args, "--expiry="+expiry.String())
+	}
+	out, exit, err := r.ExecInContainer(ctx, container, args)
+	if err != nil || exit != 0 {
+		return "", fmt.Errorf("failed to create %s token on %s: %v %s", role, container, err, strings.TrimSpace(out))
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Token is a join token as listed by `k0s token list`.
+type Token struct {
+	ID      string `json:"id"`
+	Role    string `json:"role"`
+	Expires string `json:"expires"`
+}
+
+// ListTokens returns the outstanding join tokens of the cluster, optionally only those
+// of one role.
+func ListTokens(ctx context.Context, r runtime.Runtime, clusterName, role string) ([]Token, error) {
+	if role != "" && role != "worker" && role != "controller" {
+		return nil, fmt.Errorf("invalid role %q (expected worker or controller)", role)
+	}
+	primary, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"k0s", "token", "list"}
+	if role != "" {
+		args = append(args, "--role="+role)
+	}
+	out, exit, err := r.ExecInContainer(ctx, primary, args)
+	if err != nil || exit != 0 {
+		return nil, fmt.Errorf("failed to list tokens on %s: %v %s", primary, err, strings.TrimSpace(out))
+	}
+	return parseTokenList(out), nil
+}
+
+// InvalidateToken revokes the join tokens with the given IDs.
+func InvalidateToken(ctx context.Context, r runtime.Runtime, clusterName string, ids ...string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("at least one token ID is required")
+	}
+	primary, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return err
+	}
+	out, exit, err := r.ExecInContainer(ctx, primary, append([]string{"k0s", "token", "invalidate"}, ids...))
+	if err != nil || exit != 0 {
+		return fmt.Errorf("failed to invalidate tokens on %s: %v %s", primary, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// parseTokenList parses the table printed by `k0s token list`: a header line starting
+// with ID, then one token per line as ID, role and expiry. Table borders are ignored and
+// so is the message printed when there are no tokens.
+func parseTokenList(out string) []Token {
+	var tokens []Token
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "|", " "))
+		if line == "" || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] == "ID" {
+			continue
+		}
+		if fields[1] != "worker" && fields[1] != "controller" {
+			continue
+		}
+		tokens = append(tokens, Token{ID: fields[0], Role: fields[1], Expires: strings.Join(fields[2:], " ")})
+	}
+	return tokens
+}
@@ -0,0 +1,47 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateToken(t *testing.T) {
+	r := &testRuntime{nodes: []runtime.ContainerInfo{{Name: "dev-controller"}}, out: "H4sIAAAA\n"}
+	token, err := CreateToken(context.Background(), r, "dev", "controller", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "H4sIAAAA", token)
+	assert.Equal(t, "dev-controller", r.container)
+	assert.Equal(t, []string{"k0s", "token", "create", "--role=controller", "--expiry=1h0m0s"}, r.args)
+
+	_, err = CreateToken(context.Background(), r, "dev", "worker", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k0s", "token", "create", "--role=worker"}, r.args)
+
+	_, err = CreateToken(context.Background(), r, "dev", "admin", 0)
+	assert.ErrorContains(t, err, "invalid role")
+}
+
+func TestParseTokenList(t *testing.T) {
+	out := `ID       ROLE        EXPIRES AT
+5d6e7f   worker      2025-01-02 12:00:00 +0000 UTC
+a1b2c3   controller  2025-01-03 08:30:00 +0000 UTC
+`
+	assert.Equal(t, []Token{
+		{ID: "5d6e7f", Role: "worker", Expires: "2025-01-02 12:00:00 +0000 UTC"},
+		{ID: "a1b2c3", Role: "controller", Expires: "2025-01-03 08:30:00 +0000 UTC"},
+	}, parseTokenList(out))
+
+	bordered := `+--------+--------+----------------------+
+|   ID   |  ROLE  |      EXPIRES AT      |
++--------+--------+----------------------+
+| 5d6e7f | worker | 2025-01-02T12:00:00Z |
++--------+--------+----------------------+
+`
+	assert.Equal(t, []Token{{ID: "5d6e7f", Role: "worker", Expires: "2025-01-02T12:00:00Z"}}, parseTokenList(bordered))
+	assert.Empty(t, parseTokenList("No k0s join tokens found\n"))
+}
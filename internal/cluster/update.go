@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+	"github.com/makhov/k0da/internal/utils"
+)
+
+// UpdateOptions are the inputs of Update.
+type UpdateOptions struct {
+	Name   string
+	Config *k0daconfig.ClusterConfig
+}
+
+// Update rewrites the effective k0s config and the staged manifests of a running
+// cluster and applies the dynamic config on its primary node. k0s picks up manifest
+// changes on its own, so no node is restarted.
+func Update(ctx context.Context, r runtime.Runtime, opts UpdateOptions) error {
+	clusterName, cc := opts.Name, opts.Config
+
+	// Ensure cluster work dir exists
+	clusterDir := cc.ClusterDir(clusterName)
+	if err := os.MkdirAll(clusterDir, 0755); err != nil {
+		return fmt.Errorf("failed to create cluster directory: %w", err)
+	}
+
+	if err := utils.CopyManifestsToDir(cc, cc.ManifestDir(clusterName)); err != nil {
+		return fmt.Errorf("failed to stage manifests: %w", err)
+	}
+
+	if err := cc.WriteEffectiveK0sConfig(clusterName); err != nil {
+		return fmt.Errorf("failed to write effective k0s config: %w", err)
+	}
+	if err := cc.WriteWorkerK0sConfig(clusterName); err != nil {
+		return fmt.Errorf("failed to write worker k0s config: %w", err)
+	}
+	if err := cc.WriteRegistryAuthConfig(clusterName); err != nil {
+		return fmt.Errorf("failed to write registry auth config: %w", err)
+	}
+	if err := cc.WriteStoredConfig(clusterName); err != nil {
+		return fmt.Errorf("failed to store cluster config: %w", err)
+	}
+	// The config file should be mounted at /etc/k0s/k0s.yaml, so we can apply it directly
+	primary, err := PrimaryContainer(ctx, r, clusterName)
+	if err != nil {
+		return err
+	}
+	if out, exit, err := r.ExecInContainer(ctx, primary, []string{"k0s", "kc", "apply", "-f", "/etc/k0s/k0s.yaml"}); err != nil || exit != 0 {
+		return fmt.Errorf("failed to apply dynamic config via k0s: %v, out: %s", err, out)
+	}
+	return nil
+}
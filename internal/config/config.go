@@ -3,18 +3,32 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/imdario/mergo"
 	"gopkg.in/yaml.v3"
 
+	"github.com/makhov/k0da/internal/paths"
 	"github.com/makhov/k0da/internal/plugins"
 )
 
 const (
-	DefaultNetwork      = "k0da"
+	DefaultNetwork = "k0da"
+	// DefaultRestartPolicy keeps nodes running across runtime daemon and host restarts.
+	DefaultRestartPolicy = "always"
+	// DefaultPullPolicy pulls node images only when the runtime doesn't have them.
+	DefaultPullPolicy   = "missing"
 	DefaultK0sImageRepo = "quay.io/k0sproject/k0s"
+
+	// ExistingNetworkPrefix marks a network name as user-managed, e.g. "existing:shared".
+	// Such networks must already exist and are never created by k0da.
+	ExistingNetworkPrefix = "existing:"
 )
 
 // DefaultK0sVersion is the default k0s version tag used for images.
@@ -30,6 +44,17 @@ const (
 	LabelClusterType = "k0da.cluster.type"
 	LabelNodeName    = "k0da.node.name"
 	LabelNodeRole    = "k0da.node.role"
+	LabelNodePrimary = "k0da.node.primary"
+	// LabelNodeVolume names the volume holding a node's /var. Volumes can't be renamed, so
+	// it keeps its name when the node is.
+	LabelNodeVolume = "k0da.node.volume"
+	// LabelClusterLabels lists the keys of the user-defined cluster labels, comma separated,
+	// so they can be told apart from node labels.
+	LabelClusterLabels = "k0da.cluster.labels"
+	// LabelClusterExpires holds the RFC 3339 time after which `k0da gc` deletes the cluster.
+	LabelClusterExpires = "k0da.cluster.expires"
+	// ReservedLabelPrefix is the prefix of the labels k0da manages itself.
+	ReservedLabelPrefix = "k0da."
 )
 
 // ClusterConfig is a kind-like local cluster config aligned with k0s family style.
@@ -43,13 +68,85 @@ type ClusterConfig struct {
 }
 
 type Spec struct {
-	Nodes   []NodeSpec  `yaml:"nodes"`
-	K0s     K0sSpec     `yaml:"k0s"`
-	Options OptionsSpec `yaml:"options,omitempty"`
+	// Labels are applied to every node container, e.g. team: blue, to group clusters.
+	Labels  map[string]string `yaml:"labels,omitempty"`
+	Nodes   []NodeSpec        `yaml:"nodes"`
+	K0s     K0sSpec           `yaml:"k0s"`
+	Options OptionsSpec       `yaml:"options,omitempty"`
 }
 
 type OptionsSpec struct {
 	Network string `yaml:"network,omitempty"` // bridge network name, if empty, default "k0da" network will be used
+	// NetworkCreate controls whether the network is created when missing (default true).
+	// When false, the network must already exist.
+	NetworkCreate *bool `yaml:"networkCreate,omitempty"`
+	// NetworkSubnet is the CIDR of the cluster network, used when k0da creates it. It is
+	// required for static node IPs; for an existing network it must match its subnet.
+	NetworkSubnet string `yaml:"networkSubnet,omitempty"`
+	// ExposeDNS publishes the in-cluster DNS (CoreDNS) on the host so host tooling can resolve service names.
+	ExposeDNS bool `yaml:"exposeDNS,omitempty"`
+	// RestartPolicy is applied to all node containers: no|on-failure|unless-stopped|always (default always).
+	RestartPolicy string `yaml:"restartPolicy,omitempty"`
+	// PullPolicy decides when node images are pulled: missing|always|never (default missing).
+	PullPolicy string `yaml:"pullPolicy,omitempty"`
+	// Ulimits are extra resource limits for node containers, e.g. nofile: "1048576" or nofile: "65536:1048576".
+	// They are merged with the built-in unlimited memlock.
+	Ulimits map[string]string `yaml:"ulimits,omitempty"`
+	// Privileged runs node containers in privileged mode (default true). Turning it off is
+	// experimental: kubelet, kube-proxy and most CNIs need extra capabilities and device access.
+	Privileged *bool `yaml:"privileged,omitempty"`
+	// SecurityOpt overrides the default seccomp/apparmor/label options by key, e.g. "apparmor=docker-default".
+	SecurityOpt []string `yaml:"securityOpt,omitempty"`
+	// CapAdd and CapDrop adjust the Linux capabilities of node containers. Mostly useful together
+	// with privileged: false, see RecommendedCapabilities.
+	CapAdd  []string `yaml:"capAdd,omitempty"`
+	CapDrop []string `yaml:"capDrop,omitempty"`
+	// MountKernelModules bind-mounts the host's /lib/modules read-only into nodes. By default
+	// it is mounted only when the path exists on the host.
+	MountKernelModules *bool `yaml:"mountKernelModules,omitempty"`
+	// CgroupNS is the cgroup namespace of node containers: private (default) or host. With host
+	// the host's /sys/fs/cgroup is mounted writable and the cgroup v2 preflight check is skipped.
+	CgroupNS string `yaml:"cgroupns,omitempty"`
+	// Healthcheck has the runtime probe node containers with `k0s status`, so that
+	// `k0da list` shows whether k0s is healthy and not only whether the container runs.
+	Healthcheck bool `yaml:"healthcheck,omitempty"`
+	// APIServerAddress is the host or IP the API is reached at instead of 127.0.0.1, e.g.
+	// when the runtime runs on another machine. It is written to the kubeconfig and added
+	// to the SANs of the API server certificate.
+	APIServerAddress string `yaml:"apiServerAddress,omitempty"`
+	// MountRuntimeSocket bind-mounts the socket of the container runtime running the nodes
+	// into the primary controller, e.g. for a CI runner building images. Anything with
+	// access to it controls the runtime, and through it the host.
+	MountRuntimeSocket bool `yaml:"mountRuntimeSocket,omitempty"`
+	// Ingress installs an ingress controller, nginx or traefik, as a Helm chart and
+	// publishes its http and https ports from the primary controller (default none).
+	Ingress string `yaml:"ingress,omitempty"`
+	// MetricsServer keeps the metrics-server k0s deploys, so that `kubectl top` works. It
+	// is disabled by default to keep clusters small. Controllers read it when started.
+	MetricsServer bool `yaml:"metricsServer,omitempty"`
+}
+
+// RecommendedCapabilities is the capability set to start from when running k0s nodes
+// without privileged mode.
+var RecommendedCapabilities = []string{
+	"SYS_ADMIN",    // mounts, cgroups and namespaces for kubelet and containerd
+	"NET_ADMIN",    // iptables, routes and interfaces for kube-proxy and the CNI
+	"NET_RAW",      // raw sockets used by CNIs and health probes
+	"SYS_RESOURCE", // raising rlimits for system components
+	"SYS_PTRACE",   // inspecting processes in other namespaces
+	"IPC_LOCK",     // locking memory for eBPF maps
+	"BPF",          // loading eBPF programs
+	"PERFMON",      // attaching eBPF programs to tracepoints
+}
+
+// IsPrivileged reports whether node containers run privileged.
+func (o OptionsSpec) IsPrivileged() bool {
+	return o.Privileged == nil || *o.Privileged
+}
+
+// ShouldCreateNetwork reports whether k0da may create the cluster network if it is missing.
+func (o OptionsSpec) ShouldCreateNetwork() bool {
+	return o.NetworkCreate == nil || *o.NetworkCreate
 }
 
 type NodeSpec struct {
@@ -61,6 +158,22 @@ type NodeSpec struct {
 	Mounts []Mount           `yaml:"mounts,omitempty"`
 	Env    map[string]string `yaml:"env,omitempty"`
 	Labels map[string]string `yaml:"labels,omitempty"`
+	// Devices are host devices exposed to the node, e.g. "/dev/nvidia0" or "/dev/fuse:/dev/fuse:rwm".
+	Devices []string `yaml:"devices,omitempty"`
+	// Runtime selects an alternative OCI runtime for the node container, e.g. "nvidia".
+	Runtime string `yaml:"runtime,omitempty"`
+	// Hostname overrides the container hostname (defaults to the node name), e.g. an FQDN.
+	Hostname string `yaml:"hostname,omitempty"`
+	// DNS and DNSSearch set the node's resolvers and search domains.
+	DNS       []string `yaml:"dns,omitempty"`
+	DNSSearch []string `yaml:"dnsSearch,omitempty"`
+	// ExtraHosts adds /etc/hosts entries in "host:ip" form.
+	ExtraHosts []string `yaml:"extraHosts,omitempty"`
+	// Networks are container networks the node is connected to in addition to the
+	// cluster network, e.g. one shared with a database. Missing ones are created.
+	Networks []string `yaml:"networks,omitempty"`
+	// IP is a static IPv4 address on the cluster network, within options.networkSubnet.
+	IP string `yaml:"ip,omitempty"`
 }
 
 type Port struct {
@@ -78,27 +191,165 @@ type Mount struct {
 }
 
 type K0sSpec struct {
-	Image     string         `yaml:"image,omitempty"`
-	Version   string         `yaml:"version,omitempty"`
-	Config    map[string]any `yaml:"config,omitempty"`
-	Args      []string       `yaml:"args,omitempty"`
-	Manifests []string       `yaml:"manifests,omitempty"`
+	Image   string         `yaml:"image,omitempty"`
+	Version string         `yaml:"version,omitempty"`
+	Config  map[string]any `yaml:"config,omitempty"`
+	// WorkerConfig is a k0s config rendered as is and mounted at /etc/k0s/k0s.yaml on
+	// worker nodes only, e.g. for worker-side containerd or kubelet settings. Controllers,
+	// including those running a worker, keep using Config.
+	WorkerConfig map[string]any `yaml:"workerConfig,omitempty"`
+	// JoinToken is a worker join token of an externally running k0s controller. When set,
+	// all nodes must be workers and they are joined to that controller instead of a local one.
+	JoinToken string `yaml:"joinToken,omitempty"`
+	// Server overrides the API address embedded in JoinToken, e.g. when the controller is
+	// reachable from the node containers under another address than it advertises.
+	Server string `yaml:"server,omitempty"`
+	// PodCIDR and ServiceCIDR set spec.network.podCIDR and spec.network.serviceCIDR of the
+	// effective k0s config, taking precedence over the same keys in Config.
+	PodCIDR     string `yaml:"podCIDR,omitempty"`
+	ServiceCIDR string `yaml:"serviceCIDR,omitempty"`
+	// CNI sets spec.network.provider of the effective k0s config: kuberouter, calico or custom.
+	// With custom, k0s deploys no CNI and one has to be applied, e.g. via Manifests.
+	CNI string `yaml:"cni,omitempty"`
+	// DisableKubeProxy sets spec.network.kubeProxy.disabled in the effective k0s config, for
+	// CNIs that replace kube-proxy such as Cilium.
+	DisableKubeProxy bool       `yaml:"disableKubeProxy,omitempty"`
+	Args             []string   `yaml:"args,omitempty"`
+	Manifests        []Manifest `yaml:"manifests,omitempty"`
+	// ImageBundles are image tar archives (paths or URLs) mounted into /var/lib/k0s/images on
+	// every node, from where k0s imports them on start, e.g. for air-gapped clusters.
+	ImageBundles []string `yaml:"imageBundles,omitempty"`
+	// RegistryAuth are credentials the nodes' containerd uses to pull from private registries.
+	RegistryAuth []RegistryAuth `yaml:"registryAuth,omitempty"`
+	// HelmCharts are installed by k0s; they are added to spec.extensions.helm of the
+	// effective k0s config.
+	HelmCharts []HelmChart `yaml:"helmCharts,omitempty"`
+}
+
+// HelmChart is a Helm release k0s installs and keeps up to date.
+type HelmChart struct {
+	// Name is the release name.
+	Name string `yaml:"name"`
+	// ChartName is "<repo>/<chart>", or an oci:// reference.
+	ChartName string `yaml:"chartName"`
+	// Repository is the URL of the repository ChartName refers to. It can be omitted when
+	// the repository is declared in spec.extensions.helm of the k0s config.
+	Repository string         `yaml:"repository,omitempty"`
+	Version    string         `yaml:"version,omitempty"`
+	Namespace  string         `yaml:"namespace"`
+	Values     map[string]any `yaml:"values,omitempty"`
+}
+
+// namespacePattern matches Kubernetes namespace names (RFC 1123 labels).
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Manifest is an entry of k0s.manifests: a file path or URL, written either as a plain
+// string or as an object with options.
+type Manifest struct {
+	Path string `yaml:"path"`
+	// Namespace is set on the namespaced objects of the manifest that don't declare one.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Order sorts the staged manifests, lowest first; manifests with the same order
+	// sort by their staged name. It is kept out of the list position so that inserting
+	// a manifest doesn't rename the others.
+	Order int `yaml:"order,omitempty"`
+}
+
+// maxManifestOrder is the largest Order, which keeps the zero-padded prefix of the
+// staged names sortable.
+const maxManifestOrder = 999
+
+// UnmarshalYAML accepts both a plain path and a {path, namespace, order} object.
+func (m *Manifest) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*m = Manifest{Path: node.Value}
+		return nil
+	}
+	type plain Manifest
+	return node.Decode((*plain)(m))
+}
+
+// MarshalYAML writes manifests without options as plain paths, the format older
+// versions read.
+func (m Manifest) MarshalYAML() (any, error) {
+	if m.Namespace == "" && m.Order == 0 {
+		return m.Path, nil
+	}
+	type plain Manifest
+	return plain(m), nil
+}
+
+// RegistryAuth holds the credentials for one registry host, e.g. registry.corp.internal:5000.
+type RegistryAuth struct {
+	Registry string `yaml:"registry"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// LoadOptions tweak how a cluster config file is read.
+type LoadOptions struct {
+	// ExpandEnv expands ${VAR} and ${VAR:-default} references in the raw file before
+	// it is parsed, so any field can be parameterized from the environment.
+	ExpandEnv bool
+	// Set holds "dotted.path=value" overrides applied on top of the file before validation,
+	// e.g. "spec.k0s.version=v1.34.0-k0s.0". Only scalars under spec.k0s and spec.options can be set.
+	Set []string
 }
 
 // LoadClusterConfig loads a cluster config from the given path.
 // If path is empty, returns a default config.
 // Always returns a valid config with validation applied.
 func LoadClusterConfig(path string) (*ClusterConfig, error) {
-	var c ClusterConfig
+	return LoadClusterConfigWithOptions(path, LoadOptions{})
+}
 
+// LoadClusterConfigWithOptions is LoadClusterConfig with explicit load options.
+func LoadClusterConfigWithOptions(path string, opts LoadOptions) (*ClusterConfig, error) {
+	var data []byte
 	if path != "" {
-		data, err := os.ReadFile(path)
+		var err error
+		data, err = os.ReadFile(path)
 		if err != nil {
 			return nil, fmt.Errorf("read cluster config: %w", err)
 		}
-		if err := yaml.Unmarshal(data, &c); err != nil {
-			return nil, fmt.Errorf("parse cluster config: %w", err)
+	}
+	return parseClusterConfig(data, path, opts)
+}
+
+// ParseClusterConfig is LoadClusterConfigWithOptions for a config that is not read
+// from a file. Relative manifest paths resolve against the working directory.
+func ParseClusterConfig(data []byte, opts LoadOptions) (*ClusterConfig, error) {
+	return parseClusterConfig(data, "", opts)
+}
+
+func parseClusterConfig(data []byte, path string, opts LoadOptions) (*ClusterConfig, error) {
+	var c ClusterConfig
+
+	if opts.ExpandEnv {
+		data = []byte(ExpandEnv(string(data)))
+	}
+	// Work on the generic document first so that older schemas can be upconverted
+	// and --set overrides applied before the typed parse.
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse cluster config: %w", err)
+	}
+	if err := Migrate(raw); err != nil {
+		return nil, err
+	}
+	for _, expr := range opts.Set {
+		if err := ApplySet(raw, expr); err != nil {
+			return nil, fmt.Errorf("--set %s: %w", expr, err)
 		}
+	}
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cluster config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cluster config: %w", err)
+	}
+	if path != "" {
 		// Remember the source path for resolving relative references (e.g., manifests)
 		c.SourcePath = path
 	}
@@ -110,7 +361,9 @@ func LoadClusterConfig(path string) (*ClusterConfig, error) {
 	}
 
 	// Add plugin manifests to the config
-	c.Spec.K0s.Manifests = append(c.Spec.K0s.Manifests, pluginPaths...)
+	for _, p := range pluginPaths {
+		c.Spec.K0s.Manifests = append(c.Spec.K0s.Manifests, Manifest{Path: p})
+	}
 
 	// Apply defaults and validate
 	if err := c.Validate(); err != nil {
@@ -120,22 +373,108 @@ func LoadClusterConfig(path string) (*ClusterConfig, error) {
 	return &c, nil
 }
 
+// settablePrefixes are the config subtrees --set may write into.
+var settablePrefixes = []string{"spec.k0s.", "spec.options."}
+
+// ApplySet sets a scalar at a dotted path in a parsed YAML document, creating
+// intermediate maps as needed. The value is kept as a YAML scalar and only gets its type
+// from the field it lands in, so version=1.30 stays "1.30" in a string field while
+// exposeDNS=true becomes a bool. Quote the value, e.g. key="1.30", to force a string
+// in untyped parts such as spec.k0s.config.
+func ApplySet(doc map[string]any, expr string) error {
+	path, value, ok := strings.Cut(expr, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return fmt.Errorf("expected key=value")
+	}
+	allowed := false
+	for _, p := range settablePrefixes {
+		if strings.HasPrefix(path, p) && len(path) > len(p) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("only keys under spec.k0s and spec.options can be set")
+	}
+
+	parsed, err := parseSetValue(value)
+	if err != nil {
+		return err
+	}
+
+	keys := strings.Split(path, ".")
+	cur := doc
+	for _, k := range keys[:len(keys)-1] {
+		if k == "" {
+			return fmt.Errorf("empty path segment")
+		}
+		next, ok := cur[k].(map[string]any)
+		if !ok {
+			if existing, exists := cur[k]; exists && existing != nil {
+				return fmt.Errorf("%s is not a map", k)
+			}
+			next = map[string]any{}
+			cur[k] = next
+		}
+		cur = next
+	}
+	cur[keys[len(keys)-1]] = parsed
+	return nil
+}
+
+// parseSetValue parses a --set value into a scalar node. Plain scalars lose their
+// resolved tag so that the typed parse decides; quoted ones stay strings.
+func parseSetValue(value string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, fmt.Errorf("parse value: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+	}
+	n := doc.Content[0]
+	if n.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("only scalar values can be set")
+	}
+	if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+		n.Tag = ""
+	}
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	return n, nil
+}
+
+// ExpandEnv replaces ${VAR} and $VAR with the environment value and ${VAR:-default}
+// with the default when VAR is unset or empty.
+func ExpandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if key, def, ok := strings.Cut(name, ":-"); ok {
+			if v := os.Getenv(key); v != "" {
+				return v
+			}
+			return def
+		}
+		return os.Getenv(name)
+	})
+}
+
 func (c *ClusterConfig) Validate() error {
 	// Set defaults for empty configs
 	if c.Kind == "" {
 		c.Kind = "Cluster"
 	}
 	if c.APIVersion == "" {
-		c.APIVersion = "k0da.k0sproject.io/v1alpha1"
+		c.APIVersion = APIVersion
 	}
+	c.APIVersion = canonicalAPIVersion(c.APIVersion)
 
 	// Validate kind
 	if c.Kind != "Cluster" {
 		return fmt.Errorf("unsupported kind: %q (expected Cluster)", c.Kind)
 	}
-	// apiVersion is informational for now; accept empty or v1alpha1
-	if c.APIVersion != "k0da.k0sproject.io/v1alpha1" {
-		return fmt.Errorf("unsupported apiVersion: %q", c.APIVersion)
+	// Older apiVersions are upconverted by Migrate while loading
+	if c.APIVersion != APIVersion {
+		return unsupportedAPIVersionError(c.APIVersion)
 	}
 	if c.Spec.K0s.Image != "" && len(c.Spec.K0s.Image) < 3 {
 		return fmt.Errorf("invalid k0s.image")
@@ -145,13 +484,238 @@ func (c *ClusterConfig) Validate() error {
 			return fmt.Errorf("node role is required")
 		}
 	}
+	for field, v := range map[string]string{"k0s.podCIDR": c.Spec.K0s.PodCIDR, "k0s.serviceCIDR": c.Spec.K0s.ServiceCIDR} {
+		if v == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(v); err != nil {
+			return fmt.Errorf("%s: invalid CIDR %q", field, v)
+		}
+	}
+	switch c.Spec.K0s.CNI {
+	case "", "kuberouter", "calico", "custom":
+	default:
+		return fmt.Errorf("k0s.cni: unsupported value %q (expected kuberouter, calico or custom)", c.Spec.K0s.CNI)
+	}
+	for i, m := range c.Spec.K0s.Manifests {
+		if m.Namespace == "" && m.Order == 0 {
+			continue
+		}
+		if strings.TrimSpace(m.Path) == "" {
+			return fmt.Errorf("k0s.manifests[%d]: path is required", i)
+		}
+		if m.Order < 0 || m.Order > maxManifestOrder {
+			return fmt.Errorf("k0s.manifests[%d].order: %d is out of range (0-%d)", i, m.Order, maxManifestOrder)
+		}
+		if m.Namespace != "" && (len(m.Namespace) > 63 || !namespacePattern.MatchString(m.Namespace)) {
+			return fmt.Errorf("k0s.manifests[%d].namespace: %q is not a valid namespace name", i, m.Namespace)
+		}
+	}
+	for i, h := range c.Spec.K0s.HelmCharts {
+		if h.Name == "" || h.ChartName == "" || h.Namespace == "" {
+			return fmt.Errorf("k0s.helmCharts[%d]: name, chartName and namespace are required", i)
+		}
+		if h.Repository != "" && (strings.HasPrefix(h.ChartName, "oci://") || !strings.Contains(h.ChartName, "/")) {
+			return fmt.Errorf("k0s.helmCharts[%d].chartName: %q must be <repo>/<chart> when repository is set", i, h.ChartName)
+		}
+	}
+	switch c.Spec.Options.Ingress {
+	case "", IngressNone, IngressNginx, IngressTraefik:
+	default:
+		return fmt.Errorf("options.ingress: unsupported value %q (expected nginx, traefik or none)", c.Spec.Options.Ingress)
+	}
+	for i, a := range c.Spec.K0s.RegistryAuth {
+		if strings.TrimSpace(a.Registry) == "" || a.Username == "" || a.Password == "" {
+			return fmt.Errorf("k0s.registryAuth[%d]: registry, username and password are required", i)
+		}
+	}
+	if c.Spec.K0s.Server != "" && c.Spec.K0s.JoinToken == "" {
+		return fmt.Errorf("k0s.joinToken is required when k0s.server is set")
+	}
+	if c.ExternalControlPlane() {
+		if c.Spec.Options.IngressEnabled() {
+			return fmt.Errorf("options.ingress is not supported when joining an external controller")
+		}
+		if len(c.Spec.Nodes) == 0 {
+			return fmt.Errorf("k0s.joinToken requires at least one worker node")
+		}
+		for _, n := range c.Spec.Nodes {
+			if n.Role != "worker" {
+				return fmt.Errorf("k0s.joinToken joins nodes to an external controller, node role must be worker (got %q)", n.Role)
+			}
+		}
+	}
+	if strings.HasPrefix(c.Spec.Options.Network, ExistingNetworkPrefix) {
+		c.Spec.Options.Network = strings.TrimSpace(strings.TrimPrefix(c.Spec.Options.Network, ExistingNetworkPrefix))
+		if c.Spec.Options.Network == "" {
+			return fmt.Errorf("options.network: network name is required after %q", ExistingNetworkPrefix)
+		}
+		create := false
+		c.Spec.Options.NetworkCreate = &create
+	}
 	if c.Spec.Options.Network == "" {
+		if !c.Spec.Options.ShouldCreateNetwork() {
+			return fmt.Errorf("options.network is required when networkCreate is false")
+		}
 		c.Spec.Options.Network = DefaultNetwork
 	}
+	for i, n := range c.Spec.Nodes {
+		for _, nw := range n.Networks {
+			if strings.TrimSpace(nw) == "" {
+				return fmt.Errorf("nodes[%d].networks: empty network name", i)
+			}
+			if nw == c.Spec.Options.Network {
+				return fmt.Errorf("nodes[%d].networks: %q is already the cluster network", i, nw)
+			}
+		}
+	}
+	if s := c.Spec.Options.NetworkSubnet; s != "" {
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			return fmt.Errorf("options.networkSubnet: invalid CIDR %q", s)
+		}
+	}
+	if err := c.validateNodeIPs(); err != nil {
+		return err
+	}
+	for name, v := range c.Spec.Options.Ulimits {
+		if _, _, err := ParseUlimit(v); err != nil {
+			return fmt.Errorf("options.ulimits.%s: %w", name, err)
+		}
+	}
+	switch c.Spec.Options.RestartPolicy {
+	case "":
+		c.Spec.Options.RestartPolicy = DefaultRestartPolicy
+	case "no", "on-failure", "unless-stopped", "always":
+	default:
+		return fmt.Errorf("options.restartPolicy: unsupported value %q (expected no, on-failure, unless-stopped or always)", c.Spec.Options.RestartPolicy)
+	}
+	switch c.Spec.Options.PullPolicy {
+	case "":
+		c.Spec.Options.PullPolicy = DefaultPullPolicy
+	case "missing", "always", "never":
+	default:
+		return fmt.Errorf("options.pullPolicy: unsupported value %q (expected missing, always or never)", c.Spec.Options.PullPolicy)
+	}
+	for k := range c.Spec.Labels {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("labels: empty label key")
+		}
+		if strings.HasPrefix(k, ReservedLabelPrefix) {
+			return fmt.Errorf("labels.%s: the %s prefix is reserved for k0da", k, ReservedLabelPrefix)
+		}
+	}
+	switch c.Spec.Options.CgroupNS {
+	case "", "private", "host":
+	default:
+		return fmt.Errorf("options.cgroupns: unsupported value %q (expected private or host)", c.Spec.Options.CgroupNS)
+	}
+	if a := c.Spec.Options.APIServerAddress; a != "" && (strings.ContainsAny(a, "/ ") || net.ParseIP(a) == nil && strings.Contains(a, ":")) {
+		return fmt.Errorf("options.apiServerAddress: %q must be a host name or IP address without scheme or port", a)
+	}
 
 	return nil
 }
 
+// validateNodeIPs checks that static node IPs are distinct IPv4 host addresses in
+// options.networkSubnet, so a clash fails before any container is started. The first
+// host address is left to the gateway the runtime assigns the network.
+func (c *ClusterConfig) validateNodeIPs() error {
+	var subnet *net.IPNet
+	if c.Spec.Options.NetworkSubnet != "" {
+		_, subnet, _ = net.ParseCIDR(c.Spec.Options.NetworkSubnet)
+	}
+	used := map[string]int{}
+	for i, n := range c.Spec.Nodes {
+		if n.IP == "" {
+			continue
+		}
+		ip := net.ParseIP(n.IP).To4()
+		if ip == nil {
+			return fmt.Errorf("nodes[%d].ip: %q is not an IPv4 address", i, n.IP)
+		}
+		if subnet == nil {
+			return fmt.Errorf("nodes[%d].ip: static IPs require options.networkSubnet to be set", i)
+		}
+		if !subnet.Contains(ip) || ip.Equal(subnet.IP) || ip.Equal(broadcastAddress(subnet)) {
+			return fmt.Errorf("nodes[%d].ip: %s is not a host address in %s", i, ip, subnet)
+		}
+		if ip.Equal(gatewayAddress(subnet)) {
+			return fmt.Errorf("nodes[%d].ip: %s is the gateway address of %s", i, ip, subnet)
+		}
+		if j, ok := used[ip.String()]; ok {
+			return fmt.Errorf("nodes[%d].ip: %s is already used by nodes[%d]", i, ip, j)
+		}
+		used[ip.String()] = i
+	}
+	return nil
+}
+
+// gatewayAddress returns the first host address of an IPv4 subnet, which runtimes give
+// the network gateway by default.
+func gatewayAddress(subnet *net.IPNet) net.IP {
+	ip := slices.Clone(subnet.IP.To4())
+	ip[3]++
+	return ip
+}
+
+// broadcastAddress returns the last address of an IPv4 subnet.
+func broadcastAddress(subnet *net.IPNet) net.IP {
+	ip := slices.Clone(subnet.IP.To4())
+	for i := range ip {
+		ip[i] |= ^subnet.Mask[len(subnet.Mask)-4+i]
+	}
+	return ip
+}
+
+// ParseUlimit parses a ulimit value in "limit" or "soft:hard" form. "unlimited" and -1 mean no limit.
+func ParseUlimit(v string) (soft, hard int64, err error) {
+	parse := func(s string) (int64, error) {
+		s = strings.TrimSpace(s)
+		if s == "unlimited" {
+			return -1, nil
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < -1 {
+			return 0, fmt.Errorf("invalid ulimit value %q (expected <limit> or <soft>:<hard>)", v)
+		}
+		return n, nil
+	}
+	parts := strings.Split(v, ":")
+	switch len(parts) {
+	case 1:
+		soft, err = parse(parts[0])
+		return soft, soft, err
+	case 2:
+		if soft, err = parse(parts[0]); err != nil {
+			return 0, 0, err
+		}
+		if hard, err = parse(parts[1]); err != nil {
+			return 0, 0, err
+		}
+		if hard != -1 && (soft == -1 || soft > hard) {
+			return 0, 0, fmt.Errorf("invalid ulimit value %q: soft limit exceeds hard limit", v)
+		}
+		return soft, hard, nil
+	default:
+		return 0, 0, fmt.Errorf("invalid ulimit value %q (expected <limit> or <soft>:<hard>)", v)
+	}
+}
+
+// PrimaryNodeName returns the container name of the primary node: its configured name,
+// or the cluster name when the node is unnamed.
+func (c *ClusterConfig) PrimaryNodeName(clusterName string) string {
+	if n := c.PickPrimaryNode(); n != nil && strings.TrimSpace(n.Name) != "" {
+		return strings.TrimSpace(n.Name)
+	}
+	return clusterName
+}
+
+// ExternalControlPlane reports whether the cluster only has worker nodes joined to an
+// externally running controller via spec.k0s.joinToken.
+func (c *ClusterConfig) ExternalControlPlane() bool {
+	return c != nil && strings.TrimSpace(c.Spec.K0s.JoinToken) != ""
+}
+
 // PickPrimaryNode returns the controller node if present, otherwise the first node.
 func (c *ClusterConfig) PickPrimaryNode() *NodeSpec {
 	if c == nil {
@@ -187,21 +751,69 @@ func (c *ClusterConfig) MaybeWriteInlineK0sConfig(dir string) (string, error) {
 	return p, nil
 }
 
+// ClusterDir is the state directory of the cluster, see paths.ClusterDir.
 func (c *ClusterConfig) ClusterDir(clusterName string) string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".k0da", "clusters", clusterName)
+	return paths.ClusterDir(clusterName)
 }
 
 func (c *ClusterConfig) ConfigDir(clusterName string) string {
-	return filepath.Join(c.ClusterDir(clusterName), "etc-k0s")
+	return paths.ConfigDir(clusterName)
 }
 
 func (c *ClusterConfig) ConfigPath(clusterName string) string {
-	return filepath.Join(c.ConfigDir(clusterName), "k0s.yaml")
+	return paths.ConfigPath(clusterName)
+}
+
+// WorkerConfigPath is the k0s config mounted into worker nodes when spec.k0s.workerConfig is set.
+func (c *ClusterConfig) WorkerConfigPath(clusterName string) string {
+	return paths.WorkerConfigPath(clusterName)
+}
+
+// RegistryAuthPath is the containerd config with the registry credentials, mounted into
+// every node when spec.k0s.registryAuth is set.
+func (c *ClusterConfig) RegistryAuthPath(clusterName string) string {
+	return paths.RegistryAuthPath(clusterName)
 }
 
 func (c *ClusterConfig) ManifestDir(clusterName string) string {
-	return filepath.Join(c.ClusterDir(clusterName), "manifests")
+	return paths.ManifestDir(clusterName)
+}
+
+// StoredConfigPath is where the cluster config used to create a cluster is kept.
+func (c *ClusterConfig) StoredConfigPath(clusterName string) string {
+	return paths.StoredConfigPath(clusterName)
+}
+
+// WriteStoredConfig saves the cluster config into the cluster directory so that it can be inspected later.
+func (c *ClusterConfig) WriteStoredConfig(clusterName string) error {
+	if err := os.MkdirAll(c.ClusterDir(clusterName), 0755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	// Keep registry passwords and the join token out of the stored copy, it is shown by
+	// 'k0da inspect'. A redacted token still marks the control plane as external.
+	stored := *c
+	stored.Spec.K0s.RegistryAuth = make([]RegistryAuth, len(c.Spec.K0s.RegistryAuth))
+	for i, a := range c.Spec.K0s.RegistryAuth {
+		a.Password = redacted
+		stored.Spec.K0s.RegistryAuth[i] = a
+	}
+	if stored.Spec.K0s.JoinToken != "" {
+		stored.Spec.K0s.JoinToken = redacted
+	}
+	data, err := yaml.Marshal(&stored)
+	if err != nil {
+		return fmt.Errorf("marshal cluster config: %w", err)
+	}
+	// The config can hold credentials, keep it private to the user. WriteFile keeps the
+	// mode of an existing file, so configs written by earlier versions are tightened too.
+	path := c.StoredConfigPath(clusterName)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write cluster config: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("write cluster config: %w", err)
+	}
+	return nil
 }
 
 // EffectiveImage returns the k0s image to use based on precedence:
@@ -228,26 +840,130 @@ func DefaultK0sConfig() map[string]any {
 	}
 }
 
-// EffectiveK0sConfig returns the merged k0s config: defaults overlaid with user-specified values.
+// EffectiveK0sConfig returns the merged k0s config: defaults overlaid with user-specified values,
+// then the convenience fields of the k0s section.
 func (c *ClusterConfig) EffectiveK0sConfig() map[string]any {
 	base := DefaultK0sConfig()
-	if c == nil || len(c.Spec.K0s.Config) == 0 {
+	if c == nil {
 		return base
 	}
+	baseSpec := base["spec"].(map[string]any)
 	// Merge user config into defaults; user values override defaults
-	spec, ok := c.Spec.K0s.Config["spec"]
-	if !ok {
-		return base
+	if spec, ok := c.Spec.K0s.Config["spec"]; ok {
+		if err := mergo.Merge(&baseSpec, spec.(map[string]any), mergo.WithOverride); err != nil {
+			// Fallback to internal deep merge on error
+			panic(fmt.Errorf("merge k0s config: %w", err))
+		}
 	}
-	baseSpec := base["spec"].(map[string]any)
-	if err := mergo.Merge(&baseSpec, spec.(map[string]any), mergo.WithOverride); err != nil {
-		// Fallback to internal deep merge on error
-		panic(fmt.Errorf("merge k0s config: %w", err))
+	if c.Spec.K0s.PodCIDR != "" {
+		baseSpec = setNested(baseSpec, c.Spec.K0s.PodCIDR, "network", "podCIDR")
+	}
+	if c.Spec.K0s.ServiceCIDR != "" {
+		baseSpec = setNested(baseSpec, c.Spec.K0s.ServiceCIDR, "network", "serviceCIDR")
+	}
+	if c.Spec.K0s.CNI != "" {
+		baseSpec = setNested(baseSpec, c.Spec.K0s.CNI, "network", "provider")
+	}
+	if c.Spec.K0s.DisableKubeProxy {
+		baseSpec = setNested(baseSpec, true, "network", "kubeProxy", "disabled")
+	}
+	if charts := c.helmCharts(); len(charts) > 0 {
+		baseSpec = withHelmCharts(baseSpec, charts)
+	}
+	if addr := c.Spec.Options.APIServerAddress; addr != "" {
+		api, _ := baseSpec["api"].(map[string]any)
+		sans, _ := api["sans"].([]any)
+		if !slices.Contains(sans, any(addr)) {
+			baseSpec = setNested(baseSpec, append(slices.Clone(sans), addr), "api", "sans")
+		}
 	}
 	base["spec"] = baseSpec
 	return base
 }
 
+// helmCharts returns k0s.helmCharts followed by the chart of the ingress controller
+// options.ingress selects.
+func (c *ClusterConfig) helmCharts() []HelmChart {
+	charts := c.Spec.K0s.HelmCharts
+	if ingress := c.Spec.Options.ingressChart(); ingress != nil {
+		charts = append(slices.Clone(charts), *ingress)
+	}
+	return charts
+}
+
+// withHelmCharts appends charts, and the repositories they name, to the charts and
+// repositories of spec.extensions.helm. Repositories already declared there win.
+func withHelmCharts(spec map[string]any, helmCharts []HelmChart) map[string]any {
+	extensions, _ := spec["extensions"].(map[string]any)
+	helm, _ := extensions["helm"].(map[string]any)
+	repositories, _ := helm["repositories"].([]any)
+	charts, _ := helm["charts"].([]any)
+	repositories, charts = slices.Clone(repositories), slices.Clone(charts)
+
+	declared := map[string]bool{}
+	for _, r := range repositories {
+		if m, ok := r.(map[string]any); ok {
+			name, _ := m["name"].(string)
+			declared[name] = true
+		}
+	}
+	for _, h := range helmCharts {
+		if repo, _, ok := strings.Cut(h.ChartName, "/"); ok && h.Repository != "" && !declared[repo] {
+			repositories = append(repositories, map[string]any{"name": repo, "url": h.Repository})
+			declared[repo] = true
+		}
+		chart := map[string]any{
+			"name":      h.Name,
+			"chartname": h.ChartName,
+			"namespace": h.Namespace,
+		}
+		if h.Version != "" {
+			chart["version"] = h.Version
+		}
+		if len(h.Values) > 0 {
+			// k0s takes the values as a YAML document in a string.
+			values, _ := yaml.Marshal(h.Values)
+			chart["values"] = string(values)
+		}
+		charts = append(charts, chart)
+	}
+	if len(repositories) > 0 {
+		spec = setNested(spec, repositories, "extensions", "helm", "repositories")
+	}
+	return setNested(spec, charts, "extensions", "helm", "charts")
+}
+
+// Warnings returns problems with the config that don't prevent creating a cluster.
+func (c *ClusterConfig) Warnings() []string {
+	var warnings []string
+	if c.Spec.K0s.DisableKubeProxy {
+		network, _ := c.EffectiveK0sConfig()["spec"].(map[string]any)["network"].(map[string]any)
+		if provider, _ := network["provider"].(string); provider == "" || provider == "kuberouter" {
+			warnings = append(warnings, "k0s.disableKubeProxy is set with the default kube-router CNI, which relies on kube-proxy for services; set k0s.cni to custom and deploy a kube-proxy replacement")
+		}
+	}
+	if c.Spec.Options.MountRuntimeSocket {
+		warnings = append(warnings, "options.mountRuntimeSocket is set: workloads on the controller can use the container runtime socket to control the runtime and the host")
+	}
+	return warnings
+}
+
+// setNested returns a copy of m with value set at the given key path. Maps along the
+// path are copied too, so maps shared with the user config are left untouched.
+func setNested(m map[string]any, value any, keys ...string) map[string]any {
+	out := make(map[string]any, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	if len(keys) == 1 {
+		out[keys[0]] = value
+		return out
+	}
+	child, _ := out[keys[0]].(map[string]any)
+	out[keys[0]] = setNested(child, value, keys[1:]...)
+	return out
+}
+
 // WriteEffectiveK0sConfig writes the effective k0s config (defaults merged with inline user config) to dir.
 func (c *ClusterConfig) WriteEffectiveK0sConfig(clusterName string) error {
 	dir := c.ConfigDir(clusterName)
@@ -263,3 +979,55 @@ func (c *ClusterConfig) WriteEffectiveK0sConfig(clusterName string) error {
 	}
 	return nil
 }
+
+// redacted replaces secrets in configs written for inspection.
+const redacted = "<redacted>"
+
+// WriteRegistryAuthConfig writes spec.k0s.registryAuth as a containerd config drop-in,
+// which k0s merges into the CRI plugin config of the node's containerd. The file is only
+// readable by the current user. It does nothing when no credentials are set.
+func (c *ClusterConfig) WriteRegistryAuthConfig(clusterName string) error {
+	if len(c.Spec.K0s.RegistryAuth) == 0 {
+		return nil
+	}
+	path := c.RegistryAuthPath(clusterName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(registryAuthTOML(c.Spec.K0s.RegistryAuth)), 0600); err != nil {
+		return fmt.Errorf("write registry auth config: %w", err)
+	}
+	return nil
+}
+
+// registryAuthTOML renders the credentials in containerd's version 2 config format.
+func registryAuthTOML(auths []RegistryAuth) string {
+	var b strings.Builder
+	b.WriteString("version = 2\n")
+	for _, a := range auths {
+		fmt.Fprintf(&b, "\n[plugins.\"io.containerd.grpc.v1.cri\".registry.configs.%s.auth]\n", strconv.Quote(strings.TrimSpace(a.Registry)))
+		fmt.Fprintf(&b, "  username = %s\n", strconv.Quote(a.Username))
+		fmt.Fprintf(&b, "  password = %s\n", strconv.Quote(a.Password))
+	}
+	return b.String()
+}
+
+// WriteWorkerK0sConfig writes spec.k0s.workerConfig for worker nodes. It does nothing
+// when no worker config is set.
+func (c *ClusterConfig) WriteWorkerK0sConfig(clusterName string) error {
+	if len(c.Spec.K0s.WorkerConfig) == 0 {
+		return nil
+	}
+	path := c.WorkerConfigPath(clusterName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+	data, err := yaml.Marshal(c.Spec.K0s.WorkerConfig)
+	if err != nil {
+		return fmt.Errorf("marshal worker k0s config: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write worker k0s config: %w", err)
+	}
+	return nil
+}
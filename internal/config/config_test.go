@@ -1,9 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestEffectiveK0sConfig_Default(t *testing.T) {
@@ -91,3 +94,463 @@ func TestEffectiveK0sConfig_OverrideDoesNotRemoveDefaultKeys(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, true, feat["flag"])
 }
+
+func TestEffectiveK0sConfig_NetworkCIDRs(t *testing.T) {
+	userNetwork := map[string]any{"podCIDR": "10.244.0.0/16", "provider": "calico"}
+	cc := &ClusterConfig{}
+	cc.Spec.K0s.Config = map[string]any{"spec": map[string]any{"network": userNetwork}}
+	cc.Spec.K0s.PodCIDR = "10.100.0.0/16"
+	cc.Spec.K0s.ServiceCIDR = "10.200.0.0/16"
+
+	network := cc.EffectiveK0sConfig()["spec"].(map[string]any)["network"].(map[string]any)
+	require.Equal(t, "10.100.0.0/16", network["podCIDR"])
+	require.Equal(t, "10.200.0.0/16", network["serviceCIDR"])
+	require.Equal(t, "calico", network["provider"])
+	// The user's config is not modified
+	require.Equal(t, "10.244.0.0/16", userNetwork["podCIDR"])
+
+	require.NoError(t, cc.Validate())
+	cc.Spec.K0s.ServiceCIDR = "10.200.0.0"
+	require.ErrorContains(t, cc.Validate(), "k0s.serviceCIDR")
+}
+
+func TestEffectiveK0sConfig_CNI(t *testing.T) {
+	cc := &ClusterConfig{}
+	cc.Spec.K0s.Config = map[string]any{"spec": map[string]any{"network": map[string]any{"provider": "kuberouter", "podCIDR": "10.244.0.0/16"}}}
+	cc.Spec.K0s.CNI = "custom"
+	require.NoError(t, cc.Validate())
+
+	network := cc.EffectiveK0sConfig()["spec"].(map[string]any)["network"].(map[string]any)
+	require.Equal(t, "custom", network["provider"])
+	require.Equal(t, "10.244.0.0/16", network["podCIDR"])
+
+	cc = &ClusterConfig{}
+	cc.Spec.K0s.CNI = "calico"
+	network = cc.EffectiveK0sConfig()["spec"].(map[string]any)["network"].(map[string]any)
+	require.Equal(t, map[string]any{"provider": "calico"}, network)
+
+	cc.Spec.K0s.CNI = "cilium"
+	require.ErrorContains(t, cc.Validate(), "k0s.cni")
+}
+
+func TestEffectiveK0sConfig_DisableKubeProxy(t *testing.T) {
+	cc := &ClusterConfig{}
+	cc.Spec.K0s.Config = map[string]any{"spec": map[string]any{"network": map[string]any{"kubeProxy": map[string]any{"mode": "ipvs"}}}}
+	require.Empty(t, cc.Warnings())
+
+	cc.Spec.K0s.DisableKubeProxy = true
+	kubeProxy := cc.EffectiveK0sConfig()["spec"].(map[string]any)["network"].(map[string]any)["kubeProxy"].(map[string]any)
+	require.Equal(t, true, kubeProxy["disabled"])
+	require.Equal(t, "ipvs", kubeProxy["mode"])
+	require.Len(t, cc.Warnings(), 1)
+
+	cc.Spec.K0s.CNI = "custom"
+	require.Empty(t, cc.Warnings())
+}
+
+func TestWriteWorkerK0sConfig(t *testing.T) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	cc := &ClusterConfig{}
+	require.NoError(t, cc.WriteWorkerK0sConfig("dev"))
+	_, err := os.Stat(cc.WorkerConfigPath("dev"))
+	require.True(t, os.IsNotExist(err))
+
+	cc.Spec.K0s.WorkerConfig = map[string]any{"spec": map[string]any{"workerProfiles": []any{}}}
+	require.NoError(t, cc.WriteWorkerK0sConfig("dev"))
+	data, err := os.ReadFile(cc.WorkerConfigPath("dev"))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "workerProfiles: []")
+	require.NotEqual(t, cc.ConfigPath("dev"), cc.WorkerConfigPath("dev"))
+}
+
+func TestRegistryAuth(t *testing.T) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	cc := &ClusterConfig{}
+	cc.Spec.K0s.RegistryAuth = []RegistryAuth{{Registry: "registry.corp:5000", Username: "bob", Password: `s3"cret`}}
+	require.NoError(t, cc.Validate())
+
+	require.NoError(t, cc.WriteRegistryAuthConfig("dev"))
+	data, err := os.ReadFile(cc.RegistryAuthPath("dev"))
+	require.NoError(t, err)
+	require.Equal(t, `version = 2
+
+[plugins."io.containerd.grpc.v1.cri".registry.configs."registry.corp:5000".auth]
+  username = "bob"
+  password = "s3\"cret"
+`, string(data))
+	info, err := os.Stat(cc.RegistryAuthPath("dev"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, cc.WriteStoredConfig("dev"))
+	stored, err := os.ReadFile(cc.StoredConfigPath("dev"))
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), "s3")
+	require.Contains(t, string(stored), "<redacted>")
+	require.Equal(t, `s3"cret`, cc.Spec.K0s.RegistryAuth[0].Password)
+	info, err = os.Stat(cc.StoredConfigPath("dev"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	cc.Spec.K0s.RegistryAuth[0].Username = ""
+	require.ErrorContains(t, cc.Validate(), "k0s.registryAuth[0]")
+}
+
+func TestValidate_ExternalControlPlane(t *testing.T) {
+	cc := &ClusterConfig{Spec: Spec{K0s: K0sSpec{JoinToken: "H4sI"}, Nodes: []NodeSpec{{Role: "worker"}, {Role: "worker"}}}}
+	require.NoError(t, cc.Validate())
+	require.True(t, cc.ExternalControlPlane())
+
+	cc = &ClusterConfig{Spec: Spec{K0s: K0sSpec{JoinToken: "H4sI"}, Nodes: []NodeSpec{{Role: "controller"}, {Role: "worker"}}}}
+	require.ErrorContains(t, cc.Validate(), "must be worker")
+
+	cc = &ClusterConfig{Spec: Spec{K0s: K0sSpec{JoinToken: "H4sI"}}}
+	require.ErrorContains(t, cc.Validate(), "at least one worker")
+
+	cc = &ClusterConfig{Spec: Spec{K0s: K0sSpec{Server: "https://10.0.0.1:6443"}, Nodes: []NodeSpec{{Role: "worker"}}}}
+	require.ErrorContains(t, cc.Validate(), "k0s.joinToken is required")
+}
+
+func TestWriteStoredConfig_RedactsJoinToken(t *testing.T) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	cc := &ClusterConfig{Spec: Spec{K0s: K0sSpec{JoinToken: "H4sIsecret", Server: "https://10.0.0.1:6443"}, Nodes: []NodeSpec{{Role: "worker"}}}}
+	require.NoError(t, cc.Validate())
+
+	require.NoError(t, cc.WriteStoredConfig("edge"))
+	stored, err := os.ReadFile(cc.StoredConfigPath("edge"))
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), "H4sIsecret")
+	require.Equal(t, "H4sIsecret", cc.Spec.K0s.JoinToken)
+
+	// The stored copy still loads as a cluster with an external control plane.
+	loaded, err := LoadClusterConfig(cc.StoredConfigPath("edge"))
+	require.NoError(t, err)
+	require.Equal(t, "<redacted>", loaded.Spec.K0s.JoinToken)
+	require.True(t, loaded.ExternalControlPlane())
+}
+
+func TestValidate_ExistingNetwork(t *testing.T) {
+	cc := &ClusterConfig{}
+	cc.Spec.Options.Network = "existing:shared"
+	require.NoError(t, cc.Validate())
+	require.Equal(t, "shared", cc.Spec.Options.Network)
+	require.False(t, cc.Spec.Options.ShouldCreateNetwork())
+
+	def := &ClusterConfig{}
+	require.NoError(t, def.Validate())
+	require.Equal(t, DefaultNetwork, def.Spec.Options.Network)
+	require.True(t, def.Spec.Options.ShouldCreateNetwork())
+
+	bad := &ClusterConfig{}
+	bad.Spec.Options.Network = "existing:"
+	require.Error(t, bad.Validate())
+}
+
+func TestValidate_RestartPolicy(t *testing.T) {
+	def := &ClusterConfig{}
+	require.NoError(t, def.Validate())
+	require.Equal(t, DefaultRestartPolicy, def.Spec.Options.RestartPolicy)
+
+	cc := &ClusterConfig{}
+	cc.Spec.Options.RestartPolicy = "unless-stopped"
+	require.NoError(t, cc.Validate())
+	require.Equal(t, "unless-stopped", cc.Spec.Options.RestartPolicy)
+
+	bad := &ClusterConfig{}
+	bad.Spec.Options.RestartPolicy = "sometimes"
+	require.Error(t, bad.Validate())
+}
+
+func TestParseUlimit(t *testing.T) {
+	soft, hard, err := ParseUlimit("1048576")
+	require.NoError(t, err)
+	require.Equal(t, int64(1048576), soft)
+	require.Equal(t, int64(1048576), hard)
+
+	soft, hard, err = ParseUlimit("65536:1048576")
+	require.NoError(t, err)
+	require.Equal(t, int64(65536), soft)
+	require.Equal(t, int64(1048576), hard)
+
+	soft, hard, err = ParseUlimit("unlimited")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), soft)
+	require.Equal(t, int64(-1), hard)
+
+	for _, bad := range []string{"", "lots", "1:2:3", "10:5", "-2"} {
+		_, _, err := ParseUlimit(bad)
+		require.Error(t, err, bad)
+	}
+
+	cc := &ClusterConfig{}
+	cc.Spec.Options.Ulimits = map[string]string{"nofile": "many"}
+	require.ErrorContains(t, cc.Validate(), "options.ulimits.nofile")
+}
+
+func TestOptions_IsPrivileged(t *testing.T) {
+	require.True(t, OptionsSpec{}.IsPrivileged())
+	off := false
+	require.False(t, OptionsSpec{Privileged: &off}.IsPrivileged())
+}
+
+func TestPrimaryNodeName(t *testing.T) {
+	unnamed := &ClusterConfig{Spec: Spec{Nodes: []NodeSpec{{Role: "controller"}}}}
+	require.Equal(t, "demo", unnamed.PrimaryNodeName("demo"))
+
+	named := &ClusterConfig{Spec: Spec{Nodes: []NodeSpec{
+		{Name: "w0", Role: "worker"},
+		{Name: "my-ctrl", Role: "controller"},
+	}}}
+	require.Equal(t, "my-ctrl", named.PrimaryNodeName("demo"))
+
+	empty := &ClusterConfig{}
+	require.Equal(t, "demo", empty.PrimaryNodeName("demo"))
+}
+
+func TestLoadClusterConfig_ExpandEnv(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "cluster.yaml")
+	require.NoError(t, os.WriteFile(p, []byte(`apiVersion: k0da.k0sproject.io/v1alpha1
+kind: Cluster
+spec:
+  k0s:
+    version: ${K0DA_TEST_VERSION}
+    image: ${K0DA_TEST_REGISTRY:-quay.io}/k0sproject/k0s
+`), 0644))
+	t.Setenv("K0DA_HOME", t.TempDir())
+	t.Setenv("K0DA_TEST_VERSION", "v1.34.0-k0s.0")
+
+	cc, err := LoadClusterConfigWithOptions(p, LoadOptions{ExpandEnv: true})
+	require.NoError(t, err)
+	require.Equal(t, "v1.34.0-k0s.0", cc.Spec.K0s.Version)
+	require.Equal(t, "quay.io/k0sproject/k0s", cc.Spec.K0s.Image)
+
+	// Without the flag references are kept verbatim.
+	cc, err = LoadClusterConfig(p)
+	require.NoError(t, err)
+	require.Equal(t, "${K0DA_TEST_VERSION}", cc.Spec.K0s.Version)
+}
+
+func TestApplySet(t *testing.T) {
+	doc := map[string]any{"spec": map[string]any{"k0s": map[string]any{"version": "v1.33.3-k0s.0"}}}
+	require.NoError(t, ApplySet(doc, "spec.k0s.version=v1.34.0-k0s.0"))
+	require.NoError(t, ApplySet(doc, "spec.options.exposeDNS=true"))
+	require.NoError(t, ApplySet(doc, "spec.k0s.config.spec.network.provider=calico"))
+	require.NoError(t, ApplySet(doc, `spec.k0s.config.spec.api.extraArgs.v="2"`))
+	require.NoError(t, ApplySet(doc, "spec.k0s.config.spec.api.port=6443"))
+	data, err := yaml.Marshal(doc)
+	require.NoError(t, err)
+	var parsed struct {
+		Spec struct {
+			K0s struct {
+				Version string         `yaml:"version"`
+				Config  map[string]any `yaml:"config"`
+			} `yaml:"k0s"`
+			Options struct {
+				ExposeDNS bool `yaml:"exposeDNS"`
+			} `yaml:"options"`
+		} `yaml:"spec"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &parsed))
+	require.Equal(t, "v1.34.0-k0s.0", parsed.Spec.K0s.Version)
+	require.True(t, parsed.Spec.Options.ExposeDNS)
+	api := parsed.Spec.K0s.Config["spec"].(map[string]any)["api"].(map[string]any)
+	require.Equal(t, "2", api["extraArgs"].(map[string]any)["v"])
+	require.Equal(t, 6443, api["port"])
+
+	require.Error(t, ApplySet(doc, "spec.nodes=[]"))
+	require.Error(t, ApplySet(doc, "kind=Other"))
+	require.Error(t, ApplySet(doc, "spec.options.network"))
+	require.Error(t, ApplySet(doc, "spec.k0s.args=[--debug]"))
+	require.Error(t, ApplySet(doc, "spec.k0s.version.major=1"))
+}
+
+func TestLoadClusterConfig_Set(t *testing.T) {
+	t.Setenv("K0DA_HOME", t.TempDir())
+	cc, err := LoadClusterConfigWithOptions("", LoadOptions{Set: []string{
+		"spec.k0s.version=v1.34.0-k0s.0",
+		"spec.options.network=ci",
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "v1.34.0-k0s.0", cc.Spec.K0s.Version)
+	require.Equal(t, "ci", cc.Spec.Options.Network)
+
+	// Number-like values keep their text in string fields.
+	cc, err = LoadClusterConfigWithOptions("", LoadOptions{Set: []string{"spec.k0s.version=1.30"}})
+	require.NoError(t, err)
+	require.Equal(t, "1.30", cc.Spec.K0s.Version)
+}
+
+func TestMigrate(t *testing.T) {
+	// Aliases of the current version are normalized.
+	doc := map[string]any{"apiVersion": "v1alpha1"}
+	require.NoError(t, Migrate(doc))
+	require.Equal(t, APIVersion, doc["apiVersion"])
+
+	// Older versions are upconverted by chaining registered steps.
+	migrations["k0da.k0sproject.io/v1alpha0"] = migration{to: APIVersion, apply: func(doc map[string]any) error {
+		spec := doc["spec"].(map[string]any)
+		spec["options"] = map[string]any{"network": spec["network"]}
+		delete(spec, "network")
+		return nil
+	}}
+	t.Cleanup(func() { delete(migrations, "k0da.k0sproject.io/v1alpha0") })
+	doc = map[string]any{"apiVersion": "k0da.k0sproject.io/v1alpha0", "spec": map[string]any{"network": "legacy"}}
+	require.NoError(t, Migrate(doc))
+	require.Equal(t, APIVersion, doc["apiVersion"])
+	require.Equal(t, map[string]any{"options": map[string]any{"network": "legacy"}}, doc["spec"])
+
+	err := Migrate(map[string]any{"apiVersion": "k0da.k0sproject.io/v9"})
+	require.ErrorContains(t, err, `unsupported apiVersion: "k0da.k0sproject.io/v9"`)
+	require.ErrorContains(t, err, APIVersion)
+
+	require.NoError(t, Migrate(map[string]any{}))
+}
+
+func TestAPIServerAddress(t *testing.T) {
+	cc := &ClusterConfig{}
+	cc.Spec.K0s.Config = map[string]any{"spec": map[string]any{"api": map[string]any{"sans": []any{"k0s.example.com"}}}}
+	cc.Spec.Options.APIServerAddress = "192.168.1.20"
+	require.NoError(t, cc.Validate())
+	api := cc.EffectiveK0sConfig()["spec"].(map[string]any)["api"].(map[string]any)
+	require.Equal(t, []any{"k0s.example.com", "192.168.1.20"}, api["sans"])
+	// The user's config is left alone.
+	require.Len(t, cc.Spec.K0s.Config["spec"].(map[string]any)["api"].(map[string]any)["sans"], 1)
+
+	for _, bad := range []string{"https://host", "host:6443", "host/path"} {
+		cc.Spec.Options.APIServerAddress = bad
+		require.Error(t, cc.Validate(), bad)
+	}
+	cc.Spec.Options.APIServerAddress = "fd00::20"
+	require.NoError(t, cc.Validate())
+}
+
+func TestValidateNodeNetworks(t *testing.T) {
+	cc := &ClusterConfig{Kind: "Cluster", APIVersion: APIVersion}
+	cc.Spec.Nodes = []NodeSpec{{Role: "controller", Networks: []string{"shared-db"}}}
+	require.NoError(t, cc.Validate())
+
+	cc.Spec.Nodes[0].Networks = []string{DefaultNetwork}
+	require.ErrorContains(t, cc.Validate(), "already the cluster network")
+
+	cc.Spec.Nodes[0].Networks = []string{" "}
+	require.ErrorContains(t, cc.Validate(), "empty network name")
+}
+
+func TestValidateNodeIPs(t *testing.T) {
+	cc := &ClusterConfig{Kind: "Cluster", APIVersion: APIVersion}
+	cc.Spec.Nodes = []NodeSpec{{Role: "controller", IP: "172.30.0.10"}, {Role: "worker", IP: "172.30.0.11"}}
+	require.ErrorContains(t, cc.Validate(), "require options.networkSubnet")
+
+	cc.Spec.Options.NetworkSubnet = "172.30.0.0/24"
+	require.NoError(t, cc.Validate())
+
+	cc.Spec.Nodes[1].IP = "172.30.0.10"
+	require.ErrorContains(t, cc.Validate(), "already used by nodes[0]")
+
+	for _, bad := range []string{"172.31.0.10", "172.30.0.0", "172.30.0.1", "172.30.0.255", "fd00::10", "node-1"} {
+		cc.Spec.Nodes[1].IP = bad
+		require.Error(t, cc.Validate(), bad)
+	}
+
+	cc.Spec.Nodes[1].IP = ""
+	cc.Spec.Options.NetworkSubnet = "172.30.0.0"
+	require.ErrorContains(t, cc.Validate(), "options.networkSubnet")
+}
+
+func TestManifestYAML(t *testing.T) {
+	var spec K0sSpec
+	require.NoError(t, yaml.Unmarshal([]byte(`
+manifests:
+  - ./a.yaml
+  - path: ./b.yaml
+    namespace: team-a
+  - path: ./crds.yaml
+    order: 1
+`), &spec))
+	require.Equal(t, []Manifest{{Path: "./a.yaml"}, {Path: "./b.yaml", Namespace: "team-a"}, {Path: "./crds.yaml", Order: 1}}, spec.Manifests)
+
+	out, err := yaml.Marshal(spec)
+	require.NoError(t, err)
+	require.Equal(t, "manifests:\n    - ./a.yaml\n    - path: ./b.yaml\n      namespace: team-a\n    - path: ./crds.yaml\n      order: 1\n", string(out))
+
+	cc := &ClusterConfig{Kind: "Cluster", APIVersion: APIVersion}
+	cc.Spec.K0s.Manifests = []Manifest{{Path: "./b.yaml", Namespace: "Team_A"}}
+	require.ErrorContains(t, cc.Validate(), "k0s.manifests[0].namespace")
+	cc.Spec.K0s.Manifests = []Manifest{{Namespace: "team-a"}}
+	require.ErrorContains(t, cc.Validate(), "path is required")
+	cc.Spec.K0s.Manifests = []Manifest{{Path: "./b.yaml", Order: 1000}}
+	require.ErrorContains(t, cc.Validate(), "k0s.manifests[0].order")
+	cc.Spec.K0s.Manifests = []Manifest{{Path: "./b.yaml", Order: 999}}
+	require.NoError(t, cc.Validate())
+}
+
+func TestEffectiveK0sConfig_HelmCharts(t *testing.T) {
+	cc := &ClusterConfig{Kind: "Cluster", APIVersion: APIVersion}
+	cc.Spec.K0s.Config = map[string]any{"spec": map[string]any{"extensions": map[string]any{"helm": map[string]any{
+		"repositories": []any{map[string]any{"name": "jetstack", "url": "https://charts.jetstack.io"}},
+		"charts":       []any{map[string]any{"name": "cert-manager", "chartname": "jetstack/cert-manager", "namespace": "cert-manager"}},
+	}}}}
+	cc.Spec.K0s.HelmCharts = []HelmChart{
+		{Name: "ingress", ChartName: "ingress-nginx/ingress-nginx", Repository: "https://kubernetes.github.io/ingress-nginx", Version: "4.11.3", Namespace: "ingress-nginx", Values: map[string]any{"controller": map[string]any{"replicaCount": 2}}},
+		// The repository declared in the k0s config wins.
+		{Name: "trust", ChartName: "jetstack/trust-manager", Repository: "https://example.com/charts", Namespace: "cert-manager"},
+		{Name: "podinfo", ChartName: "oci://ghcr.io/stefanprodan/charts/podinfo", Namespace: "default"},
+	}
+	require.NoError(t, cc.Validate())
+
+	helm := cc.EffectiveK0sConfig()["spec"].(map[string]any)["extensions"].(map[string]any)["helm"].(map[string]any)
+	require.Equal(t, []any{
+		map[string]any{"name": "jetstack", "url": "https://charts.jetstack.io"},
+		map[string]any{"name": "ingress-nginx", "url": "https://kubernetes.github.io/ingress-nginx"},
+	}, helm["repositories"])
+	charts := helm["charts"].([]any)
+	require.Len(t, charts, 4)
+	require.Equal(t, "cert-manager", charts[0].(map[string]any)["name"])
+	require.Equal(t, map[string]any{
+		"name":      "ingress",
+		"chartname": "ingress-nginx/ingress-nginx",
+		"version":   "4.11.3",
+		"namespace": "ingress-nginx",
+		"values":    "controller:\n    replicaCount: 2\n",
+	}, charts[1])
+	// The user's config is left alone.
+	require.Len(t, cc.Spec.K0s.Config["spec"].(map[string]any)["extensions"].(map[string]any)["helm"].(map[string]any)["charts"], 1)
+
+	cc.Spec.K0s.HelmCharts = []HelmChart{{Name: "x", ChartName: "x"}}
+	require.ErrorContains(t, cc.Validate(), "k0s.helmCharts[0]")
+	cc.Spec.K0s.HelmCharts = []HelmChart{{Name: "x", ChartName: "x", Namespace: "default", Repository: "https://example.com"}}
+	require.ErrorContains(t, cc.Validate(), "<repo>/<chart>")
+}
+
+func TestEffectiveK0sConfig_Ingress(t *testing.T) {
+	cc := &ClusterConfig{Kind: "Cluster", APIVersion: APIVersion}
+	require.NoError(t, cc.Validate())
+	require.NotContains(t, cc.EffectiveK0sConfig()["spec"], "extensions")
+
+	cc.Spec.Options.Ingress = IngressNone
+	require.NoError(t, cc.Validate())
+	require.NotContains(t, cc.EffectiveK0sConfig()["spec"], "extensions")
+
+	cc.Spec.Options.Ingress = IngressTraefik
+	cc.Spec.K0s.HelmCharts = []HelmChart{{Name: "podinfo", ChartName: "oci://ghcr.io/stefanprodan/charts/podinfo", Namespace: "default"}}
+	require.NoError(t, cc.Validate())
+	helm := cc.EffectiveK0sConfig()["spec"].(map[string]any)["extensions"].(map[string]any)["helm"].(map[string]any)
+	require.Equal(t, []any{map[string]any{"name": "traefik", "url": "https://traefik.github.io/charts"}}, helm["repositories"])
+	charts := helm["charts"].([]any)
+	require.Len(t, charts, 2)
+	traefik := charts[1].(map[string]any)
+	require.Equal(t, "traefik/traefik", traefik["chartname"])
+	require.Equal(t, IngressTraefikChartVersion, traefik["version"])
+	require.Contains(t, traefik["values"], "nodePort: 30080")
+	require.Contains(t, traefik["values"], "nodePort: 30443")
+	// The user's charts are left alone.
+	require.Len(t, cc.Spec.K0s.HelmCharts, 1)
+
+	cc.Spec.Options.Ingress = IngressNginx
+	helm = cc.EffectiveK0sConfig()["spec"].(map[string]any)["extensions"].(map[string]any)["helm"].(map[string]any)
+	require.Equal(t, "ingress-nginx/ingress-nginx", helm["charts"].([]any)[1].(map[string]any)["chartname"])
+	require.Equal(t, IngressNginxChartVersion, helm["charts"].([]any)[1].(map[string]any)["version"])
+
+	cc.Spec.Options.Ingress = "haproxy"
+	require.ErrorContains(t, cc.Validate(), "options.ingress")
+}
@@ -0,0 +1,69 @@
+package config
+
+// Ingress controllers options.ingress can install.
+const (
+	IngressNone    = "none"
+	IngressNginx   = "nginx"
+	IngressTraefik = "traefik"
+)
+
+// Node ports the built-in ingress controller serves http and https on. The primary
+// controller publishes them on the host.
+const (
+	IngressHTTPNodePort  = 30080
+	IngressHTTPSNodePort = 30443
+)
+
+// Chart versions of the ingress controllers, pinned so that clusters created from the
+// same config get the same controller.
+const (
+	IngressNginxChartVersion   = "4.13.0"
+	IngressTraefikChartVersion = "36.3.0"
+)
+
+// IngressEnabled reports whether options.ingress installs an ingress controller.
+func (o OptionsSpec) IngressEnabled() bool {
+	return o.Ingress != "" && o.Ingress != IngressNone
+}
+
+// ingressChart is the Helm chart of the ingress controller options.ingress selects, or
+// nil when none is. Its service is a NodePort on the fixed ingress node ports and its
+// ingress class is the cluster default.
+func (o OptionsSpec) ingressChart() *HelmChart {
+	switch o.Ingress {
+	case IngressNginx:
+		return &HelmChart{
+			Name:       "ingress-nginx",
+			ChartName:  "ingress-nginx/ingress-nginx",
+			Repository: "https://kubernetes.github.io/ingress-nginx",
+			Version:    IngressNginxChartVersion,
+			Namespace:  "ingress-nginx",
+			Values: map[string]any{
+				"controller": map[string]any{
+					"ingressClassResource": map[string]any{"default": true},
+					"service": map[string]any{
+						"type":      "NodePort",
+						"nodePorts": map[string]any{"http": IngressHTTPNodePort, "https": IngressHTTPSNodePort},
+					},
+				},
+			},
+		}
+	case IngressTraefik:
+		return &HelmChart{
+			Name:       "traefik",
+			ChartName:  "traefik/traefik",
+			Repository: "https://traefik.github.io/charts",
+			Version:    IngressTraefikChartVersion,
+			Namespace:  "traefik",
+			Values: map[string]any{
+				"ingressClass": map[string]any{"isDefaultClass": true},
+				"service":      map[string]any{"type": "NodePort"},
+				"ports": map[string]any{
+					"web":       map[string]any{"nodePort": IngressHTTPNodePort},
+					"websecure": map[string]any{"nodePort": IngressHTTPSNodePort},
+				},
+			},
+		}
+	}
+	return nil
+}
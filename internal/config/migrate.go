@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// APIVersion is the current cluster config apiVersion. Configs with an older apiVersion
+// are upconverted to it when loaded.
+const APIVersion = "k0da.k0sproject.io/v1alpha1"
+
+// apiVersionAliases maps alternative spellings to the apiVersion they stand for.
+var apiVersionAliases = map[string]string{
+	"v1alpha1":      "k0da.k0sproject.io/v1alpha1",
+	"k0da/v1alpha1": "k0da.k0sproject.io/v1alpha1",
+}
+
+// migration upconverts a raw config document from one apiVersion to the next.
+type migration struct {
+	to    string
+	apply func(doc map[string]any) error
+}
+
+// migrations are keyed by the apiVersion they convert from. When the schema changes,
+// bump APIVersion and register a step from the previous version here; older versions
+// keep working by chaining through the steps.
+var migrations = map[string]migration{}
+
+func canonicalAPIVersion(v string) string {
+	v = strings.TrimSpace(v)
+	if alias, ok := apiVersionAliases[v]; ok {
+		return alias
+	}
+	return v
+}
+
+// supportedAPIVersions lists every apiVersion that can be loaded.
+func supportedAPIVersions() []string {
+	out := []string{APIVersion}
+	for from := range migrations {
+		out = append(out, from)
+	}
+	sort.Strings(out[1:])
+	return out
+}
+
+func unsupportedAPIVersionError(v string) error {
+	return fmt.Errorf("unsupported apiVersion: %q (supported: %s)", v, strings.Join(supportedAPIVersions(), ", "))
+}
+
+// Migrate upconverts a raw config document to APIVersion in place. Documents without
+// an apiVersion are left alone and get the current one from Validate.
+func Migrate(doc map[string]any) error {
+	raw, ok := doc["apiVersion"]
+	if !ok || raw == nil {
+		return nil
+	}
+	original, ok := raw.(string)
+	if !ok {
+		return fmt.Errorf("apiVersion must be a string")
+	}
+	v := canonicalAPIVersion(original)
+	for steps := 0; v != APIVersion; steps++ {
+		m, ok := migrations[v]
+		if !ok || steps > len(migrations) {
+			return unsupportedAPIVersionError(original)
+		}
+		if err := m.apply(doc); err != nil {
+			return fmt.Errorf("migrate config from %s to %s: %w", v, m.to, err)
+		}
+		v = m.to
+	}
+	doc["apiVersion"] = APIVersion
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package lock provides advisory file locks that serialize concurrent k0da
+// invocations touching shared state, such as the user's kubeconfig.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultTimeout is how long k0da waits for a lock held by another invocation.
+const DefaultTimeout = 2 * time.Minute
+
+// ErrBusy is returned when a lock could not be acquired within the timeout.
+var ErrBusy = errors.New("another k0da operation is in progress")
+
+// retryInterval is how often a held lock is polled.
+const retryInterval = 100 * time.Millisecond
+
+// Lock is an exclusive advisory lock on a file.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire waits up to timeout for an exclusive lock on path. The file, and its
+// directory, are created when missing; the file is never removed.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			return &Lock{f: f}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w (gave up waiting for %s after %s)", ErrBusy, path, timeout)
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
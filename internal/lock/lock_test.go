@@ -0,0 +1,47 @@
+package lock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "test.lock")
+	l, err := Acquire(path, time.Second)
+	require.NoError(t, err)
+
+	acquired := make(chan *Lock)
+	go func() {
+		l2, err := Acquire(path, 5*time.Second)
+		require.NoError(t, err)
+		acquired <- l2
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second Acquire succeeded while the lock was held")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	require.NoError(t, l.Release())
+	select {
+	case l2 := <-acquired:
+		require.NoError(t, l2.Release())
+	case <-time.After(5 * time.Second):
+		t.Fatal("second Acquire did not succeed after Release")
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	l, err := Acquire(path, time.Second)
+	require.NoError(t, err)
+	defer l.Release()
+
+	_, err = Acquire(path, 200*time.Millisecond)
+	require.ErrorIs(t, err, ErrBusy)
+	require.ErrorContains(t, err, "another k0da operation is in progress")
+}
@@ -0,0 +1,113 @@
+// Package paths resolves where k0da keeps its state on disk. All k0da code should
+// build state paths through this package so that they cannot drift apart.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HomeEnv relocates the k0da state directory (default ~/.k0da).
+const HomeEnv = "K0DA_HOME"
+
+// Home returns the k0da state directory: $K0DA_HOME when set, otherwise ~/.k0da.
+func Home() (string, error) {
+	if v := strings.TrimSpace(os.Getenv(HomeEnv)); v != "" {
+		return v, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".k0da"), nil
+}
+
+// home is Home for accessors that cannot fail; it falls back to .k0da in the working directory.
+func home() string {
+	h, err := Home()
+	if err != nil {
+		return ".k0da"
+	}
+	return h
+}
+
+// ClusterDir is the state directory of a cluster.
+func ClusterDir(clusterName string) string {
+	return filepath.Join(home(), "clusters", clusterName)
+}
+
+// ConfigDir holds the effective k0s config of a cluster, mounted into the primary node.
+func ConfigDir(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "etc-k0s")
+}
+
+// ConfigPath is the effective k0s config of a cluster.
+func ConfigPath(clusterName string) string {
+	return filepath.Join(ConfigDir(clusterName), "k0s.yaml")
+}
+
+// WorkerConfigPath is the k0s config of a cluster's worker nodes, kept apart from the
+// controllers' ConfigDir.
+func WorkerConfigPath(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "etc-k0s-worker", "k0s.yaml")
+}
+
+// RegistryAuthPath is the containerd config drop-in with a cluster's registry credentials.
+func RegistryAuthPath(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "containerd.d", "k0da-registry-auth.toml")
+}
+
+// ImageBundlesDir holds the image bundles of a cluster downloaded from URLs.
+func ImageBundlesDir(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "images")
+}
+
+// ManifestDir holds the manifests staged for a cluster.
+func ManifestDir(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "manifests")
+}
+
+// StoredConfigPath is where the cluster config used to create a cluster is kept.
+func StoredConfigPath(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "cluster.yaml")
+}
+
+// TokensDir holds the join tokens of a cluster's additional nodes.
+func TokensDir(clusterName string) string {
+	return filepath.Join(ClusterDir(clusterName), "tokens")
+}
+
+// PluginsDir is where embedded plugin manifests are extracted to.
+func PluginsDir() string {
+	return filepath.Join(home(), "plugins")
+}
+
+// PluginsLock is the lock file serializing plugin extraction.
+func PluginsLock() string {
+	return filepath.Join(home(), "plugins.lock")
+}
+
+// KubeconfigLock is the lock file serializing k0da's kubeconfig updates. It lives
+// in the k0da home rather than next to the kubeconfig, where kubectl keeps its own
+// config.lock with different semantics.
+func KubeconfigLock() string {
+	return filepath.Join(home(), "kubeconfig.lock")
+}
+
+// Kubeconfig returns the kubeconfig k0da writes contexts to: the first entry of
+// $KUBECONFIG when set, otherwise ~/.kube/config.
+func Kubeconfig() string {
+	if v := strings.TrimSpace(os.Getenv("KUBECONFIG")); v != "" {
+		parts := strings.Split(v, string(os.PathListSeparator))
+		if len(parts) > 0 && strings.TrimSpace(parts[0]) != "" {
+			return parts[0]
+		}
+	}
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kube", "config")
+	}
+	return filepath.Join(h, ".kube", "config")
+}
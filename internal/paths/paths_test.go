@@ -0,0 +1,63 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setHome points the user home directory at dir for the test. os.UserHomeDir reads
+// $USERPROFILE on Windows, so both variables are set.
+func setHome(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
+
+func TestHome_Env(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(HomeEnv, dir)
+
+	home, err := Home()
+	require.NoError(t, err)
+	assert.Equal(t, dir, home)
+	assert.Equal(t, filepath.Join(dir, "clusters", "dev"), ClusterDir("dev"))
+	assert.Equal(t, filepath.Join(dir, "plugins"), PluginsDir())
+}
+
+func TestHome_Default(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(HomeEnv, "")
+	setHome(t, dir)
+
+	home, err := Home()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, ".k0da"), home)
+}
+
+func TestClusterPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(HomeEnv, dir)
+	cluster := filepath.Join(dir, "clusters", "dev")
+
+	assert.Equal(t, filepath.Join(cluster, "etc-k0s"), ConfigDir("dev"))
+	assert.Equal(t, filepath.Join(cluster, "etc-k0s", "k0s.yaml"), ConfigPath("dev"))
+	assert.Equal(t, filepath.Join(cluster, "manifests"), ManifestDir("dev"))
+	assert.Equal(t, filepath.Join(cluster, "cluster.yaml"), StoredConfigPath("dev"))
+	assert.Equal(t, filepath.Join(cluster, "tokens"), TokensDir("dev"))
+}
+
+func TestKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	setHome(t, dir)
+
+	t.Setenv("KUBECONFIG", "")
+	assert.Equal(t, filepath.Join(dir, ".kube", "config"), Kubeconfig())
+
+	first := filepath.Join(dir, "a.yaml")
+	t.Setenv("KUBECONFIG", first+string(os.PathListSeparator)+filepath.Join(dir, "b.yaml"))
+	assert.Equal(t, first, Kubeconfig())
+}
@@ -7,6 +7,9 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/makhov/k0da/internal/lock"
+	"github.com/makhov/k0da/internal/paths"
 )
 
 //go:embed embedded
@@ -34,12 +37,7 @@ func PluginManifestList() ([]string, error) {
 }
 
 func pluginDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	pluginsDir := filepath.Join(homeDir, ".k0da", "plugins")
+	pluginsDir := paths.PluginsDir()
 	if err := os.MkdirAll(pluginsDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create plugins directory: %w", err)
 	}
@@ -53,6 +51,13 @@ func ExtractPlugins() ([]string, error) {
 		return nil, err
 	}
 
+	// Concurrent invocations would otherwise rewrite the same files under each other.
+	l, err := lock.Acquire(paths.PluginsLock(), lock.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
 	// Read all files from the embedded filesystem
 	entries, err := pluginFS.ReadDir("embedded")
 	if err != nil {
@@ -0,0 +1,360 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// cliRuntime implements the Runtime methods that docker-compatible CLIs such as podman
+// and nerdctl share by running binary. Backends embed it and add what differs, e.g.
+// Version, Describe and how `ps` output is parsed.
+type cliRuntime struct {
+	name   string
+	binary string
+	// prefixArgs returns the global flags put before every subcommand, e.g. podman's
+	// --connection. Optional.
+	prefixArgs func() []string
+	// extraEnv returns variables added to the environment of every command. Optional.
+	extraEnv func() []string
+}
+
+func (c *cliRuntime) Name() string { return c.name }
+
+// args returns the full argument list of the subcommand args.
+func (c *cliRuntime) args(args []string) []string {
+	if c.prefixArgs == nil {
+		return args
+	}
+	return append(c.prefixArgs(), args...)
+}
+
+// command returns the command running the subcommand args.
+func (c *cliRuntime) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.binary, c.args(args)...)
+	if c.extraEnv != nil {
+		cmd.Env = append(os.Environ(), c.extraEnv()...)
+	}
+	return cmd
+}
+
+// exists runs args and reports whether they succeeded, for the inspect style commands
+// that fail when an object doesn't exist.
+func (c *cliRuntime) exists(ctx context.Context, args ...string) (bool, error) {
+	if err := c.command(ctx, args...).Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() != 0 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// runArgs builds the `run` arguments for opts with the k0s defaults applied.
+func (c *cliRuntime) runArgs(opts RunContainerOptions) []string {
+	return cliRunArgs(opts)
+}
+
+func (c *cliRuntime) RunContainer(ctx context.Context, opts RunContainerOptions) (string, error) {
+	// Image then command args
+	if strings.TrimSpace(opts.Image) == "" {
+		return "", fmt.Errorf("image is required")
+	}
+	out, err := c.command(ctx, c.runArgs(opts)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s run failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (c *cliRuntime) ContainerExists(ctx context.Context, name string) (bool, error) {
+	return c.exists(ctx, "container", "inspect", name)
+}
+
+func (c *cliRuntime) ContainerIsRunning(ctx context.Context, name string) (bool, error) {
+	out, err := c.command(ctx, "container", "inspect", name, "--format", "{{.State.Running}}").CombinedOutput()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+func (c *cliRuntime) StopContainer(ctx context.Context, name string) error {
+	_, err := c.command(ctx, "stop", name).CombinedOutput()
+	return err
+}
+
+func (c *cliRuntime) RemoveContainer(ctx context.Context, name string) error {
+	_, err := c.command(ctx, "rm", "-f", name).CombinedOutput()
+	return err
+}
+
+func (c *cliRuntime) RenameContainer(ctx context.Context, oldName, newName string) error {
+	if out, err := c.command(ctx, "rename", oldName, newName).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s rename failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (c *cliRuntime) ExecInContainer(ctx context.Context, name string, command []string) (string, int, error) {
+	out, err := c.command(ctx, append([]string{"exec", name}, command...)...).CombinedOutput()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return string(out), ee.ExitCode(), nil
+		}
+		return string(out), 1, err
+	}
+	return string(out), 0, nil
+}
+
+func (c *cliRuntime) ExecInContainerStream(ctx context.Context, name string, command []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	args := []string{"exec"}
+	if stdin != nil {
+		args = append(args, "-i")
+	}
+	cmd := c.command(ctx, append(append(args, name), command...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return ee.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+func (c *cliRuntime) ExecInContainerInteractive(ctx context.Context, name string, command []string) (int, error) {
+	return runInteractive(c.command(ctx, append([]string{"exec", "-it", name}, command...)...))
+}
+
+func (c *cliRuntime) ExecInContainerTTY(ctx context.Context, name string, command []string, stdin io.Reader, stdout io.Writer) (int, error) {
+	cmd := c.command(ctx, append([]string{"exec", "-i", "-t", name}, command...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return ee.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+func (c *cliRuntime) ContainerLogs(ctx context.Context, name string, opts LogsOptions, stdout, stderr io.Writer) error {
+	cmd := c.command(ctx, logsArgs(name, opts)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("%s logs failed: %w", c.name, err)
+	}
+	return nil
+}
+
+// logsArgs builds the `logs` arguments for opts.
+func logsArgs(name string, opts LogsOptions) []string {
+	args := []string{"logs"}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	if opts.Tail > 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	return append(args, name)
+}
+
+func (c *cliRuntime) GetPortMapping(ctx context.Context, name string, containerPort int, protocol string) (string, int, error) {
+	proto := strings.ToLower(protocol)
+	if proto == "" {
+		proto = "tcp"
+	}
+	out, err := c.command(ctx, "port", name, fmt.Sprintf("%d/%s", containerPort, proto)).CombinedOutput()
+	if err != nil {
+		return "", 0, fmt.Errorf("%s port failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return parsePortOutput(string(out))
+}
+
+// parsePortOutput parses the output of `port <container> <port>/<proto>`: one line per
+// binding, e.g. 0.0.0.0:40001 or [::]:40001, of which the first one is used.
+func parsePortOutput(out string) (string, int, error) {
+	s, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	if s == "" {
+		return "", 0, fmt.Errorf("port mapping not found")
+	}
+	idx := strings.LastIndex(s, ":")
+	if idx == -1 || idx+1 >= len(s) {
+		return "", 0, fmt.Errorf("unexpected port output: %q", s)
+	}
+	host := strings.Trim(strings.TrimSpace(s[:idx]), "[]")
+	port, err := strconv.Atoi(strings.TrimSpace(s[idx+1:]))
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected port output: %q", s)
+	}
+	return host, port, nil
+}
+
+func (c *cliRuntime) ContainerIP(ctx context.Context, name, network string) (string, error) {
+	out, err := c.command(ctx, "container", "inspect", name, "--format", "{{json .NetworkSettings.Networks}}").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s inspect failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	ip, err := parseNetworkIP(out, network)
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		return "", fmt.Errorf("container %s has no IP address on network %s", name, network)
+	}
+	return ip, nil
+}
+
+func (c *cliRuntime) VolumeExists(ctx context.Context, name string) (bool, error) {
+	return c.exists(ctx, "volume", "inspect", name)
+}
+
+func (c *cliRuntime) RemoveVolume(ctx context.Context, name string) error {
+	_, err := c.command(ctx, "volume", "rm", "-f", name).CombinedOutput()
+	return err
+}
+
+// ps runs `ps` with the output format and label selector and returns its output.
+func (c *cliRuntime) ps(ctx context.Context, format string, selector map[string]string, includeStopped bool) ([]byte, error) {
+	args := []string{"ps", "--format", format}
+	if includeStopped {
+		args = append(args, "-a")
+	}
+	for k, v := range selector {
+		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
+	}
+	out, err := c.command(ctx, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s ps failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+func (c *cliRuntime) CopyToContainer(ctx context.Context, name string, srcPath string, dstPath string) error {
+	if out, err := c.command(ctx, "cp", srcPath, name+":"+dstPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s cp failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// PullImage pulls an image with `pull`.
+func (c *cliRuntime) PullImage(ctx context.Context, ref string) error {
+	if out, err := c.command(ctx, "pull", "--quiet", ref).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s pull failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ImageExists reports whether the image is present, using `image inspect`.
+func (c *cliRuntime) ImageExists(ctx context.Context, ref string) (bool, error) {
+	exists, err := c.exists(ctx, "image", "inspect", ref)
+	if err != nil {
+		return false, fmt.Errorf("%s image inspect failed: %w", c.name, err)
+	}
+	return exists, nil
+}
+
+// ImageID returns the ID of a local image from `image inspect`. Podman reports it
+// without the sha256: prefix, which is added for consistency with Docker.
+func (c *cliRuntime) ImageID(ctx context.Context, ref string) (string, error) {
+	out, err := c.command(ctx, "image", "inspect", "--format", "{{json .}}", ref).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s image inspect failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	var img struct{ Id string }
+	if err := json.Unmarshal(out, &img); err != nil {
+		return "", fmt.Errorf("parse %s image inspect: %w", c.name, err)
+	}
+	if img.Id == "" {
+		return "", fmt.Errorf("%s did not report an ID for image %s", c.name, ref)
+	}
+	if !strings.Contains(img.Id, ":") {
+		return "sha256:" + img.Id, nil
+	}
+	return img.Id, nil
+}
+
+func (c *cliRuntime) SaveImageToTar(ctx context.Context, imageRef string, tarPath string) error {
+	if out, err := c.command(ctx, "save", "-o", tarPath, imageRef).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s save failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// EnsureNetwork ensures a user-defined network exists with the given name.
+func (c *cliRuntime) EnsureNetwork(ctx context.Context, name string, opts NetworkOptions) error {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	if err := c.command(ctx, "network", "inspect", name).Run(); err == nil {
+		return nil
+	}
+	// create attachable bridge network by default
+	args := []string{"network", "create"}
+	if opts.Subnet != "" {
+		args = append(args, "--subnet", opts.Subnet)
+	}
+	if out, err := c.command(ctx, append(args, name)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s network create failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// NetworkExists reports whether a network with the given name exists.
+func (c *cliRuntime) NetworkExists(ctx context.Context, name string) (bool, error) {
+	return c.exists(ctx, "network", "inspect", name)
+}
+
+// NetworkSubnets returns the subnets of a network from `network inspect`.
+func (c *cliRuntime) NetworkSubnets(ctx context.Context, name string) ([]string, error) {
+	out, err := c.command(ctx, "network", "inspect", "--format", "{{json .}}", name).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s network inspect failed: %s", c.name, strings.TrimSpace(string(out)))
+	}
+	return parseNetworkSubnets(out)
+}
+
+// parseNetworkSubnets extracts the subnets from `network inspect` JSON, which lists them
+// in the docker format under IPAM.Config or, with podman, under subnets.
+func parseNetworkSubnets(out []byte) ([]string, error) {
+	var n struct {
+		IPAM struct {
+			Config []struct{ Subnet string }
+		}
+		Subnets []struct {
+			Subnet string `json:"subnet"`
+		} `json:"subnets"`
+	}
+	if err := json.Unmarshal(out, &n); err != nil {
+		return nil, fmt.Errorf("parse network inspect: %w", err)
+	}
+	var subnets []string
+	for _, c := range n.IPAM.Config {
+		if c.Subnet != "" {
+			subnets = append(subnets, c.Subnet)
+		}
+	}
+	for _, s := range n.Subnets {
+		if s.Subnet != "" {
+			subnets = append(subnets, s.Subnet)
+		}
+	}
+	return subnets, nil
+}
@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLIRuntimeCommand(t *testing.T) {
+	c := &cliRuntime{name: "test", binary: "test-cli"}
+	cmd := c.command(context.Background(), "ps", "-a")
+	require.Equal(t, []string{"test-cli", "ps", "-a"}, cmd.Args)
+	require.Nil(t, cmd.Env)
+
+	c.prefixArgs = func() []string { return []string{"--global", "x"} }
+	c.extraEnv = func() []string { return []string{"TEST_CLI=1"} }
+	cmd = c.command(context.Background(), "ps", "-a")
+	require.Equal(t, []string{"test-cli", "--global", "x", "ps", "-a"}, cmd.Args)
+	require.Contains(t, cmd.Env, "TEST_CLI=1")
+}
+
+func TestPodmanArgs(t *testing.T) {
+	p := newPodman("", "", "")
+	require.Equal(t, "podman", p.Name())
+	require.Equal(t, []string{"podman", "ps"}, p.command(context.Background(), "ps").Args)
+
+	p = newPodman("", "", "podman-machine-default-root")
+	require.Equal(t, []string{"podman", "--connection", "podman-machine-default-root", "ps"}, p.command(context.Background(), "ps").Args)
+	require.Empty(t, p.env())
+
+	t.Setenv("CONTAINER_SSHKEY", "")
+	p = newPodman("ssh://core@127.0.0.1:50000/run/podman/podman.sock", "/home/me/.ssh/id", "")
+	cmd := p.command(context.Background(), "ps")
+	require.Equal(t, []string{"podman", "ps"}, cmd.Args)
+	require.Contains(t, cmd.Env, "CONTAINER_HOST=ssh://core@127.0.0.1:50000/run/podman/podman.sock")
+	require.Contains(t, cmd.Env, "CONTAINER_SSHKEY=/home/me/.ssh/id")
+
+	args := p.runArgs(RunContainerOptions{Name: "n", Image: "k0s", Privileged: true})
+	require.Equal(t, []string{"run", "-d", "--restart", "always", "--cgroupns", "private", "--pull", "missing", "--name", "n", "--privileged"}, args[:11])
+	require.Equal(t, "k0s", args[len(args)-1])
+
+	// The host cgroup namespace is rejected before podman runs under rootless.
+	p.rootless = true
+	_, err := p.RunContainer(context.Background(), RunContainerOptions{Name: "n", Image: "k0s", CgroupNS: "host"})
+	require.ErrorContains(t, err, "rootless")
+}
+
+func TestPodmanInspectCommands(t *testing.T) {
+	// A stand-in podman that records its arguments and succeeds.
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args")
+	bin := filepath.Join(dir, "podman")
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\necho true\n"), 0755))
+	p := newPodman("", "", "")
+	p.binary = bin
+
+	ctx := context.Background()
+	ok, err := p.ContainerExists(ctx, "demo")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = p.ContainerIsRunning(ctx, "demo")
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = p.NetworkExists(ctx, "k0da")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"inspect -t container demo",
+		"inspect -t container demo --format {{.State.Running}}",
+		"network exists k0da",
+	}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+}
+
+func TestParsePortOutput(t *testing.T) {
+	host, port, err := parsePortOutput("0.0.0.0:40001\n[::]:40001\n")
+	require.NoError(t, err)
+	require.Equal(t, "0.0.0.0", host)
+	require.Equal(t, 40001, port)
+
+	host, port, err = parsePortOutput("[::1]:40002")
+	require.NoError(t, err)
+	require.Equal(t, "::1", host)
+	require.Equal(t, 40002, port)
+
+	_, _, err = parsePortOutput("")
+	require.ErrorContains(t, err, "not found")
+	_, _, err = parsePortOutput("0.0.0.0:http")
+	require.ErrorContains(t, err, "unexpected port output")
+	_, _, err = parsePortOutput("40001")
+	require.ErrorContains(t, err, "unexpected port output")
+}
+
+func TestNerdctlArgs(t *testing.T) {
+	n := newNerdctl("nerdctl.lima", "", "")
+	require.Equal(t, "nerdctl", n.Name())
+	require.Equal(t, []string{"nerdctl.lima", "ps"}, n.command(context.Background(), "ps").Args)
+
+	n = newNerdctl("nerdctl", "/run/containerd/containerd.sock", "k0da")
+	cmd := n.command(context.Background(), "ps")
+	require.Equal(t, []string{"nerdctl", "--address", "/run/containerd/containerd.sock", "--namespace", "k0da", "ps"}, cmd.Args)
+	require.Nil(t, cmd.Env)
+
+	args := n.runArgs(RunContainerOptions{Name: "n", Image: "k0s", Privileged: true})
+	require.Equal(t, []string{"run", "-d", "--restart", "always", "--cgroupns", "private", "--pull", "missing", "--name", "n", "--privileged"}, args[:11])
+	require.Equal(t, "k0s", args[len(args)-1])
+}
+
+func TestParseNetworkSubnets(t *testing.T) {
+	docker := `{"Name":"k0da","IPAM":{"Driver":"default","Config":[{"Subnet":"172.18.0.0/16","Gateway":"172.18.0.1"}]}}`
+	subnets, err := parseNetworkSubnets([]byte(docker))
+	require.NoError(t, err)
+	require.Equal(t, []string{"172.18.0.0/16"}, subnets)
+
+	podman := `{"name":"k0da","driver":"bridge","subnets":[{"subnet":"10.89.0.0/24","gateway":"10.89.0.1"}]}`
+	subnets, err = parseNetworkSubnets([]byte(podman))
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.89.0.0/24"}, subnets)
+
+	_, err = parseNetworkSubnets([]byte("Error: no such network"))
+	require.Error(t, err)
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 )
@@ -48,12 +50,11 @@ func tryPodmanMacTmpdirSocket() string {
 
 // tryDockerSocketCandidates checks all Docker socket candidates and returns
 // the first one that exists and is reachable, or empty string if none found.
-// tryDockerSocketCandidates returns the first reachable Docker socket and its proto, or empty string if none found.
 func tryDockerSocketCandidates() (socket string) {
 	if runtime.GOOS == "windows" {
 		return "npipe:////./pipe/docker_engine"
 	}
-	home := os.Getenv("HOME")
+	home, _ := os.UserHomeDir()
 	candidates := []string{"unix:///var/run/docker.sock"}
 	if home != "" {
 		candidates = append(candidates,
@@ -66,11 +67,59 @@ func tryDockerSocketCandidates() (socket string) {
 			"unix://"+filepath.Join(home, ".local", "share", "containers", "podman", "machine", "podman-machine-default", "podman.sock"),
 		)
 	}
-	for _, socket := range candidates {
-		path := strings.TrimPrefix(socket, "unix://")
-		if _, err := os.Stat(path); err == nil {
-			if isSocketReachable("unix", path, 3) {
-				return socket
+	return firstReachableSocket(candidates, socketProbeTimeout)
+}
+
+// socketProbeTimeout caps the time spent probing all socket candidates together.
+const socketProbeTimeout = 2 * time.Second
+
+// firstReachableSocket probes unix:// socket candidates concurrently and returns the
+// first one in the order given that exists and is reachable. It returns as soon as all
+// candidates preferred over a reachable one are known to be unreachable; after timeout
+// the candidates still being probed count as unreachable.
+func firstReachableSocket(candidates []string, timeout time.Duration) string {
+	type probe struct {
+		i         int
+		reachable bool
+	}
+	results := make(chan probe, len(candidates))
+	for i, socket := range candidates {
+		go func() {
+			path := strings.TrimPrefix(socket, "unix://")
+			_, err := os.Stat(path)
+			results <- probe{i: i, reachable: err == nil && isSocketReachable("unix", path, 3)}
+		}()
+	}
+
+	const (
+		pending = iota
+		unreachable
+		reachable
+	)
+	state := make([]int, len(candidates))
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for range candidates {
+		select {
+		case p := <-results:
+			state[p.i] = unreachable
+			if p.reachable {
+				state[p.i] = reachable
+			}
+		case <-deadline.C:
+			for i, st := range state {
+				if st == reachable {
+					return candidates[i]
+				}
+			}
+			return ""
+		}
+		for i, st := range state {
+			if st == pending {
+				break
+			}
+			if st == reachable {
+				return candidates[i]
 			}
 		}
 	}
@@ -92,11 +141,26 @@ func isSocketReachable(proto, socketPath string, maxRetries int) bool {
 	return false
 }
 
+// detectCommandTimeout bounds each runtime probe Detect makes, on top of the caller's
+// context, so that a hung podman or an unreachable remote daemon can't block every
+// command.
+const detectCommandTimeout = 5 * time.Second
+
+// probeOutput runs a CLI probe for Detect and returns its combined output. The process
+// is killed when ctx is done or after detectCommandTimeout.
+func probeOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, detectCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Don't wait for children of a killed probe that still hold its output open.
+	cmd.WaitDelay = time.Second
+	return cmd.CombinedOutput()
+}
+
 // tryPodmanConnectionList queries `podman system connection list --format json`
 // and returns the URI and Identity of the best connection (prefer rootful), or "" if not found.
-func tryPodmanConnectionList() (string, string) {
-	cmd := exec.Command("podman", "system", "connection", "list", "--format", "json")
-	out, err := cmd.CombinedOutput()
+func tryPodmanConnectionList(ctx context.Context) (string, string) {
+	out, err := probeOutput(ctx, "podman", "system", "connection", "list", "--format", "json")
 	if err != nil || len(out) == 0 {
 		return "", ""
 	}
@@ -144,9 +208,21 @@ func tryPodmanConnectionList() (string, string) {
 	return "", ""
 }
 
-func podmanMachineIsRootful() (bool, bool) {
-	cmd := exec.Command("podman", "machine", "inspect")
-	out, err := cmd.CombinedOutput()
+// rootlessAllowed reports whether the user opted into rootless Podman via K0DA_ALLOW_ROOTLESS.
+func rootlessAllowed() bool {
+	v, ok := getenv("K0DA_ALLOW_ROOTLESS")
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+func podmanMachineIsRootful(ctx context.Context) (bool, bool) {
+	out, err := probeOutput(ctx, "podman", "machine", "inspect")
 	if err != nil || len(out) == 0 {
 		return false, false
 	}
@@ -193,7 +269,7 @@ func Detect(ctx context.Context, opts DetectOptions) (Runtime, error) {
 			}
 		case "podman":
 			// First, prefer connection list (rootful if available)
-			u, id := tryPodmanConnectionList()
+			u, id := tryPodmanConnectionList(ctx)
 			if u != "" {
 				socket, identity = u, id
 			} else {
@@ -226,39 +302,116 @@ func Detect(ctx context.Context, opts DetectOptions) (Runtime, error) {
 
 	// If using Podman on macOS and machine is rootless but we selected a rootless connection, check for rootful alternative
 	if runtime == "podman" {
-		if isRootful, ok := podmanMachineIsRootful(); ok && !isRootful {
+		if isRootful, ok := podmanMachineIsRootful(ctx); ok && !isRootful {
 			// Try to find rootful connection
-			u2, id2 := tryPodmanConnectionList()
+			u2, id2 := tryPodmanConnectionList(ctx)
 			if strings.HasPrefix(u2, "ssh://root@") {
 				socket, identity = u2, id2
-			} else {
-				return nil, fmt.Errorf("podman machine is rootless; please run 'podman machine set --rootful' and restart, or set K0DA_RUNTIME=docker")
+			} else if !rootlessAllowed() {
+				return nil, fmt.Errorf("podman machine is rootless; please run 'podman machine set --rootful' and restart, set K0DA_ALLOW_ROOTLESS=1 to try rootless mode, or set K0DA_RUNTIME=docker")
 			}
 		}
 	}
 
+	endpoint := FactoryOptions{Socket: socket, Identity: identity}
 	if runtime != "" {
-		switch runtime {
-		case "docker":
-			return NewDockerRuntime(ctx, socket)
-		case "podman":
-			return NewPodmanRuntime(ctx, socket, identity)
-		case "containerd":
-			return nil, fmt.Errorf("containerd backend not implemented yet")
-		default:
-			return nil, fmt.Errorf("unknown runtime: %s", runtime)
+		factory, ok := GetFactory(runtime)
+		if !ok {
+			return nil, fmt.Errorf("unknown runtime: %s (supported: %s)", runtime, strings.Join(Registered(), ", "))
 		}
+		return factory(ctx, endpoint)
 	}
 	// Try Docker with any available socket first if no socket was set
-	if socket == "" {
-		socket = tryDockerSocketCandidates()
+	if endpoint.Socket == "" {
+		endpoint.Socket = tryDockerSocketCandidates()
+	}
+
+	for _, name := range autoDetectOrder() {
+		factory, _ := GetFactory(name)
+		if b, err := factory(ctx, endpoint); err == nil {
+			return b, nil
+		}
 	}
+	return nil, fmt.Errorf("no supported container runtime detected. Please set K0DA_RUNTIME=%s and K0DA_SOCKET=<socket-path> to override detection", strings.Join(Registered(), "|"))
+}
 
-	if b, err := NewDockerRuntime(ctx, socket); err == nil {
-		return b, nil
+// preferredRuntimes are tried first, in this order, when no runtime is selected.
+var preferredRuntimes = []string{"docker", "podman", "nerdctl"}
+
+// autoDetectOrder returns the registered backends in the order Detect tries them:
+// the preferred ones first, then any others by name.
+func autoDetectOrder() []string {
+	var order []string
+	for _, name := range preferredRuntimes {
+		if _, ok := GetFactory(name); ok {
+			order = append(order, name)
+		}
 	}
-	if b, err := NewPodmanRuntime(ctx, socket, identity); err == nil {
-		return b, nil
+	for _, name := range Registered() {
+		if !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// RemoteHost returns the host of the machine the runtime runs on when it is reached
+// over ssh:// or tcp:// at a non-loopback address, or "" when the runtime is local.
+// Ports published by node containers are then bound on that host, not on 127.0.0.1.
+func RemoteHost(r Runtime) string {
+	switch b := r.(type) {
+	case *Docker:
+		return socketHost(b.socket)
+	case *Podman:
+		return socketHost(b.socket)
+	}
+	return ""
+}
+
+// socketHost returns the non-loopback host of an ssh://, tcp://, http:// or https://
+// socket URI, or "" for local sockets.
+func socketHost(socket string) string {
+	u, err := url.Parse(strings.TrimSpace(socket))
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "ssh", "tcp", "http", "https":
+	default:
+		return ""
+	}
+	host := u.Hostname()
+	if host == "" || strings.EqualFold(host, "localhost") {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return ""
+	}
+	return host
+}
+
+// SocketMount returns a bind mount of the runtime's API socket at its usual path, as seen
+// by the daemon or service that starts the containers: local unix sockets on Linux are
+// mounted as is, while VM-based and remote Docker runtimes use /var/run/docker.sock inside
+// the VM or on the remote host.
+func SocketMount(ctx context.Context, r Runtime) (Mount, error) {
+	switch b := r.(type) {
+	case *Docker:
+		source := "/var/run/docker.sock"
+		if path, ok := strings.CutPrefix(b.socket, "unix://"); ok && runtime.GOOS == "linux" {
+			source = path
+		}
+		return Mount{Type: "bind", Source: source, Target: "/var/run/docker.sock"}, nil
+	case *Podman:
+		out, err := b.command(ctx, "info", "--format", "{{.Host.RemoteSocket.Path}}").Output()
+		if err != nil {
+			return Mount{}, fmt.Errorf("failed to find the podman socket: %w", err)
+		}
+		source := strings.TrimPrefix(strings.TrimSpace(string(out)), "unix://")
+		if source == "" {
+			return Mount{}, fmt.Errorf("podman did not report its socket path")
+		}
+		return Mount{Type: "bind", Source: source, Target: "/run/podman/podman.sock"}, nil
 	}
-	return nil, fmt.Errorf("no supported container runtime detected. Please set K0DA_RUNTIME=docker|podman and K0DA_SOCKET=<socket-path> to override detection")
+	return Mount{}, fmt.Errorf("mounting the runtime socket is not supported for %s", r.Name())
 }
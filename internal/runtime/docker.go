@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -13,8 +14,10 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	imageTypes "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	timetypes "github.com/docker/docker/api/types/time"
 	"github.com/docker/docker/api/types/volume"
 	dockerClient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 )
 
@@ -24,6 +27,16 @@ type Docker struct {
 	socket string
 }
 
+func init() {
+	Register("docker", func(ctx context.Context, opts FactoryOptions) (Runtime, error) {
+		d, err := NewDockerRuntime(ctx, opts.Socket)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	})
+}
+
 func NewDockerRuntime(ctx context.Context, socket string) (*Docker, error) {
 	if socket == "" {
 		return nil, fmt.Errorf("docker socket not specified")
@@ -33,7 +46,9 @@ func NewDockerRuntime(ctx context.Context, socket string) (*Docker, error) {
 		return nil, err
 	}
 	// Ping to verify connectivity
-	_, err = client.Ping(ctx)
+	pingCtx, cancel := context.WithTimeout(ctx, detectCommandTimeout)
+	defer cancel()
+	_, err = client.Ping(pingCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -42,18 +57,58 @@ func NewDockerRuntime(ctx context.Context, socket string) (*Docker, error) {
 
 func (d *Docker) Name() string { return d.name }
 
+func (d *Docker) Describe() string { return fmt.Sprintf("%s (%s)", d.name, d.socket) }
+
+func (d *Docker) Version(ctx context.Context) (string, error) {
+	v, err := d.cli.ServerVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	return v.Version, nil
+}
+
 func (d *Docker) RunContainer(ctx context.Context, opts RunContainerOptions) (string, error) {
-	// Ensure image exists locally; pull if missing
 	if opts.Image != "" {
-		rc, err := d.cli.ImagePull(ctx, opts.Image, imageTypes.PullOptions{})
-		if err != nil {
+		if err := d.ensureImage(ctx, opts.Image, opts.effectivePullPolicy()); err != nil {
 			return "", err
 		}
-		if rc != nil {
-			_, _ = io.Copy(io.Discard, rc)
-			_ = rc.Close()
+	}
+
+	config, hostConfig, networking := dockerContainerConfig(opts)
+	resp, err := d.cli.ContainerCreate(ctx, config, hostConfig, networking, nil, opts.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// ensureImage pulls ref according to policy. The Docker API, unlike `docker run`, does
+// not pull on its own, so a missing image fails the create otherwise.
+func (d *Docker) ensureImage(ctx context.Context, ref, policy string) error {
+	switch policy {
+	case PullAlways:
+		return d.PullImage(ctx, ref)
+	case PullMissing, PullNever:
+		exists, err := d.ImageExists(ctx, ref)
+		if err != nil || exists {
+			return err
+		}
+		if policy == PullNever {
+			return fmt.Errorf("image %s not found locally and the pull policy is %s", ref, PullNever)
 		}
+		return d.PullImage(ctx, ref)
+	default:
+		return fmt.Errorf("unsupported pull policy %q", policy)
 	}
+}
+
+// dockerContainerConfig translates run options, with the k0s defaults applied, into Docker API configs.
+func dockerContainerConfig(opts RunContainerOptions) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+	opts = withK0sDefaults(opts)
 
 	config := &container.Config{
 		Image:    opts.Image,
@@ -64,24 +119,45 @@ func (d *Docker) RunContainer(ctx context.Context, opts RunContainerOptions) (st
 		Tty:      true,
 	}
 
+	if h := opts.Healthcheck; h != nil {
+		config.Healthcheck = &container.HealthConfig{
+			Test:        append([]string{"CMD"}, h.Command...),
+			Interval:    h.Interval,
+			Timeout:     h.Timeout,
+			StartPeriod: h.StartPeriod,
+			Retries:     h.Retries,
+		}
+	}
+
 	hostConfig := &container.HostConfig{
 		AutoRemove:  opts.AutoRemove,
 		Privileged:  opts.Privileged,
 		SecurityOpt: opts.SecurityOpt,
 		Tmpfs:       opts.Tmpfs,
+		Runtime:     opts.OCIRuntime,
+		CapAdd:      opts.CapAdd,
+		CapDrop:     opts.CapDrop,
+		DNS:         opts.DNS,
+		DNSSearch:   opts.DNSSearch,
+		ExtraHosts:  opts.ExtraHosts,
+	}
+	for _, dev := range opts.Devices {
+		d := ParseDevice(dev)
+		hostConfig.Resources.Devices = append(hostConfig.Resources.Devices, container.DeviceMapping{
+			PathOnHost:        d.HostPath,
+			PathInContainer:   d.ContainerPath,
+			CgroupPermissions: d.Permissions,
+		})
 	}
-	// Set ulimit memlock unlimited for k0s eBPF
-	if hostConfig.Ulimits == nil {
-		hostConfig.Ulimits = []*container.Ulimit{}
+	for _, u := range opts.Ulimits {
+		hostConfig.Ulimits = append(hostConfig.Ulimits, &container.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
 	}
-	hostConfig.Ulimits = append(hostConfig.Ulimits, &container.Ulimit{Name: "memlock", Soft: -1, Hard: -1})
 
 	// Use Mounts helper
 	if len(opts.Mounts) > 0 {
 		hostConfig.Binds = opts.Mounts.ToBinds()
 	}
 
-	hostConfig.Binds = append(hostConfig.Binds, "/var/run/docker.sock:/var/run/docker.sock")
 	// Port publishing
 	if len(opts.Publish) > 0 {
 		hostConfig.PortBindings = natPortBindings(opts.Publish)
@@ -99,25 +175,19 @@ func (d *Docker) RunContainer(ctx context.Context, opts RunContainerOptions) (st
 		}
 	}
 
-	// Ensure container restarts after daemon restart
-	hostConfig.RestartPolicy = container.RestartPolicy{Name: "always"}
+	hostConfig.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyMode(opts.effectiveRestartPolicy())}
+	hostConfig.CgroupnsMode = container.CgroupnsMode(opts.effectiveCgroupNS())
 
 	networking := &network.NetworkingConfig{}
 	if strings.TrimSpace(opts.Network) != "" {
 		networking.EndpointsConfig = map[string]*network.EndpointSettings{
-			opts.Network: {},
+			opts.Network: {Aliases: opts.NetworkAliases},
+		}
+		if opts.IP != "" {
+			networking.EndpointsConfig[opts.Network].IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: opts.IP}
 		}
 	}
-
-	resp, err := d.cli.ContainerCreate(ctx, config, hostConfig, networking, nil, opts.Name)
-	if err != nil {
-		return "", err
-	}
-
-	if err := d.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", err
-	}
-	return resp.ID, nil
+	return config, hostConfig, networking
 }
 
 func (d *Docker) ContainerExists(ctx context.Context, name string) (bool, error) {
@@ -147,10 +217,72 @@ func (d *Docker) RemoveContainer(ctx context.Context, name string) error {
 	return d.cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true})
 }
 
+func (d *Docker) RenameContainer(ctx context.Context, oldName, newName string) error {
+	return d.cli.ContainerRename(ctx, oldName, newName)
+}
+
+func (d *Docker) RecreateContainer(ctx context.Context, name string, opts RecreateOptions) error {
+	info, err := d.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", name, err)
+	}
+	config, hostConfig, networking := recreatedDockerConfig(info, opts)
+	running := info.State != nil && info.State.Running
+	// Named volumes survive, only anonymous ones would be removed with RemoveVolumes.
+	return replaceContainer(ctx, d, strings.TrimPrefix(info.Name, "/"), running, func(tmpName string) error {
+		_, err := d.cli.ContainerCreate(ctx, config, hostConfig, networking, nil, tmpName)
+		return err
+	})
+}
+
+func (d *Docker) RecreateWithLabels(ctx context.Context, name string, labels map[string]string) error {
+	return d.RecreateContainer(ctx, name, RecreateOptions{Labels: labels})
+}
+
+func (d *Docker) startContainer(ctx context.Context, name string) error {
+	return d.cli.ContainerStart(ctx, name, container.StartOptions{})
+}
+
+// recreatedDockerConfig derives the configs of a replacement for the inspected container.
+func recreatedDockerConfig(info container.InspectResponse, opts RecreateOptions) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+	config := info.Config
+	labels := make(map[string]string, len(config.Labels)+len(opts.Labels))
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	config.Labels = labels
+
+	hostConfig := info.HostConfig
+	binds := make([]string, 0, len(hostConfig.Binds))
+	for _, b := range hostConfig.Binds {
+		binds = append(binds, remapBindSource(b, opts.MountSources))
+	}
+	hostConfig.Binds = binds
+
+	networking := &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{}}
+	if info.NetworkSettings != nil {
+		for name, ep := range info.NetworkSettings.Networks {
+			if ep == nil {
+				continue
+			}
+			networking.EndpointsConfig[name] = &network.EndpointSettings{
+				Aliases:    ep.Aliases,
+				IPAMConfig: ep.IPAMConfig,
+				Links:      ep.Links,
+				DriverOpts: ep.DriverOpts,
+			}
+		}
+	}
+	return config, hostConfig, networking
+}
+
 func (d *Docker) ExecInContainer(ctx context.Context, name string, command []string) (string, int, error) {
 	// Fallback to docker CLI to avoid API type drift
 	args := append([]string{"exec", name}, command...)
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := d.cliCommand(ctx, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		// Try to get exit code
@@ -162,6 +294,128 @@ func (d *Docker) ExecInContainer(ctx context.Context, name string, command []str
 	return string(out), 0, nil
 }
 
+func (d *Docker) ContainerLogs(ctx context.Context, name string, opts LogsOptions, stdout, stderr io.Writer) error {
+	info, err := d.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	logOpts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.Since != "" {
+		// The API only takes timestamps; resolve relative durations like the docker CLI does.
+		if logOpts.Since, err = timetypes.GetTimestamp(opts.Since, time.Now()); err != nil {
+			return fmt.Errorf("invalid since value %q: %w", opts.Since, err)
+		}
+	}
+	if opts.Tail > 0 {
+		logOpts.Tail = strconv.Itoa(opts.Tail)
+	}
+	rc, err := d.cli.ContainerLogs(ctx, name, logOpts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// Output of TTY containers, such as k0da nodes, is not multiplexed.
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(stdout, rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	}
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Docker) ExecInContainerStream(ctx context.Context, name string, command []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	created, err := d.cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          command,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 1, fmt.Errorf("exec create: %w", err)
+	}
+	resp, err := d.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return 1, fmt.Errorf("exec attach: %w", err)
+	}
+	defer resp.Close()
+
+	if stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if _, err := stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		return 1, fmt.Errorf("exec stream: %w", err)
+	}
+
+	insp, err := d.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 1, fmt.Errorf("exec inspect: %w", err)
+	}
+	return insp.ExitCode, nil
+}
+
+func (d *Docker) ExecInContainerTTY(ctx context.Context, name string, command []string, stdin io.Reader, stdout io.Writer) (int, error) {
+	created, err := d.cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          command,
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 1, fmt.Errorf("exec create: %w", err)
+	}
+	resp, err := d.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return 1, fmt.Errorf("exec attach: %w", err)
+	}
+	defer resp.Close()
+
+	// The input stays open until the command exits, closing it would hang up the TTY.
+	go func() { _, _ = io.Copy(resp.Conn, stdin) }()
+	// TTY output is not multiplexed.
+	if _, err := io.Copy(stdout, resp.Reader); err != nil {
+		return 1, fmt.Errorf("exec stream: %w", err)
+	}
+
+	insp, err := d.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 1, fmt.Errorf("exec inspect: %w", err)
+	}
+	return insp.ExitCode, nil
+}
+
+func (d *Docker) ExecInContainerInteractive(ctx context.Context, name string, command []string) (int, error) {
+	// The docker CLI takes care of putting the terminal into raw mode and resizing it
+	args := append([]string{"exec", "-it", name}, command...)
+	cmd := d.cliCommand(ctx, args...)
+	return runInteractive(cmd)
+}
+
+// cliCommand returns a docker CLI command talking to the daemon the runtime uses, which
+// may not be the CLI's default one.
+func (d *Docker) cliCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = append(os.Environ(), "DOCKER_HOST="+d.socket)
+	return cmd
+}
+
 func (d *Docker) GetPortMapping(ctx context.Context, name string, containerPort int, protocol string) (string, int, error) {
 	insp, err := d.cli.ContainerInspect(ctx, name)
 	if err != nil {
@@ -176,7 +430,7 @@ func (d *Docker) GetPortMapping(ctx context.Context, name string, containerPort
 	}
 	// Fallback to docker CLI: docker port <name> <port>/<proto>
 	args := []string{"port", name, fmt.Sprintf("%d/%s", containerPort, proto)}
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := d.cliCommand(ctx, args...)
 	out, err := cmd.CombinedOutput()
 	if err == nil {
 		s := strings.TrimSpace(string(out))
@@ -197,6 +451,24 @@ func (d *Docker) GetPortMapping(ctx context.Context, name string, containerPort
 	return "", 0, fmt.Errorf("port mapping not found")
 }
 
+func (d *Docker) ContainerIP(ctx context.Context, name, network string) (string, error) {
+	insp, err := d.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if insp.NetworkSettings != nil {
+		if ep, ok := insp.NetworkSettings.Networks[network]; ok && ep != nil {
+			if ep.IPAddress != "" {
+				return ep.IPAddress, nil
+			}
+			if ep.GlobalIPv6Address != "" {
+				return ep.GlobalIPv6Address, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("container %s has no IP address on network %s", name, network)
+}
+
 func (d *Docker) VolumeExists(ctx context.Context, name string) (bool, error) {
 	vols, err := d.cli.VolumeList(ctx, volume.ListOptions{Filters: filters.NewArgs(filters.Arg("name", name))})
 	if err != nil {
@@ -225,6 +497,8 @@ func (d *Docker) ListContainersByLabel(ctx context.Context, selector map[string]
 			Name:    strings.TrimPrefix(strings.TrimPrefix(c.Names[0], "/"), "/"),
 			Image:   c.Image,
 			Status:  c.Status,
+			State:   normalizeState(string(c.State), c.Status),
+			Health:  healthFromStatus(c.Status),
 			Ports:   formatPorts(c.Ports),
 			Created: c.Created,
 			Labels:  c.Labels,
@@ -236,7 +510,7 @@ func (d *Docker) ListContainersByLabel(ctx context.Context, selector map[string]
 
 // CopyToContainer copies a local path into the container
 func (d *Docker) CopyToContainer(ctx context.Context, name string, srcPath string, dstPath string) error {
-	cmd := exec.CommandContext(ctx, "docker", "cp", srcPath, name+":"+dstPath)
+	cmd := d.cliCommand(ctx, "cp", srcPath, name+":"+dstPath)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker cp failed: %s", string(out))
@@ -244,9 +518,40 @@ func (d *Docker) CopyToContainer(ctx context.Context, name string, srcPath strin
 	return nil
 }
 
+// PullImage pulls an image and waits for the pull to complete.
+func (d *Docker) PullImage(ctx context.Context, ref string) error {
+	rc, err := d.cli.ImagePull(ctx, ref, imageTypes.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// ImageExists reports whether the image is present in the Docker daemon.
+func (d *Docker) ImageExists(ctx context.Context, ref string) (bool, error) {
+	if _, err := d.cli.ImageInspect(ctx, ref); err != nil {
+		if dockerClient.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ImageID returns the ID of a local Docker image.
+func (d *Docker) ImageID(ctx context.Context, ref string) (string, error) {
+	img, err := d.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("docker image inspect failed: %w", err)
+	}
+	return img.ID, nil
+}
+
 // SaveImageToTar saves a local Docker image into a tar archive
 func (d *Docker) SaveImageToTar(ctx context.Context, imageRef string, tarPath string) error {
-	cmd := exec.CommandContext(ctx, "docker", "save", "-o", tarPath, imageRef)
+	cmd := d.cliCommand(ctx, "save", "-o", tarPath, imageRef)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker save failed: %s", string(out))
@@ -254,6 +559,15 @@ func (d *Docker) SaveImageToTar(ctx context.Context, imageRef string, tarPath st
 	return nil
 }
 
+// SaveImage streams a local Docker image as a tar archive from the daemon.
+func (d *Docker) SaveImage(ctx context.Context, imageRef string) (io.ReadCloser, error) {
+	rc, err := d.cli.ImageSave(ctx, []string{imageRef})
+	if err != nil {
+		return nil, fmt.Errorf("docker image save failed: %w", err)
+	}
+	return rc, nil
+}
+
 func atoiSafe(s string) int { n, _ := strconv.Atoi(s); return n }
 
 func formatPorts(ports []container.Port) string {
@@ -298,18 +612,22 @@ func natPortBindings(publish []PortSpec) nat.PortMap {
 }
 
 // EnsureNetwork ensures a user-defined bridge network exists with the given name.
-func (d *Docker) EnsureNetwork(ctx context.Context, name string) error {
+func (d *Docker) EnsureNetwork(ctx context.Context, name string, opts NetworkOptions) error {
 	if strings.TrimSpace(name) == "" {
 		return nil
 	}
 	// Check: docker network inspect <name>
-	cmd := exec.CommandContext(ctx, "docker", "network", "inspect", name)
+	cmd := d.cliCommand(ctx, "network", "inspect", name)
 	if out, err := cmd.CombinedOutput(); err == nil && len(out) > 0 {
 		return nil
 	}
 	// Create
-	args := []string{"network", "create", "--driver", "bridge", "--attachable", "--label", "k0da.network=true", "--label", "k0da.network.name=" + name, name}
-	cmd = exec.CommandContext(ctx, "docker", args...)
+	args := []string{"network", "create", "--driver", "bridge", "--attachable", "--label", "k0da.network=true", "--label", "k0da.network.name=" + name}
+	if opts.Subnet != "" {
+		args = append(args, "--subnet", opts.Subnet)
+	}
+	args = append(args, name)
+	cmd = d.cliCommand(ctx, args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("docker network create failed: %s", strings.TrimSpace(string(out)))
@@ -317,3 +635,36 @@ func (d *Docker) EnsureNetwork(ctx context.Context, name string) error {
 	_ = out
 	return nil
 }
+
+func (d *Docker) ConnectNetwork(ctx context.Context, container, network string) error {
+	if err := d.cli.NetworkConnect(ctx, network, container, nil); err != nil {
+		return fmt.Errorf("failed to connect %s to network %s: %w", container, network, err)
+	}
+	return nil
+}
+
+// NetworkSubnets returns the subnets of the network's IPAM config.
+func (d *Docker) NetworkSubnets(ctx context.Context, name string) ([]string, error) {
+	resp, err := d.cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect network %s: %w", name, err)
+	}
+	var subnets []string
+	for _, c := range resp.IPAM.Config {
+		if c.Subnet != "" {
+			subnets = append(subnets, c.Subnet)
+		}
+	}
+	return subnets, nil
+}
+
+// NetworkExists reports whether a network with the given name exists.
+func (d *Docker) NetworkExists(ctx context.Context, name string) (bool, error) {
+	if _, err := d.cli.NetworkInspect(ctx, name, network.InspectOptions{}); err != nil {
+		if dockerClient.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
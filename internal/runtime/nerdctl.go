@@ -0,0 +1,226 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Nerdctl implements Runtime with the nerdctl CLI of containerd. With Lima the CLI is
+// the nerdctl.lima wrapper, which runs nerdctl inside the VM.
+type Nerdctl struct {
+	cliRuntime
+	// address is the containerd socket passed with --address; empty for the default.
+	address string
+	// namespace is the containerd namespace passed with --namespace; empty for the default.
+	namespace string
+}
+
+func init() {
+	Register("nerdctl", func(ctx context.Context, opts FactoryOptions) (Runtime, error) {
+		n, err := NewNerdctlRuntime(ctx, opts.Socket)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	})
+}
+
+// NewNerdctlRuntime returns a nerdctl backend. The CLI is $K0DA_NERDCTL, nerdctl, or
+// Lima's nerdctl.lima, whichever is found first. The socket is used as the containerd
+// address only when it is a containerd socket, and $K0DA_NERDCTL_NAMESPACE selects the
+// containerd namespace.
+func NewNerdctlRuntime(ctx context.Context, socket string) (*Nerdctl, error) {
+	binary, err := nerdctlBinary()
+	if err != nil {
+		return nil, err
+	}
+	n := newNerdctl(binary, nerdctlAddress(socket), strings.TrimSpace(os.Getenv("K0DA_NERDCTL_NAMESPACE")))
+	probeCtx, cancel := context.WithTimeout(ctx, detectCommandTimeout)
+	defer cancel()
+	cmd := n.command(probeCtx, "version", "--format", "{{.Client.Version}}")
+	cmd.WaitDelay = time.Second
+	if out, err := cmd.CombinedOutput(); err != nil || len(strings.TrimSpace(string(out))) == 0 {
+		return nil, fmt.Errorf("nerdctl CLI not available or unreachable: %s", strings.TrimSpace(string(out)))
+	}
+	return n, nil
+}
+
+// newNerdctl returns a Nerdctl running binary against the containerd address and
+// namespace; empty ones use the nerdctl defaults.
+func newNerdctl(binary, address, namespace string) *Nerdctl {
+	n := &Nerdctl{address: address, namespace: namespace}
+	n.cliRuntime = cliRuntime{name: "nerdctl", binary: binary, prefixArgs: n.globalArgs}
+	return n
+}
+
+// nerdctlBinary returns the nerdctl CLI to use.
+func nerdctlBinary() (string, error) {
+	if v := strings.TrimSpace(os.Getenv("K0DA_NERDCTL")); v != "" {
+		return v, nil
+	}
+	for _, name := range []string{"nerdctl", "nerdctl.lima"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", errors.New("nerdctl not found in PATH")
+}
+
+// nerdctlAddress returns the containerd socket path of socket, or "" when socket is
+// not a containerd socket, e.g. the Docker socket Detect guesses for auto-detection.
+func nerdctlAddress(socket string) string {
+	path := strings.TrimPrefix(strings.TrimSpace(socket), "unix://")
+	if path == "" || strings.Contains(path, "://") || !strings.Contains(filepath.Base(path), "containerd") {
+		return ""
+	}
+	return path
+}
+
+func (n *Nerdctl) Describe() string {
+	var details []string
+	if n.binary != "nerdctl" {
+		details = append(details, n.binary)
+	}
+	if n.address != "" {
+		details = append(details, "unix://"+n.address)
+	}
+	if n.namespace != "" {
+		details = append(details, "namespace "+n.namespace)
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("%s (default address)", n.name)
+	}
+	return fmt.Sprintf("%s (%s)", n.name, strings.Join(details, ", "))
+}
+
+// globalArgs returns the --address and --namespace flags.
+func (n *Nerdctl) globalArgs() []string {
+	var args []string
+	if n.address != "" {
+		args = append(args, "--address", n.address)
+	}
+	if n.namespace != "" {
+		args = append(args, "--namespace", n.namespace)
+	}
+	return args
+}
+
+// Version returns the version of containerd, falling back to the nerdctl version.
+func (n *Nerdctl) Version(ctx context.Context) (string, error) {
+	out, err := n.command(ctx, "version", "--format", "{{json .}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("nerdctl version failed: %w", err)
+	}
+	var v struct {
+		Client struct{ Version string }
+		Server *struct {
+			Components []struct{ Name, Version string }
+		}
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return "", fmt.Errorf("parse nerdctl version: %w", err)
+	}
+	if v.Server != nil {
+		for _, c := range v.Server.Components {
+			if c.Name == "containerd" && c.Version != "" {
+				return c.Version, nil
+			}
+		}
+	}
+	if v.Client.Version != "" {
+		return v.Client.Version, nil
+	}
+	return "", fmt.Errorf("nerdctl version not reported")
+}
+
+// RecreateContainer is not supported: nerdctl doesn't record the options a container
+// was created with.
+func (n *Nerdctl) RecreateContainer(ctx context.Context, name string, opts RecreateOptions) error {
+	return fmt.Errorf("recreating containers is not supported with nerdctl")
+}
+
+func (n *Nerdctl) RecreateWithLabels(ctx context.Context, name string, labels map[string]string) error {
+	return n.RecreateContainer(ctx, name, RecreateOptions{Labels: labels})
+}
+
+func (n *Nerdctl) ListContainersByLabel(ctx context.Context, selector map[string]string, includeStopped bool) ([]ContainerInfo, error) {
+	out, err := n.ps(ctx, "{{json .}}", selector, includeStopped)
+	if err != nil {
+		return nil, err
+	}
+	return parseNerdctlPS(out)
+}
+
+// parseNerdctlPS converts `nerdctl ps --format '{{json .}}'` output, one JSON object
+// per line in the docker format, into ContainerInfo values.
+func parseNerdctlPS(out []byte) ([]ContainerInfo, error) {
+	var list []ContainerInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var c struct {
+			ID        string
+			Names     string
+			Image     string
+			Status    string
+			Ports     string
+			Labels    string
+			CreatedAt string
+		}
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse nerdctl ps output: %w", err)
+		}
+		ci := ContainerInfo{
+			ID:     c.ID,
+			Name:   strings.TrimPrefix(c.Names, "/"),
+			Image:  c.Image,
+			Status: c.Status,
+			Ports:  c.Ports,
+			Labels: parseNerdctlLabels(c.Labels),
+		}
+		ci.State = normalizeState("", ci.Status)
+		ci.Health = healthFromStatus(ci.Status)
+		for _, layout := range podmanCreatedLayouts {
+			if t, err := time.Parse(layout, c.CreatedAt); err == nil {
+				ci.Created = t.Unix()
+				break
+			}
+		}
+		list = append(list, ci)
+	}
+	return list, nil
+}
+
+// parseNerdctlLabels parses the comma separated key=value labels of `nerdctl ps`.
+// Values can contain commas themselves, e.g. k0da's list of cluster label keys, so a
+// part without "=" continues the previous value.
+func parseNerdctlLabels(s string) map[string]string {
+	labels := map[string]string{}
+	last := ""
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			if last != "" {
+				labels[last] += "," + part
+			}
+			continue
+		}
+		labels[k] = v
+		last = k
+	}
+	return labels
+}
+
+// ConnectNetwork is not supported: nerdctl can only attach networks when a container
+// is created.
+func (n *Nerdctl) ConnectNetwork(ctx context.Context, container, network string) error {
+	return fmt.Errorf("failed to connect %s to network %s: nerdctl cannot connect running containers to networks", container, network)
+}
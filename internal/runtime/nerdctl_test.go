@@ -0,0 +1,34 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nerdctl ps --format '{{json .}}': one object per line, labels as a single string
+const nerdctlPS = `{"Command":"\"/entrypoint.sh k0s…\"","CreatedAt":"2024-06-21 20:00:00 +0000 UTC","ID":"0123456789ab","Image":"quay.io/k0sproject/k0s:v1.33.3-k0s.0","Labels":"k0da.cluster=true,k0da.cluster.name=demo,k0da.cluster.labels=env,team","Names":"demo","Ports":"0.0.0.0:40001->6443/tcp","Status":"Up"}
+{"Command":"\"/entrypoint.sh k0s…\"","CreatedAt":"2024-06-21 20:00:00 +0000 UTC","ID":"fedcba987654","Image":"quay.io/k0sproject/k0s:v1.33.3-k0s.0","Labels":"","Names":"demo-worker-0","Ports":"","Status":"Exited (0) 2 minutes ago"}
+`
+
+func TestParseNerdctlPS(t *testing.T) {
+	list, err := parseNerdctlPS([]byte(nerdctlPS))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "0123456789ab", list[0].ID)
+	require.Equal(t, "demo", list[0].Name)
+	require.Equal(t, int64(1719000000), list[0].Created)
+	require.Equal(t, "0.0.0.0:40001->6443/tcp", list[0].Ports)
+	require.Equal(t, map[string]string{"k0da.cluster": "true", "k0da.cluster.name": "demo", "k0da.cluster.labels": "env,team"}, list[0].Labels)
+	require.Equal(t, StateRunning, list[0].State)
+	require.Empty(t, list[1].Labels)
+	require.Equal(t, StateExited, list[1].State)
+}
+
+func TestNerdctlAddress(t *testing.T) {
+	require.Equal(t, "/run/containerd/containerd.sock", nerdctlAddress("unix:///run/containerd/containerd.sock"))
+	require.Equal(t, "/run/user/1000/containerd-rootless/containerd.sock", nerdctlAddress("/run/user/1000/containerd-rootless/containerd.sock"))
+	require.Empty(t, nerdctlAddress("unix:///var/run/docker.sock"))
+	require.Empty(t, nerdctlAddress("tcp://127.0.0.1:2375"))
+	require.Empty(t, nerdctlAddress(""))
+}
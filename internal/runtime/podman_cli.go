@@ -7,16 +7,29 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Podman implements Runtime using the podman CLI only (no cgo, no gpgme).
 type Podman struct {
-	name       string
+	cliRuntime
 	socket     string
 	identity   string
 	connection string
+	rootless   bool
+}
+
+func init() {
+	Register("podman", func(ctx context.Context, opts FactoryOptions) (Runtime, error) {
+		p, err := NewPodmanRuntime(ctx, opts.Socket, opts.Identity)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
 }
 
 func NewPodmanRuntime(ctx context.Context, socket string, identity string) (*Podman, error) {
@@ -27,51 +40,113 @@ func NewPodmanRuntime(ctx context.Context, socket string, identity string) (*Pod
 			connName = name
 		}
 	}
+	p := newPodman(socket, identity, connName)
 
 	// Validate connectivity via CLI. Respect connection if provided, else use CONTAINER_HOST when given.
-	args := []string{"version", "--format", "{{.Version}}"}
-	if connName != "" {
-		args = append([]string{"--connection", connName}, args...)
+	probeCtx, cancel := context.WithTimeout(ctx, detectCommandTimeout)
+	defer cancel()
+	cmd := p.command(probeCtx, "version", "--format", "{{.Version}}")
+	cmd.WaitDelay = time.Second
+	if out, err := cmd.CombinedOutput(); err != nil || len(strings.TrimSpace(string(out))) == 0 {
+		return nil, fmt.Errorf("podman CLI not available or unreachable: %s", strings.TrimSpace(string(out)))
 	}
-	cmd := exec.CommandContext(ctx, "podman", args...)
-	env := os.Environ()
-	if connName == "" && socket != "" {
-		env = append(env, "CONTAINER_HOST="+socket)
+	p.rootless = p.isRootless(probeCtx)
+	return p, nil
+}
+
+// newPodman returns a Podman talking to the named connection or, without one, to socket.
+func newPodman(socket, identity, connection string) *Podman {
+	p := &Podman{socket: socket, identity: identity, connection: connection}
+	p.cliRuntime = cliRuntime{name: "podman", binary: "podman", prefixArgs: p.connectionArgs, extraEnv: p.env}
+	return p
+}
+
+// Rootless reports whether the podman service runs without root privileges.
+func (p *Podman) Rootless() bool { return p.rootless }
+
+// isRootless queries `podman info` for the rootless flag of the (possibly remote) service.
+func (p *Podman) isRootless(ctx context.Context) bool {
+	out, err := p.command(ctx, "info", "--format", "{{.Host.Security.Rootless}}").Output()
+	if err != nil {
+		return false
 	}
-	// identity (ssh key) handled by CONTAINER_SSHKEY if using ssh://
-	if connName == "" && strings.HasPrefix(socket, "ssh://") && identity != "" && os.Getenv("CONTAINER_SSHKEY") == "" {
-		env = append(env, "CONTAINER_SSHKEY="+identity)
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+// RunContainer rejects the host cgroup namespace under rootless podman: k0s needs a
+// private one to manage the delegated cgroup v2 subtree as its own root.
+func (p *Podman) RunContainer(ctx context.Context, opts RunContainerOptions) (string, error) {
+	if p.rootless && opts.effectiveCgroupNS() == "host" {
+		return "", fmt.Errorf("cgroupns host is not supported with rootless podman")
 	}
-	cmd.Env = env
-	if out, err := cmd.CombinedOutput(); err != nil || len(strings.TrimSpace(string(out))) == 0 {
-		return nil, fmt.Errorf("podman CLI not available or unreachable: %s", strings.TrimSpace(string(out)))
+	return p.cliRuntime.RunContainer(ctx, opts)
+}
+
+func (p *Podman) Describe() string {
+	mode := ""
+	if p.rootless {
+		mode = ", rootless"
+	}
+	switch {
+	case strings.TrimSpace(p.connection) != "":
+		return fmt.Sprintf("%s (connection %s%s)", p.name, p.connection, mode)
+	case strings.TrimSpace(p.socket) != "":
+		return fmt.Sprintf("%s (%s%s)", p.name, p.socket, mode)
+	default:
+		return fmt.Sprintf("%s (default connection%s)", p.name, mode)
 	}
-	return &Podman{name: "podman", socket: socket, identity: identity, connection: connName}, nil
 }
 
-func (p *Podman) Name() string { return p.name }
+// Version returns the podman server version, falling back to the client version
+// when podman runs locally without a service.
+func (p *Podman) Version(ctx context.Context) (string, error) {
+	out, err := p.command(ctx, "version", "--format", "json").Output()
+	if err != nil {
+		return "", fmt.Errorf("podman version failed: %w", err)
+	}
+	var v struct {
+		Client *struct{ Version string }
+		Server *struct{ Version string }
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return "", fmt.Errorf("parse podman version: %w", err)
+	}
+	if v.Server != nil && v.Server.Version != "" {
+		return v.Server.Version, nil
+	}
+	if v.Client != nil && v.Client.Version != "" {
+		return v.Client.Version, nil
+	}
+	return "", fmt.Errorf("podman version not reported")
+}
 
-func (p *Podman) withEnv(cmd *exec.Cmd) *exec.Cmd {
-	env := os.Environ()
+// env returns CONTAINER_HOST and, for ssh:// sockets, CONTAINER_SSHKEY when podman is
+// reached through the socket rather than a named connection.
+func (p *Podman) env() []string {
+	var env []string
 	if p.connection == "" && p.socket != "" {
 		env = append(env, "CONTAINER_HOST="+p.socket)
 	}
+	// identity (ssh key) handled by CONTAINER_SSHKEY if using ssh://
 	if p.connection == "" && strings.HasPrefix(p.socket, "ssh://") && strings.TrimSpace(p.identity) != "" && os.Getenv("CONTAINER_SSHKEY") == "" {
 		env = append(env, "CONTAINER_SSHKEY="+p.identity)
 	}
-	cmd.Env = env
-	return cmd
+	return env
 }
 
-func (p *Podman) argsWithConnection(args []string) []string {
+// connectionArgs returns the --connection flag when a named connection is used.
+func (p *Podman) connectionArgs() []string {
 	if strings.TrimSpace(p.connection) != "" {
-		return append([]string{"--connection", p.connection}, args...)
+		return []string{"--connection", p.connection}
 	}
-	return args
+	return nil
 }
 
-func (p *Podman) RunContainer(ctx context.Context, opts RunContainerOptions) (string, error) {
-	args := []string{"run", "-d", "--restart", "always"}
+// cliRunArgs builds docker-compatible `run` arguments for opts with the k0s defaults
+// applied, as podman and nerdctl take them.
+func cliRunArgs(opts RunContainerOptions) []string {
+	opts = withK0sDefaults(opts)
+	args := []string{"run", "-d", "--restart", opts.effectiveRestartPolicy(), "--cgroupns", opts.effectiveCgroupNS(), "--pull", opts.effectivePullPolicy()}
 	if strings.TrimSpace(opts.Name) != "" {
 		args = append(args, "--name", opts.Name)
 	}
@@ -115,8 +190,53 @@ func (p *Podman) RunContainer(ctx context.Context, opts RunContainerOptions) (st
 			args = append(args, "--security-opt", s)
 		}
 	}
+	for _, c := range opts.CapAdd {
+		args = append(args, "--cap-add", c)
+	}
+	for _, c := range opts.CapDrop {
+		args = append(args, "--cap-drop", c)
+	}
+	for _, u := range opts.Ulimits {
+		args = append(args, "--ulimit", fmt.Sprintf("%s=%d:%d", u.Name, u.Soft, u.Hard))
+	}
+	for _, d := range opts.DNS {
+		args = append(args, "--dns", d)
+	}
+	for _, d := range opts.DNSSearch {
+		args = append(args, "--dns-search", d)
+	}
+	for _, h := range opts.ExtraHosts {
+		args = append(args, "--add-host", h)
+	}
+	for _, d := range opts.Devices {
+		args = append(args, "--device", d)
+	}
+	if strings.TrimSpace(opts.OCIRuntime) != "" {
+		args = append(args, "--runtime", opts.OCIRuntime)
+	}
+	if h := opts.Healthcheck; h != nil {
+		args = append(args, "--health-cmd", strings.Join(h.Command, " "))
+		if h.Interval > 0 {
+			args = append(args, "--health-interval", h.Interval.String())
+		}
+		if h.Timeout > 0 {
+			args = append(args, "--health-timeout", h.Timeout.String())
+		}
+		if h.StartPeriod > 0 {
+			args = append(args, "--health-start-period", h.StartPeriod.String())
+		}
+		if h.Retries > 0 {
+			args = append(args, "--health-retries", strconv.Itoa(h.Retries))
+		}
+	}
 	if strings.TrimSpace(opts.Network) != "" {
 		args = append(args, "--network", opts.Network)
+		for _, a := range opts.NetworkAliases {
+			args = append(args, "--network-alias", a)
+		}
+		if opts.IP != "" {
+			args = append(args, "--ip", opts.IP)
+		}
 	}
 	if len(opts.Publish) > 0 {
 		for _, ps := range opts.Publish {
@@ -137,124 +257,137 @@ func (p *Podman) RunContainer(ctx context.Context, opts RunContainerOptions) (st
 			args = append(args, "-p", fmt.Sprintf("%s%d:%d/%s", prefix, ps.HostPort, ps.ContainerPort, proto))
 		}
 	}
-	// Image then command args
-	if strings.TrimSpace(opts.Image) == "" {
-		return "", errors.New("image is required")
-	}
 	args = append(args, opts.Image)
 	if len(opts.Args) > 0 {
 		args = append(args, opts.Args...)
 	}
+	return args
+}
 
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection(args)...))
-	out, err := cmd.CombinedOutput()
+// RecreateContainer re-creates the container from the command it was created with, podman
+// keeps it as .Config.CreateCommand, with the labels and mount sources of opts applied.
+func (p *Podman) RecreateContainer(ctx context.Context, name string, opts RecreateOptions) error {
+	out, err := p.command(ctx, "container", "inspect", name, "--format", "{{json .Config.CreateCommand}}").CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("podman run failed: %s", strings.TrimSpace(string(out)))
+		return fmt.Errorf("failed to inspect container %s: %s", name, strings.TrimSpace(string(out)))
 	}
-	return strings.TrimSpace(string(out)), nil
-}
-
-func (p *Podman) ContainerExists(ctx context.Context, name string) (bool, error) {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"inspect", "-t", "container", name})...))
-	if err := cmd.Run(); err != nil {
-		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() != 0 {
-			return false, nil
-		}
-		return false, err
+	var createCommand []string
+	if err := json.Unmarshal(out, &createCommand); err != nil {
+		return fmt.Errorf("failed to parse create command of %s: %w", name, err)
 	}
-	return true, nil
-}
-
-func (p *Podman) ContainerIsRunning(ctx context.Context, name string) (bool, error) {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"inspect", "-t", "container", name, "--format", "{{.State.Running}}"})...))
-	out, err := cmd.CombinedOutput()
+	if _, err := recreateCreateArgs(createCommand, name, opts); err != nil {
+		return fmt.Errorf("cannot recreate container %s: %w", name, err)
+	}
+	running, err := p.ContainerIsRunning(ctx, name)
 	if err != nil {
-		return false, err
+		return err
 	}
-	return strings.TrimSpace(string(out)) == "true", nil
+	return replaceContainer(ctx, p, name, running, func(tmpName string) error {
+		args, _ := recreateCreateArgs(createCommand, tmpName, opts)
+		if out, err := p.command(ctx, args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("podman create failed: %s", strings.TrimSpace(string(out)))
+		}
+		return nil
+	})
 }
 
-func (p *Podman) StopContainer(ctx context.Context, name string) error {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"stop", name})...))
-	_, err := cmd.CombinedOutput()
-	return err
+func (p *Podman) RecreateWithLabels(ctx context.Context, name string, labels map[string]string) error {
+	return p.RecreateContainer(ctx, name, RecreateOptions{Labels: labels})
 }
 
-func (p *Podman) RemoveContainer(ctx context.Context, name string) error {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"rm", "-f", name})...))
-	_, err := cmd.CombinedOutput()
-	return err
+func (p *Podman) startContainer(ctx context.Context, name string) error {
+	if out, err := p.command(ctx, "start", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("podman start failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
 }
 
-func (p *Podman) ExecInContainer(ctx context.Context, name string, command []string) (string, int, error) {
-	args := append([]string{"exec", name}, command...)
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection(args)...))
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			return string(out), ee.ExitCode(), nil
+// recreateCreateArgs turns the create command of a container into `podman create`
+// arguments for a replacement named name: labels in opts replace existing ones with the
+// same key, bind mount sources are remapped and the run-only detach flag is dropped.
+func recreateCreateArgs(createCommand []string, name string, opts RecreateOptions) ([]string, error) {
+	start := -1
+	for i, a := range createCommand {
+		if a == "run" {
+			start = i + 1
+			break
 		}
-		return string(out), 1, err
 	}
-	return string(out), 0, nil
-}
+	if start < 0 {
+		return nil, errors.New("container was not created with podman run")
+	}
 
-func (p *Podman) GetPortMapping(ctx context.Context, name string, containerPort int, protocol string) (string, int, error) {
-	proto := strings.ToLower(protocol)
-	if proto == "" {
-		proto = "tcp"
+	overridden := func(label string) bool {
+		_, ok := opts.Labels[strings.SplitN(label, "=", 2)[0]]
+		return ok
 	}
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"port", name, fmt.Sprintf("%d/%s", containerPort, proto)})...))
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", 0, fmt.Errorf("podman port failed: %s", strings.TrimSpace(string(out)))
-	}
-	s := strings.TrimSpace(string(out))
-	if s == "" {
-		return "", 0, fmt.Errorf("port mapping not found")
-	}
-	idx := strings.LastIndex(s, ":")
-	if idx == -1 || idx+1 >= len(s) {
-		return "", 0, fmt.Errorf("unexpected port output: %s", s)
-	}
-	host := strings.TrimSpace(s[:idx])
-	portStr := strings.TrimSpace(s[idx+1:])
-	host = strings.TrimPrefix(host, "[")
-	host = strings.TrimSuffix(host, "]")
-	n, _ := strconv.Atoi(portStr)
-	return host, n, nil
-}
+	keys := make([]string, 0, len(opts.Labels))
+	for k := range opts.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
-func (p *Podman) VolumeExists(ctx context.Context, name string) (bool, error) {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"volume", "inspect", name})...))
-	if err := cmd.Run(); err != nil {
-		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() != 0 {
-			return false, nil
+	args := []string{"create"}
+	for _, k := range keys {
+		args = append(args, "--label", k+"="+opts.Labels[k])
+	}
+	rest := createCommand[start:]
+	for i := 0; i < len(rest); i++ {
+		a := rest[i]
+		next := ""
+		if i+1 < len(rest) {
+			next = rest[i+1]
+		}
+		switch {
+		case a == "-d" || a == "--detach" || strings.HasPrefix(a, "--detach="):
+		case a == "--name":
+			args = append(args, a, name)
+			i++
+		case strings.HasPrefix(a, "--name="):
+			args = append(args, "--name="+name)
+		case (a == "--label" || a == "-l") && overridden(next):
+			i++
+		case strings.HasPrefix(a, "--label=") && overridden(strings.TrimPrefix(a, "--label=")):
+		case a == "-v" || a == "--volume":
+			args = append(args, a, remapBindSource(next, opts.MountSources))
+			i++
+		case strings.HasPrefix(a, "--volume="):
+			args = append(args, "--volume="+remapBindSource(strings.TrimPrefix(a, "--volume="), opts.MountSources))
+		default:
+			args = append(args, a)
 		}
-		return false, err
 	}
-	return true, nil
+	return args, nil
 }
 
-func (p *Podman) RemoveVolume(ctx context.Context, name string) error {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"volume", "rm", "-f", name})...))
-	_, err := cmd.CombinedOutput()
-	return err
+// parseNetworkIP returns the address of network in the JSON of an inspected
+// container's NetworkSettings.Networks, preferring IPv4.
+func parseNetworkIP(data []byte, network string) (string, error) {
+	var networks map[string]struct {
+		IPAddress         string
+		GlobalIPv6Address string
+	}
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return "", fmt.Errorf("failed to parse container networks: %w", err)
+	}
+	ep := networks[network]
+	if ep.IPAddress != "" {
+		return ep.IPAddress, nil
+	}
+	return ep.GlobalIPv6Address, nil
 }
 
 func (p *Podman) ListContainersByLabel(ctx context.Context, selector map[string]string, includeStopped bool) ([]ContainerInfo, error) {
-	args := []string{"ps", "--format", "json"}
-	if includeStopped {
-		args = append(args, "-a")
-	}
-	for k, v := range selector {
-		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
-	}
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection(args)...))
-	out, err := cmd.CombinedOutput()
+	out, err := p.ps(ctx, "json", selector, includeStopped)
 	if err != nil {
-		return nil, fmt.Errorf("podman ps failed: %s", strings.TrimSpace(string(out)))
+		return nil, err
 	}
+	return parsePodmanPS(out)
+}
+
+// parsePodmanPS converts `podman ps --format json` output into ContainerInfo values.
+// Field names and types differ between podman versions, so each field is read leniently.
+func parsePodmanPS(out []byte) ([]ContainerInfo, error) {
 	var arr []map[string]any
 	if err := json.Unmarshal(out, &arr); err != nil {
 		return nil, err
@@ -271,15 +404,18 @@ func (p *Podman) ListContainersByLabel(ctx context.Context, selector map[string]
 			if s, ok2 := v[0].(string); ok2 {
 				ci.Name = strings.TrimPrefix(strings.TrimSpace(s), "/")
 			}
+		} else if s, ok := m["Names"].(string); ok {
+			ci.Name = strings.TrimPrefix(strings.TrimSpace(s), "/")
 		} else if s, ok := m["Name"].(string); ok {
 			ci.Name = strings.TrimPrefix(strings.TrimSpace(s), "/")
 		}
 		if s, ok := m["Image"].(string); ok {
 			ci.Image = s
 		}
-		if s, ok := m["Status"].(string); ok {
-			ci.Status = s
-		}
+		ci.Status = podmanStatus(m)
+		state, _ := m["State"].(string)
+		ci.State = normalizeState(state, ci.Status)
+		ci.Health = healthFromStatus(ci.Status)
 		if labels, ok := m["Labels"].(map[string]any); ok {
 			ci.Labels = map[string]string{}
 			for k, v := range labels {
@@ -290,90 +426,163 @@ func (p *Podman) ListContainersByLabel(ctx context.Context, selector map[string]
 		}
 		// Ports formatting
 		if ports, ok := m["Ports"].([]any); ok {
-			var b strings.Builder
-			for i, pi := range ports {
-				pm, ok2 := pi.(map[string]any)
-				if !ok2 {
-					continue
-				}
-				if i > 0 {
-					b.WriteString(", ")
-				}
-				hostIP := "0.0.0.0"
-				if hip, ok3 := pm["HostIp"].(string); ok3 && hip != "" {
-					hostIP = hip
-				}
-				var hostPort, contPort int
-				if hp, ok3 := pm["HostPort"].(float64); ok3 {
-					hostPort = int(hp)
-				}
-				if cp, ok3 := pm["ContainerPort"].(float64); ok3 {
-					contPort = int(cp)
-				}
-				proto := "tcp"
-				if pr, ok3 := pm["Protocol"].(string); ok3 && pr != "" {
-					proto = strings.ToLower(pr)
-				}
-				if hostPort == 0 {
-					fmt.Fprintf(&b, "%s:%d->%d/%s", hostIP, contPort, contPort, proto)
-				} else {
-					fmt.Fprintf(&b, "%s:%d->%d/%s", hostIP, hostPort, contPort, proto)
-				}
-			}
-			ci.Ports = b.String()
-		}
-		// Created timestamp if available
-		if ts, ok := m["Created"].(float64); ok {
-			ci.Created = int64(ts)
+			ci.Ports = formatPodmanPorts(ports)
 		}
+		ci.Created = podmanCreated(m)
 		outList = append(outList, ci)
 	}
 	return outList, nil
 }
 
-func (p *Podman) CopyToContainer(ctx context.Context, name string, srcPath string, dstPath string) error {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"cp", srcPath, name + ":" + dstPath})...))
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("podman cp failed: %s", strings.TrimSpace(string(out)))
+// podmanStatus returns the docker-style status of a `podman ps` entry. Newer podman
+// versions may leave Status empty and only report State and ExitCode, from which the
+// same form is rebuilt, e.g. "Exited (1)".
+func podmanStatus(m map[string]any) string {
+	if s, ok := m["Status"].(string); ok && strings.TrimSpace(s) != "" {
+		return s
+	}
+	state, _ := m["State"].(string)
+	if state == "" {
+		return ""
+	}
+	if state == "exited" || state == "stopped" {
+		code, _ := m["ExitCode"].(float64)
+		return fmt.Sprintf("Exited (%d)", int(code))
+	}
+	return strings.ToUpper(state[:1]) + state[1:]
+}
+
+// formatPodmanPorts renders podman port entries, which use "HostPort" style keys in
+// podman 3 and "host_port" style keys in podman 4+.
+func formatPodmanPorts(ports []any) string {
+	var b strings.Builder
+	n := 0
+	for _, pi := range ports {
+		pm, ok := pi.(map[string]any)
+		if !ok {
+			continue
+		}
+		if n > 0 {
+			b.WriteString(", ")
+		}
+		n++
+		hostIP := "0.0.0.0"
+		if hip, ok := firstString(pm, "HostIp", "hostIP", "host_ip"); ok && hip != "" {
+			hostIP = hip
+		}
+		hostPort := firstInt(pm, "HostPort", "hostPort", "host_port")
+		contPort := firstInt(pm, "ContainerPort", "containerPort", "container_port")
+		proto := "tcp"
+		if pr, ok := firstString(pm, "Protocol", "protocol"); ok && pr != "" {
+			proto = strings.ToLower(pr)
+		}
+		if hostPort == 0 {
+			fmt.Fprintf(&b, "%s:%d->%d/%s", hostIP, contPort, contPort, proto)
+		} else {
+			fmt.Fprintf(&b, "%s:%d->%d/%s", hostIP, hostPort, contPort, proto)
+		}
 	}
-	return nil
+	return b.String()
 }
 
-func (p *Podman) SaveImageToTar(ctx context.Context, imageRef string, tarPath string) error {
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"save", "-o", tarPath, imageRef})...))
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("podman save failed: %s", strings.TrimSpace(string(out)))
+// podmanCreatedLayouts are the timestamp layouts podman has used for created times.
+var podmanCreatedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05 -0700 MST",
+}
+
+// podmanCreated extracts the creation time as unix seconds. Depending on the version,
+// podman reports "Created" as a number or a timestamp string, and "CreatedAt" as a
+// timestamp string (or a humanized value that cannot be parsed). Returns 0 if unknown.
+func podmanCreated(m map[string]any) int64 {
+	for _, key := range []string{"Created", "CreatedAt"} {
+		switch v := m[key].(type) {
+		case float64:
+			if v > 0 {
+				return int64(v)
+			}
+		case string:
+			s := strings.TrimSpace(v)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+				return n
+			}
+			for _, layout := range podmanCreatedLayouts {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t.Unix()
+				}
+			}
+		}
 	}
-	return nil
+	return 0
 }
 
-// EnsureNetwork ensures a user-defined network exists with the given name.
-func (p *Podman) EnsureNetwork(ctx context.Context, name string) error {
-	if strings.TrimSpace(name) == "" {
-		return nil
+func firstString(m map[string]any, keys ...string) (string, bool) {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok {
+			return s, true
+		}
 	}
-	// podman network inspect <name>
-	cmd := p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"network", "inspect", name})...))
-	if err := cmd.Run(); err == nil {
-		return nil
+	return "", false
+}
+
+func firstInt(m map[string]any, keys ...string) int {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case float64:
+			return int(v)
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
 	}
-	// create attachable bridge network by default
-	cmd = p.withEnv(exec.CommandContext(ctx, "podman", p.argsWithConnection([]string{"network", "create", name})...))
-	out, err := cmd.CombinedOutput()
+	return 0
+}
+
+// ContainerExists reports whether a container with the given name exists. `podman
+// inspect -t container` doesn't match images or volumes of the same name.
+func (p *Podman) ContainerExists(ctx context.Context, name string) (bool, error) {
+	return p.exists(ctx, "inspect", "-t", "container", name)
+}
+
+func (p *Podman) ContainerIsRunning(ctx context.Context, name string) (bool, error) {
+	out, err := p.command(ctx, "inspect", "-t", "container", name, "--format", "{{.State.Running}}").CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("podman network create failed: %s", strings.TrimSpace(string(out)))
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+// NetworkExists reports whether a network with the given name exists, using `podman
+// network exists`.
+func (p *Podman) NetworkExists(ctx context.Context, name string) (bool, error) {
+	return p.exists(ctx, "network", "exists", name)
+}
+
+// ImageExists reports whether the image is present, using `podman image exists`,
+// which exits with 1 when it is not.
+func (p *Podman) ImageExists(ctx context.Context, ref string) (bool, error) {
+	if err := p.command(ctx, "image", "exists", ref).Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("podman image exists failed: %w", err)
+	}
+	return true, nil
+}
+
+func (p *Podman) ConnectNetwork(ctx context.Context, container, network string) error {
+	if out, err := p.command(ctx, "network", "connect", network, container).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to connect %s to network %s: %s", container, network, strings.TrimSpace(string(out)))
 	}
-	_ = out
 	return nil
 }
 
 // findPreferredPodmanConnection returns a rootful or default connection name from
 // `podman system connection list --format json`.
 func findPreferredPodmanConnection(ctx context.Context) (string, bool) {
-	cmd := exec.CommandContext(ctx, "podman", "system", "connection", "list", "--format", "json")
-	out, err := cmd.CombinedOutput()
+	out, err := probeOutput(ctx, "podman", "system", "connection", "list", "--format", "json")
 	if err != nil || len(out) == 0 {
 		return "", false
 	}
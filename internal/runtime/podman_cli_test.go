@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// podman 3.x style: numeric Created, CamelCase port keys
+const podmanV3PS = `[
+  {
+    "Id": "0123456789abcdef",
+    "Names": ["demo"],
+    "Image": "quay.io/k0sproject/k0s:v1.33.3-k0s.0",
+    "Status": "Up 3 minutes ago",
+    "Created": 1719000000,
+    "Labels": {"k0da.cluster": "true", "k0da.cluster.name": "demo"},
+    "Ports": [{"HostIp": "", "ContainerPort": 6443, "HostPort": 40001, "Protocol": "tcp"}]
+  }
+]`
+
+// podman 4.x style: Created as RFC3339 string, humanized CreatedAt, snake_case port keys
+const podmanV4PS = `[
+  {
+    "Id": "fedcba9876543210",
+    "Names": ["demo-worker-0"],
+    "Image": "quay.io/k0sproject/k0s:v1.33.3-k0s.0",
+    "Status": "Exited (0) 2 minutes ago",
+    "Created": "2024-06-21T20:00:00.123456789Z",
+    "CreatedAt": "2 hours ago",
+    "Labels": {"k0da.cluster": "true"},
+    "Ports": [{"host_ip": "127.0.0.1", "container_port": 6443, "host_port": 40002, "range": 1, "protocol": "tcp"}]
+  },
+  {
+    "Id": "aaaaaaaaaaaaaaaa",
+    "Names": ["demo-worker-1"],
+    "Image": "quay.io/k0sproject/k0s:v1.33.3-k0s.0",
+    "Status": "Created",
+    "CreatedAt": "2024-06-21 20:00:00.123456789 +0000 UTC",
+    "Labels": null,
+    "Ports": null
+  }
+]`
+
+func TestParsePodmanPS_V3(t *testing.T) {
+	list, err := parsePodmanPS([]byte(podmanV3PS))
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "0123456789abcdef", list[0].ID)
+	require.Equal(t, "demo", list[0].Name)
+	require.Equal(t, int64(1719000000), list[0].Created)
+	require.Equal(t, "0.0.0.0:40001->6443/tcp", list[0].Ports)
+	require.Equal(t, "demo", list[0].Labels["k0da.cluster.name"])
+	require.Equal(t, StateRunning, list[0].State)
+}
+
+func TestParsePodmanPS_V4(t *testing.T) {
+	list, err := parsePodmanPS([]byte(podmanV4PS))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, int64(1719000000), list[0].Created)
+	require.Equal(t, "127.0.0.1:40002->6443/tcp", list[0].Ports)
+	require.Equal(t, int64(1719000000), list[1].Created)
+	require.Empty(t, list[1].Ports)
+	require.Nil(t, list[1].Labels)
+	require.Equal(t, StateExited, list[0].State)
+	require.Equal(t, StateCreated, list[1].State)
+}
+
+func TestParsePodmanPS_StateOnly(t *testing.T) {
+	list, err := parsePodmanPS([]byte(`[
+  {"Id": "a", "Names": ["demo"], "State": "exited", "Status": "", "ExitCode": 137},
+  {"Id": "b", "Names": ["demo-worker-0"], "State": "running"}
+]`))
+	require.NoError(t, err)
+	require.Equal(t, "Exited (137)", list[0].Status)
+	require.Equal(t, StateExited, list[0].State)
+	require.Equal(t, "Running", list[1].Status)
+	require.Equal(t, StateRunning, list[1].State)
+}
+
+func TestRecreateCreateArgs(t *testing.T) {
+	createCommand := []string{
+		"podman", "--connection", "machine", "run", "-d", "--name", "tmp",
+		"--label", "k0da.cluster.name=tmp", "--label", "team=a",
+		"-v", "/home/u/.k0da/clusters/tmp/manifests:/var/lib/k0s/manifests/k0da",
+		"-v", "tmp-var:/var", "quay.io/k0sproject/k0s:v1.33.3-k0s.0", "k0s", "controller",
+	}
+	opts := RecreateOptions{
+		Labels:       map[string]string{"k0da.cluster.name": "prod"},
+		MountSources: map[string]string{"/home/u/.k0da/clusters/tmp": "/home/u/.k0da/clusters/prod"},
+	}
+
+	args, err := recreateCreateArgs(createCommand, "prod", opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"create", "--label", "k0da.cluster.name=prod", "--name", "prod",
+		"--label", "team=a",
+		"-v", "/home/u/.k0da/clusters/prod/manifests:/var/lib/k0s/manifests/k0da",
+		"-v", "tmp-var:/var", "quay.io/k0sproject/k0s:v1.33.3-k0s.0", "k0s", "controller",
+	}, args)
+
+	_, err = recreateCreateArgs([]string{"podman", "create", "busybox"}, "x", opts)
+	require.Error(t, err)
+}
+
+func TestLogsArgs(t *testing.T) {
+	require.Equal(t, []string{"logs", "node"}, logsArgs("node", LogsOptions{}))
+	require.Equal(t,
+		[]string{"logs", "--since", "5m", "--tail", "100", "--follow", "--timestamps", "node"},
+		logsArgs("node", LogsOptions{Since: "5m", Tail: 100, Follow: true, Timestamps: true}))
+}
+
+func TestParseNetworkIP(t *testing.T) {
+	data := []byte(`{"k0da":{"IPAddress":"10.89.0.4","GlobalIPv6Address":"fd00::4"},"v6only":{"IPAddress":"","GlobalIPv6Address":"fd01::7"}}`)
+	ip, err := parseNetworkIP(data, "k0da")
+	require.NoError(t, err)
+	require.Equal(t, "10.89.0.4", ip)
+	ip, err = parseNetworkIP(data, "v6only")
+	require.NoError(t, err)
+	require.Equal(t, "fd01::7", ip)
+	ip, err = parseNetworkIP(data, "other")
+	require.NoError(t, err)
+	require.Empty(t, ip)
+	_, err = parseNetworkIP([]byte("<no value>"), "k0da")
+	require.Error(t, err)
+}
@@ -2,7 +2,13 @@ package runtime
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
 	"strings"
+	"time"
 )
 
 // PortSpec describes a port to publish from container to host.
@@ -30,6 +36,288 @@ type RunContainerOptions struct {
 	// Network is the name of the user-defined network to attach this container to.
 	// If empty, the runtime default network is used.
 	Network string
+	// NetworkAliases are extra names the container is resolvable by on Network.
+	NetworkAliases []string
+	// IP is a static IPv4 address on Network; the network needs a user-defined subnet.
+	IP string
+	// DNS, DNSSearch and ExtraHosts ("host:ip") configure name resolution inside the container.
+	DNS        []string
+	DNSSearch  []string
+	ExtraHosts []string
+	// Devices are host devices in "host[:container[:permissions]]" form.
+	Devices []string
+	// CapAdd and CapDrop adjust the container's Linux capabilities, e.g. "NET_ADMIN".
+	CapAdd  []string
+	CapDrop []string
+	// Ulimits are resource limits for the container process.
+	Ulimits []Ulimit
+	// RestartPolicy is one of "no", "on-failure", "unless-stopped" or "always" (the default when empty).
+	RestartPolicy string
+	// OCIRuntime selects an alternative OCI runtime (e.g. "nvidia"); empty uses the default.
+	OCIRuntime string
+	// CgroupNS is the cgroup namespace mode: "private" (the default when empty) or "host".
+	CgroupNS string
+	// PullPolicy is one of PullMissing (the default when empty), PullAlways or PullNever.
+	PullPolicy string
+	// Healthcheck, when set, has the runtime probe the container with a command.
+	Healthcheck *Healthcheck
+}
+
+// Healthcheck is a command the runtime runs periodically in the container; the container
+// is healthy while it exits with 0.
+type Healthcheck struct {
+	Command     []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// Image pull policies of RunContainerOptions.
+const (
+	// PullMissing pulls the image only when it is not present in the runtime.
+	PullMissing = "missing"
+	// PullAlways pulls the image before every run to pick up a moved tag.
+	PullAlways = "always"
+	// PullNever only uses images already present in the runtime.
+	PullNever = "never"
+)
+
+// LogsOptions select which container logs ContainerLogs returns.
+type LogsOptions struct {
+	// Since shows logs since a timestamp (RFC 3339) or relative duration such as "5m".
+	Since string
+	// Tail is the number of lines to show from the end of the logs; 0 or less shows all.
+	Tail int
+	// Follow keeps streaming new output.
+	Follow bool
+	// Timestamps prefixes every line with its RFC 3339 timestamp.
+	Timestamps bool
+}
+
+// RecreateOptions are the changes applied when a container is recreated.
+type RecreateOptions struct {
+	// Labels are merged over the labels of the container.
+	Labels map[string]string
+	// MountSources maps host paths to the paths replacing them in bind mount sources,
+	// e.g. a moved cluster state directory.
+	MountSources map[string]string
+}
+
+// IsPortInUse reports whether err is a runtime failing to publish a host port
+// that is already taken.
+func IsPortInUse(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "port is already allocated") || strings.Contains(msg, "address already in use")
+}
+
+// containerSwapper is what replaceContainer needs from a backend.
+type containerSwapper interface {
+	StopContainer(ctx context.Context, name string) error
+	RemoveContainer(ctx context.Context, name string) error
+	RenameContainer(ctx context.Context, oldName, newName string) error
+	startContainer(ctx context.Context, name string) error
+}
+
+// replaceContainer swaps the container name for the one create makes under the temporary
+// name it is given. The replacement is created before the original is touched, and every
+// later failure restores the original, so a failed recreate leaves the node as it was.
+// The replacement is started only if the original was running.
+func replaceContainer(ctx context.Context, s containerSwapper, name string, running bool, create func(tmpName string) error) error {
+	newName, oldName := name+"-k0da-new", name+"-k0da-old"
+	if err := create(newName); err != nil {
+		return fmt.Errorf("failed to create replacement for container %s: %w", name, err)
+	}
+	restore := func(renamed bool) {
+		_ = s.RemoveContainer(ctx, newName)
+		if renamed {
+			_ = s.RenameContainer(ctx, oldName, name)
+		}
+		if running {
+			_ = s.startContainer(ctx, name)
+		}
+	}
+
+	if running {
+		if err := s.StopContainer(ctx, name); err != nil {
+			_ = s.RemoveContainer(ctx, newName)
+			return fmt.Errorf("failed to stop container %s: %w", name, err)
+		}
+	}
+	if err := s.RenameContainer(ctx, name, oldName); err != nil {
+		restore(false)
+		return fmt.Errorf("failed to rename container %s: %w", name, err)
+	}
+	if err := s.RenameContainer(ctx, newName, name); err != nil {
+		restore(true)
+		return fmt.Errorf("failed to rename replacement of container %s: %w", name, err)
+	}
+	if running {
+		if err := s.startContainer(ctx, name); err != nil {
+			// The replacement holds the name now.
+			_ = s.RemoveContainer(ctx, name)
+			_ = s.RenameContainer(ctx, oldName, name)
+			_ = s.startContainer(ctx, name)
+			return fmt.Errorf("failed to start recreated container %s: %w", name, err)
+		}
+	}
+	if err := s.RemoveContainer(ctx, oldName); err != nil {
+		return fmt.Errorf("failed to remove replaced container %s: %w", oldName, err)
+	}
+	return nil
+}
+
+// remapBindSource rewrites the host path of a "source:target[:options]" bind spec when it
+// equals or lies below one of the paths in sources.
+func remapBindSource(bind string, sources map[string]string) string {
+	for from, to := range sources {
+		if from == "" {
+			continue
+		}
+		if strings.HasPrefix(bind, from+":") || strings.HasPrefix(bind, from+"/") || strings.HasPrefix(bind, from+string(os.PathSeparator)) {
+			return to + bind[len(from):]
+		}
+	}
+	return bind
+}
+
+// Ulimit is a resource limit; -1 means unlimited.
+type Ulimit struct {
+	Name string
+	Soft int64
+	Hard int64
+}
+
+// k0s node containers need the same base settings regardless of backend: a writable /run,
+// relaxed confinement for the kubelet and container runtime, and unlimited memlock for eBPF.
+var (
+	k0sDefaultTmpfs       = map[string]string{"/run": "", "/var/run": ""}
+	k0sDefaultSecurityOpt = []string{"seccomp=unconfined", "apparmor=unconfined", "label=disable"}
+	k0sDefaultUlimits     = []Ulimit{{Name: "memlock", Soft: -1, Hard: -1}}
+	// k0sHostCgroupMount makes the host cgroup hierarchy writable for nodes sharing the host
+	// cgroup namespace; with a private namespace the runtime provides a writable cgroupfs.
+	k0sHostCgroupMount = Mount{Type: "bind", Source: "/sys/fs/cgroup", Target: "/sys/fs/cgroup", Options: []string{"rw"}}
+)
+
+// withK0sDefaults returns opts with the k0s container defaults merged in. Values already
+// set by the caller win over the defaults.
+func withK0sDefaults(opts RunContainerOptions) RunContainerOptions {
+	tmpfs := make(map[string]string, len(k0sDefaultTmpfs)+len(opts.Tmpfs))
+	for k, v := range k0sDefaultTmpfs {
+		tmpfs[k] = v
+	}
+	for k, v := range opts.Tmpfs {
+		tmpfs[k] = v
+	}
+	opts.Tmpfs = tmpfs
+
+	secOpts := append([]string(nil), opts.SecurityOpt...)
+	for _, d := range k0sDefaultSecurityOpt {
+		key := strings.SplitN(d, "=", 2)[0]
+		found := false
+		for _, s := range secOpts {
+			if strings.SplitN(s, "=", 2)[0] == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			secOpts = append(secOpts, d)
+		}
+	}
+	opts.SecurityOpt = secOpts
+
+	ulimits := append([]Ulimit(nil), opts.Ulimits...)
+	for _, d := range k0sDefaultUlimits {
+		found := false
+		for _, u := range ulimits {
+			if u.Name == d.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ulimits = append(ulimits, d)
+		}
+	}
+	opts.Ulimits = ulimits
+
+	if opts.effectiveCgroupNS() == "host" {
+		found := false
+		for _, m := range opts.Mounts {
+			if m.Target == k0sHostCgroupMount.Target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			opts.Mounts = append(append(Mounts(nil), opts.Mounts...), k0sHostCgroupMount)
+		}
+	}
+	return opts
+}
+
+// effectiveRestartPolicy returns the restart policy to apply, defaulting to "always".
+func (o RunContainerOptions) effectiveRestartPolicy() string {
+	if p := strings.TrimSpace(o.RestartPolicy); p != "" {
+		return p
+	}
+	return "always"
+}
+
+// effectivePullPolicy returns the pull policy to apply, defaulting to PullMissing.
+func (o RunContainerOptions) effectivePullPolicy() string {
+	if p := strings.TrimSpace(o.PullPolicy); p != "" {
+		return p
+	}
+	return PullMissing
+}
+
+// effectiveCgroupNS returns the cgroup namespace mode to apply, defaulting to "private"
+// like kind does on cgroup v2 hosts.
+func (o RunContainerOptions) effectiveCgroupNS() string {
+	if m := strings.TrimSpace(o.CgroupNS); m != "" {
+		return m
+	}
+	return "private"
+}
+
+// runInteractive runs a runtime CLI command wired to the current terminal and returns its exit code.
+func runInteractive(cmd *exec.Cmd) (int, error) {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return ee.ExitCode(), nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+// DeviceSpec is a parsed "host[:container[:permissions]]" device mapping.
+type DeviceSpec struct {
+	HostPath      string
+	ContainerPath string
+	Permissions   string
+}
+
+// ParseDevice parses a device mapping. The container path defaults to the host path
+// and permissions default to "rwm".
+func ParseDevice(s string) DeviceSpec {
+	parts := strings.SplitN(strings.TrimSpace(s), ":", 3)
+	d := DeviceSpec{HostPath: parts[0], ContainerPath: parts[0], Permissions: "rwm"}
+	if len(parts) > 1 && parts[1] != "" {
+		d.ContainerPath = parts[1]
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		d.Permissions = parts[2]
+	}
+	return d
 }
 
 // Mount describes a container mount
@@ -97,27 +385,124 @@ func (ev EnvVars) ToMap() map[string]string {
 
 // ContainerInfo is a reduced view for listing clusters.
 type ContainerInfo struct {
-	ID      string
-	Name    string
-	Image   string
-	Status  string
+	ID    string
+	Name  string
+	Image string
+	// Status is the runtime's own human-readable status, e.g. "Up 3 minutes"; see State
+	// for a value to compare against.
+	Status string
+	State  string // one of the State* constants
+	// Health is healthy, unhealthy or starting for containers with a healthcheck, else empty.
+	Health  string
 	Ports   string // human readable, e.g., "0.0.0.0:55131->6443/tcp"
 	Created int64  // unix seconds
 	Labels  map[string]string
 }
 
+// Normalized container states, the same for all backends.
+const (
+	StateRunning = "running"
+	StateExited  = "exited"
+	StateCreated = "created"
+	StatePaused  = "paused"
+)
+
+// healthFromStatus extracts the health of a container from its status text, where both
+// docker and podman report it, e.g. "Up 3 minutes (healthy)" or "Up 5 seconds (health: starting)".
+func healthFromStatus(status string) string {
+	status = strings.ToLower(status)
+	switch {
+	case strings.Contains(status, "(unhealthy)"):
+		return "unhealthy"
+	case strings.Contains(status, "(healthy)"):
+		return "healthy"
+	case strings.Contains(status, "(health: starting)") || strings.Contains(status, "(starting)"):
+		return "starting"
+	}
+	return ""
+}
+
+// normalizeState maps a backend's container state to one of the State* constants.
+// When the backend did not report a state, it is derived from the status text.
+func normalizeState(state, status string) string {
+	switch strings.ToLower(strings.TrimSpace(state)) {
+	case "running", "restarting":
+		return StateRunning
+	case "paused":
+		return StatePaused
+	case "created", "configured", "initialized":
+		return StateCreated
+	case "exited", "stopped", "dead", "removing":
+		return StateExited
+	}
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch {
+	case strings.HasPrefix(status, "up"):
+		if strings.Contains(status, "(paused)") {
+			return StatePaused
+		}
+		return StateRunning
+	case strings.HasPrefix(status, "running"), strings.HasPrefix(status, "restarting"):
+		return StateRunning
+	case strings.HasPrefix(status, "paused"):
+		return StatePaused
+	case strings.HasPrefix(status, "created"), strings.HasPrefix(status, "configured"), strings.HasPrefix(status, "initialized"):
+		return StateCreated
+	case status == "":
+		return ""
+	}
+	return StateExited
+}
+
+// NetworkOptions configure a network created by EnsureNetwork.
+type NetworkOptions struct {
+	// Subnet in CIDR form; the runtime picks one when empty.
+	Subnet string
+}
+
 // Runtime is the interface implemented by container runtimes.
 type Runtime interface {
 	Name() string
+	// Describe returns a short human-readable description of the backend and the
+	// endpoint it talks to, e.g. "docker (unix:///var/run/docker.sock)".
+	Describe() string
+	// Version returns the version of the runtime server (daemon or service).
+	Version(ctx context.Context) (string, error)
 
 	RunContainer(ctx context.Context, opts RunContainerOptions) (string, error)
 	ContainerExists(ctx context.Context, name string) (bool, error)
 	ContainerIsRunning(ctx context.Context, name string) (bool, error)
 	StopContainer(ctx context.Context, name string) error
 	RemoveContainer(ctx context.Context, name string) error
+	// RenameContainer renames a container, running or not. Neither Docker nor Podman can
+	// change the labels of an existing container, so labels derived from the old name keep
+	// their values; update them with RecreateContainer.
+	RenameContainer(ctx context.Context, oldName, newName string) error
+	// RecreateContainer replaces a container with an identical one with opts applied. It is
+	// the only way to change labels after creation. Named volumes are kept and the new
+	// container is only started if the old one was running. The replacement is created
+	// before the old container is stopped, which is restored if anything fails.
+	RecreateContainer(ctx context.Context, name string, opts RecreateOptions) error
+	// RecreateWithLabels recreates the container with labels merged over its own, keeping
+	// its volumes. It is the building block for changing a node's identity, e.g. on adopt.
+	RecreateWithLabels(ctx context.Context, name string, labels map[string]string) error
 
 	ExecInContainer(ctx context.Context, name string, command []string) (stdout string, exitCode int, err error)
+	// ExecInContainerStream runs command and streams its input and output as it runs.
+	// stdin may be nil. Output is demultiplexed into stdout and stderr.
+	ExecInContainerStream(ctx context.Context, name string, command []string, stdin io.Reader, stdout, stderr io.Writer) (exitCode int, err error)
+	// ExecInContainerInteractive runs command with a TTY attached to the current terminal.
+	ExecInContainerInteractive(ctx context.Context, name string, command []string) (exitCode int, err error)
+	// ExecInContainerTTY runs command with a TTY fed from stdin, for programs that read
+	// secrets only from a terminal, such as ctr's password prompt. The TTY output,
+	// including what it echoes, is written to stdout.
+	ExecInContainerTTY(ctx context.Context, name string, command []string, stdin io.Reader, stdout io.Writer) (exitCode int, err error)
 	GetPortMapping(ctx context.Context, name string, containerPort int, protocol string) (hostIP string, hostPort int, err error)
+	// ContainerIP returns the IP address of a container on the given network.
+	ContainerIP(ctx context.Context, name, network string) (string, error)
+	// ContainerLogs writes the logs of a container to stdout and stderr. With
+	// opts.Follow it keeps streaming until ctx is cancelled or the container stops.
+	ContainerLogs(ctx context.Context, name string, opts LogsOptions, stdout, stderr io.Writer) error
 
 	VolumeExists(ctx context.Context, name string) (bool, error)
 	RemoveVolume(ctx context.Context, name string) error
@@ -127,16 +512,43 @@ type Runtime interface {
 	// CopyToContainer copies a local host path into the container at dstPath
 	CopyToContainer(ctx context.Context, name string, srcPath string, dstPath string) error
 
+	// PullImage pulls an image from its registry into the host runtime.
+	PullImage(ctx context.Context, ref string) error
+	// ImageExists reports whether an image is present in the host runtime. It never pulls.
+	ImageExists(ctx context.Context, ref string) (bool, error)
+	// ImageID returns the ID of a local image, the digest of its config, e.g. sha256:4f0b….
+	ImageID(ctx context.Context, ref string) (string, error)
 	// SaveImageToTar saves a local image from the host runtime into a tar file at tarPath
 	SaveImageToTar(ctx context.Context, imageRef string, tarPath string) error
 
-	// EnsureNetwork ensures a user-defined network with the given name exists.
-	// It should be idempotent.
-	EnsureNetwork(ctx context.Context, name string) error
+	// EnsureNetwork ensures a user-defined network with the given name exists, creating
+	// it with opts if it is missing. It should be idempotent.
+	EnsureNetwork(ctx context.Context, name string, opts NetworkOptions) error
+	// NetworkExists reports whether a network with the given name exists. It never creates one.
+	NetworkExists(ctx context.Context, name string) (bool, error)
+	// NetworkSubnets returns the subnets of an existing network in CIDR form.
+	NetworkSubnets(ctx context.Context, name string) ([]string, error)
+	// ConnectNetwork connects a container to an additional network.
+	ConnectNetwork(ctx context.Context, container, network string) error
+}
+
+// ImageSaver is implemented by runtimes that can export an image as a stream, so that
+// it can be imported into a node without a temporary tar file on the host.
+type ImageSaver interface {
+	// SaveImage returns a local image as a tar archive stream. The caller closes it.
+	SaveImage(ctx context.Context, imageRef string) (io.ReadCloser, error)
+}
+
+// FactoryOptions tell a Factory which runtime endpoint to connect to.
+type FactoryOptions struct {
+	// Socket is the URI of the runtime, e.g. unix:///var/run/docker.sock; empty for the default.
+	Socket string
+	// Identity is the ssh key for ssh:// sockets, if any.
+	Identity string
 }
 
-// Factory constructs a Runtime given a socket URI (may be empty for default).
-type Factory func(ctx context.Context, socket string) (Runtime, error)
+// Factory constructs a Runtime connected to the endpoint in opts.
+type Factory func(ctx context.Context, opts FactoryOptions) (Runtime, error)
 
 var registry = map[string]Factory{}
 
@@ -151,11 +563,12 @@ func GetFactory(name string) (Factory, bool) {
 	return f, ok
 }
 
-// Registered returns the list of registered backend names.
+// Registered returns the sorted list of registered backend names.
 func Registered() []string {
 	names := make([]string, 0, len(registry))
 	for k := range registry {
 		names = append(names, k)
 	}
+	sort.Strings(names)
 	return names
 }
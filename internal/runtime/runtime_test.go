@@ -1,8 +1,18 @@
 package runtime
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,3 +35,427 @@ func TestEnvVars(t *testing.T) {
 	m := ev.ToMap()
 	require.Equal(t, map[string]string{"A": "1", "B": "2"}, m)
 }
+
+func TestParseDevice(t *testing.T) {
+	require.Equal(t, DeviceSpec{HostPath: "/dev/nvidia0", ContainerPath: "/dev/nvidia0", Permissions: "rwm"}, ParseDevice("/dev/nvidia0"))
+	require.Equal(t, DeviceSpec{HostPath: "/dev/fuse", ContainerPath: "/dev/myfuse", Permissions: "rwm"}, ParseDevice("/dev/fuse:/dev/myfuse"))
+	require.Equal(t, DeviceSpec{HostPath: "/dev/sda", ContainerPath: "/dev/xvda", Permissions: "r"}, ParseDevice("/dev/sda:/dev/xvda:r"))
+}
+
+func TestRootlessAllowed(t *testing.T) {
+	t.Setenv("K0DA_ALLOW_ROOTLESS", "")
+	require.False(t, rootlessAllowed())
+	t.Setenv("K0DA_ALLOW_ROOTLESS", "1")
+	require.True(t, rootlessAllowed())
+	t.Setenv("K0DA_ALLOW_ROOTLESS", "no")
+	require.False(t, rootlessAllowed())
+}
+
+func TestWithK0sDefaults_CallerWins(t *testing.T) {
+	opts := withK0sDefaults(RunContainerOptions{
+		Tmpfs:       map[string]string{"/tmp": "size=64m"},
+		SecurityOpt: []string{"seccomp=/etc/k0da/seccomp.json"},
+		Ulimits:     []Ulimit{{Name: "memlock", Soft: 1024, Hard: 1024}},
+	})
+	require.Equal(t, map[string]string{"/run": "", "/var/run": "", "/tmp": "size=64m"}, opts.Tmpfs)
+	require.Equal(t, []string{"seccomp=/etc/k0da/seccomp.json", "apparmor=unconfined", "label=disable"}, opts.SecurityOpt)
+	require.Equal(t, []Ulimit{{Name: "memlock", Soft: 1024, Hard: 1024}}, opts.Ulimits)
+}
+
+func TestDockerContainerConfig_OnlyRequestedMounts(t *testing.T) {
+	_, hc, _ := dockerContainerConfig(RunContainerOptions{Name: "n", Image: "k0s"})
+	require.Empty(t, hc.Binds)
+
+	opts := RunContainerOptions{Name: "n", Image: "k0s", Mounts: Mounts{{Type: "bind", Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"}}}
+	_, hc, _ = dockerContainerConfig(opts)
+	require.Equal(t, []string{"/var/run/docker.sock:/var/run/docker.sock"}, hc.Binds)
+}
+
+func TestBackendsApplyK0sDefaults(t *testing.T) {
+	opts := RunContainerOptions{Name: "n", Image: "k0s"}
+
+	_, hc, _ := dockerContainerConfig(opts)
+	require.Contains(t, hc.Ulimits, &container.Ulimit{Name: "memlock", Soft: -1, Hard: -1})
+	require.Contains(t, hc.Tmpfs, "/run")
+	require.Contains(t, hc.Tmpfs, "/var/run")
+	require.ElementsMatch(t, []string{"seccomp=unconfined", "apparmor=unconfined", "label=disable"}, hc.SecurityOpt)
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--ulimit memlock=-1:-1")
+	require.Contains(t, args, "--tmpfs /run")
+	require.Contains(t, args, "--tmpfs /var/run")
+	for _, s := range []string{"seccomp=unconfined", "apparmor=unconfined", "label=disable"} {
+		require.Contains(t, args, "--security-opt "+s)
+	}
+}
+
+func TestBackendsApplyCapabilities(t *testing.T) {
+	opts := RunContainerOptions{Name: "n", Image: "k0s", CapAdd: []string{"NET_ADMIN"}, CapDrop: []string{"MKNOD"}}
+
+	_, hc, _ := dockerContainerConfig(opts)
+	require.Equal(t, []string{"NET_ADMIN"}, []string(hc.CapAdd))
+	require.Equal(t, []string{"MKNOD"}, []string(hc.CapDrop))
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--cap-add NET_ADMIN")
+	require.Contains(t, args, "--cap-drop MKNOD")
+}
+
+func TestBackendsApplyDNS(t *testing.T) {
+	opts := RunContainerOptions{
+		Name:       "n",
+		Image:      "k0s",
+		DNS:        []string{"10.0.0.53"},
+		DNSSearch:  []string{"corp.internal"},
+		ExtraHosts: []string{"registry.corp.internal:10.0.0.5"},
+	}
+
+	_, hc, _ := dockerContainerConfig(opts)
+	require.Equal(t, []string{"10.0.0.53"}, hc.DNS)
+	require.Equal(t, []string{"corp.internal"}, hc.DNSSearch)
+	require.Equal(t, []string{"registry.corp.internal:10.0.0.5"}, hc.ExtraHosts)
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--dns 10.0.0.53")
+	require.Contains(t, args, "--dns-search corp.internal")
+	require.Contains(t, args, "--add-host registry.corp.internal:10.0.0.5")
+}
+
+func TestBackendsApplyNetworkAliases(t *testing.T) {
+	opts := RunContainerOptions{Name: "demo", Image: "k0s", Network: "k0da", NetworkAliases: []string{"my-ctrl"}}
+
+	_, _, nc := dockerContainerConfig(opts)
+	require.Equal(t, []string{"my-ctrl"}, nc.EndpointsConfig["k0da"].Aliases)
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--network k0da --network-alias my-ctrl")
+}
+
+func TestBackendsApplyStaticIP(t *testing.T) {
+	opts := RunContainerOptions{Name: "demo", Image: "k0s", Network: "k0da", IP: "172.30.0.10"}
+
+	_, _, nc := dockerContainerConfig(opts)
+	require.Equal(t, "172.30.0.10", nc.EndpointsConfig["k0da"].IPAMConfig.IPv4Address)
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--network k0da --ip 172.30.0.10")
+
+	_, _, nc = dockerContainerConfig(RunContainerOptions{Name: "demo", Image: "k0s", Network: "k0da"})
+	require.Nil(t, nc.EndpointsConfig["k0da"].IPAMConfig)
+}
+
+func TestBackendsApplyCgroupNS(t *testing.T) {
+	opts := RunContainerOptions{Name: "n", Image: "k0s"}
+
+	_, hc, _ := dockerContainerConfig(opts)
+	require.Equal(t, container.CgroupnsMode("private"), hc.CgroupnsMode)
+	require.NotContains(t, hc.Binds, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+	require.Contains(t, strings.Join((&Podman{}).runArgs(opts), " "), "--cgroupns private")
+
+	opts.CgroupNS = "host"
+	_, hc, _ = dockerContainerConfig(opts)
+	require.Equal(t, container.CgroupnsMode("host"), hc.CgroupnsMode)
+	require.Contains(t, hc.Binds, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+
+	args := strings.Join((&Podman{}).runArgs(opts), " ")
+	require.Contains(t, args, "--cgroupns host")
+	require.Contains(t, args, "-v /sys/fs/cgroup:/sys/fs/cgroup:rw")
+}
+
+func TestBackendsApplyHealthcheck(t *testing.T) {
+	opts := RunContainerOptions{Name: "n", Image: "k0s"}
+	c, _, _ := dockerContainerConfig(opts)
+	require.Nil(t, c.Healthcheck)
+	require.NotContains(t, strings.Join((&Podman{}).runArgs(opts), " "), "--health-cmd")
+
+	opts.Healthcheck = &Healthcheck{Command: []string{"k0s", "status"}, Interval: 10 * time.Second, Retries: 3}
+	c, _, _ = dockerContainerConfig(opts)
+	require.Equal(t, []string{"CMD", "k0s", "status"}, c.Healthcheck.Test)
+	require.Equal(t, 10*time.Second, c.Healthcheck.Interval)
+	require.Equal(t, 3, c.Healthcheck.Retries)
+
+	args := (&Podman{}).runArgs(opts)
+	joined := strings.Join(args, " ")
+	require.Contains(t, args, "k0s status")
+	require.Contains(t, joined, "--health-interval 10s")
+	require.Contains(t, joined, "--health-retries 3")
+	require.NotContains(t, joined, "--health-timeout")
+}
+
+func TestHealthFromStatus(t *testing.T) {
+	require.Equal(t, "healthy", healthFromStatus("Up 3 minutes (healthy)"))
+	require.Equal(t, "unhealthy", healthFromStatus("Up 3 minutes (unhealthy)"))
+	require.Equal(t, "starting", healthFromStatus("Up 5 seconds (health: starting)"))
+	require.Equal(t, "starting", healthFromStatus("Up 5 seconds (starting)"))
+	require.Empty(t, healthFromStatus("Up 3 minutes"))
+	require.Empty(t, healthFromStatus("Exited (0) 2 minutes ago"))
+}
+
+func TestPodmanRunArgsPullPolicy(t *testing.T) {
+	opts := RunContainerOptions{Name: "n", Image: "k0s"}
+	require.Contains(t, strings.Join((&Podman{}).runArgs(opts), " "), "--pull missing")
+
+	opts.PullPolicy = PullAlways
+	require.Contains(t, strings.Join((&Podman{}).runArgs(opts), " "), "--pull always")
+}
+
+func TestRecreatedDockerConfig(t *testing.T) {
+	info := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			HostConfig: &container.HostConfig{Binds: []string{
+				"/home/u/.k0da/clusters/tmp/etc-k0s/k0s.yaml:/etc/k0s/k0s.yaml:ro",
+				"/lib/modules:/lib/modules:ro",
+			}},
+		},
+		Config: &container.Config{Image: "k0s", Labels: map[string]string{"k0da.cluster.name": "tmp", "team": "a"}},
+		NetworkSettings: &container.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"k0da": {Aliases: []string{"tmp"}, IPAddress: "172.18.0.2", EndpointID: "abc"},
+		}},
+	}
+	opts := RecreateOptions{
+		Labels:       map[string]string{"k0da.cluster.name": "dev"},
+		MountSources: map[string]string{"/home/u/.k0da/clusters/tmp": "/home/u/.k0da/clusters/dev"},
+	}
+
+	config, hostConfig, networking := recreatedDockerConfig(info, opts)
+	require.Equal(t, map[string]string{"k0da.cluster.name": "dev", "team": "a"}, config.Labels)
+	require.Equal(t, []string{
+		"/home/u/.k0da/clusters/dev/etc-k0s/k0s.yaml:/etc/k0s/k0s.yaml:ro",
+		"/lib/modules:/lib/modules:ro",
+	}, hostConfig.Binds)
+	require.Equal(t, &network.EndpointSettings{Aliases: []string{"tmp"}}, networking.EndpointsConfig["k0da"])
+}
+
+// swapRecorder is a containerSwapper keeping containers by name, with a failure injected
+// for the first call of one operation.
+type swapRecorder struct {
+	containers map[string]string // name -> id
+	running    map[string]bool
+	failOn     string
+	ops        []string
+}
+
+func (s *swapRecorder) op(op, name string) error {
+	s.ops = append(s.ops, op+" "+name)
+	if s.failOn == op+" "+name {
+		s.failOn = ""
+		return fmt.Errorf("%s failed", op)
+	}
+	return nil
+}
+
+func (s *swapRecorder) StopContainer(_ context.Context, name string) error {
+	if err := s.op("stop", name); err != nil {
+		return err
+	}
+	s.running[s.containers[name]] = false
+	return nil
+}
+
+func (s *swapRecorder) RemoveContainer(_ context.Context, name string) error {
+	if err := s.op("rm", name); err != nil {
+		return err
+	}
+	delete(s.containers, name)
+	return nil
+}
+
+func (s *swapRecorder) RenameContainer(_ context.Context, oldName, newName string) error {
+	if err := s.op("rename", oldName); err != nil {
+		return err
+	}
+	s.containers[newName] = s.containers[oldName]
+	delete(s.containers, oldName)
+	return nil
+}
+
+func (s *swapRecorder) startContainer(_ context.Context, name string) error {
+	if err := s.op("start", name); err != nil {
+		return err
+	}
+	s.running[s.containers[name]] = true
+	return nil
+}
+
+func TestReplaceContainer(t *testing.T) {
+	newSwap := func(failOn string) *swapRecorder {
+		return &swapRecorder{containers: map[string]string{"n": "old"}, running: map[string]bool{"old": true}, failOn: failOn}
+	}
+	create := func(s *swapRecorder) func(string) error {
+		return func(tmpName string) error {
+			s.containers[tmpName] = "new"
+			return nil
+		}
+	}
+
+	s := newSwap("")
+	require.NoError(t, replaceContainer(context.Background(), s, "n", true, create(s)))
+	require.Equal(t, map[string]string{"n": "new"}, s.containers)
+	require.True(t, s.running["new"])
+
+	// Every failure after the replacement exists leaves the original running under its name.
+	for _, failOn := range []string{"stop n", "rename n", "rename n-k0da-new", "start n"} {
+		s := newSwap(failOn)
+		require.Error(t, replaceContainer(context.Background(), s, "n", true, create(s)), failOn)
+		require.Equal(t, map[string]string{"n": "old"}, s.containers, failOn)
+		require.True(t, s.running["old"], failOn)
+	}
+
+	// A failed create doesn't touch the original.
+	s = newSwap("")
+	err := replaceContainer(context.Background(), s, "n", true, func(string) error { return fmt.Errorf("no space") })
+	require.ErrorContains(t, err, "no space")
+	require.Empty(t, s.ops)
+
+	// Stopped containers are swapped without starting anything.
+	s = newSwap("")
+	s.running["old"] = false
+	require.NoError(t, replaceContainer(context.Background(), s, "n", false, create(s)))
+	require.Equal(t, map[string]string{"n": "new"}, s.containers)
+	require.NotContains(t, s.ops, "start n")
+}
+
+func TestNormalizeState(t *testing.T) {
+	for _, tc := range []struct{ state, status, want string }{
+		{"running", "Up 3 minutes", StateRunning},
+		{"restarting", "Restarting (1) 3 seconds ago", StateRunning},
+		{"paused", "Up 3 minutes (Paused)", StatePaused},
+		{"exited", "Exited (0) 2 minutes ago", StateExited},
+		{"dead", "Dead", StateExited},
+		{"created", "Created", StateCreated},
+		// podman
+		{"stopped", "", StateExited},
+		{"configured", "Created", StateCreated},
+		// no state reported
+		{"", "Up 3 minutes ago", StateRunning},
+		{"", "Up 3 minutes (Paused)", StatePaused},
+		{"", "Exited (1) 1 hour ago", StateExited},
+		{"", "Created", StateCreated},
+		{"", "Restarting (1) 3 seconds ago", StateRunning},
+		{"", "Running", StateRunning},
+		{"", "Paused", StatePaused},
+		{"", "Stopped", StateExited},
+		{"", "Configured", StateCreated},
+		{"", "", ""},
+	} {
+		require.Equal(t, tc.want, normalizeState(tc.state, tc.status), "%s/%s", tc.state, tc.status)
+	}
+}
+
+func TestSocketHost(t *testing.T) {
+	for socket, want := range map[string]string{
+		"ssh://core@build-box.lan/run/docker.sock":          "build-box.lan",
+		"ssh://root@127.0.0.1:52811/run/podman/podman.sock": "",
+		"ssh://user@localhost:22/run/user/1000/podman.sock": "",
+		"tcp://10.0.0.5:2375":                               "10.0.0.5",
+		"tcp://[::1]:2375":                                  "",
+		"unix:///var/run/docker.sock":                       "",
+		"npipe:////./pipe/docker_engine":                    "",
+		"":                                                  "",
+	} {
+		require.Equal(t, want, socketHost(socket), socket)
+	}
+	require.Equal(t, "10.0.0.5", RemoteHost(&Docker{socket: "tcp://10.0.0.5:2375"}))
+	require.Empty(t, RemoteHost(&Podman{}))
+}
+
+func TestSocketMount_Docker(t *testing.T) {
+	m, err := SocketMount(context.Background(), &Docker{socket: "tcp://10.0.0.5:2375"})
+	require.NoError(t, err)
+	require.Equal(t, Mount{Type: "bind", Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"}, m)
+
+	if goruntime.GOOS == "linux" {
+		m, err = SocketMount(context.Background(), &Docker{socket: "unix:///run/user/1000/docker.sock"})
+		require.NoError(t, err)
+		require.Equal(t, "/run/user/1000/docker.sock", m.Source)
+	}
+}
+
+func TestDetect_PodmanProbesRespectContext(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("fake podman is a shell script")
+	}
+	// A podman that hangs on every call.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "podman"), []byte("#!/bin/sh\nsleep 30\n"), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("K0DA_PODMAN_CONNECTION", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Detect(ctx, DetectOptions{Runtime: "podman", SocketOverride: "unix:///nonexistent/podman.sock"})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestFirstReachableSocket(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	// Keep socket paths short, they are limited to ~100 bytes.
+	dir, err := os.MkdirTemp("", "k0da")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	listen := func(name string) string {
+		l, err := net.Listen("unix", filepath.Join(dir, name))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = l.Close() })
+		return "unix://" + filepath.Join(dir, name)
+	}
+	// A socket file nobody listens on refuses connections.
+	stale := func(name string) string {
+		l, err := net.Listen("unix", filepath.Join(dir, name))
+		require.NoError(t, err)
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(t, l.Close())
+		return "unix://" + filepath.Join(dir, name)
+	}
+	missing := "unix://" + filepath.Join(dir, "missing.sock")
+	stale1, stale2 := stale("stale1.sock"), stale("stale2.sock")
+	first, second := listen("first.sock"), listen("second.sock")
+
+	require.Equal(t, first, firstReachableSocket([]string{missing, stale1, first, stale2, second}, 2*time.Second))
+	require.Equal(t, second, firstReachableSocket([]string{second, first}, 2*time.Second))
+	require.Empty(t, firstReachableSocket([]string{missing, stale1, stale2}, 2*time.Second))
+	require.Empty(t, firstReachableSocket(nil, 2*time.Second))
+
+	// Unreachable candidates are probed at the same time, not one after another.
+	var many []string
+	for i := 0; i < 8; i++ {
+		many = append(many, stale(fmt.Sprintf("s%d.sock", i)))
+	}
+	start := time.Now()
+	require.Equal(t, first, firstReachableSocket(append(many, first), 2*time.Second))
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestRegisteredBackends(t *testing.T) {
+	require.ElementsMatch(t, []string{"docker", "nerdctl", "podman"}, Registered())
+	for _, name := range []string{"docker", "nerdctl", "podman"} {
+		f, ok := GetFactory(name)
+		require.True(t, ok, name)
+		require.NotNil(t, f, name)
+	}
+	_, ok := GetFactory("containerd")
+	require.False(t, ok)
+}
+
+func TestDetect_UsesRegisteredFactory(t *testing.T) {
+	var got FactoryOptions
+	Register("fake", func(_ context.Context, opts FactoryOptions) (Runtime, error) {
+		got = opts
+		return &Docker{name: "fake", socket: opts.Socket}, nil
+	})
+	t.Cleanup(func() { delete(registry, "fake") })
+
+	r, err := Detect(context.Background(), DetectOptions{Runtime: "fake", SocketOverride: "unix:///run/fake.sock"})
+	require.NoError(t, err)
+	require.Equal(t, "fake", r.Name())
+	require.Equal(t, FactoryOptions{Socket: "unix:///run/fake.sock"}, got)
+	require.Equal(t, []string{"docker", "podman", "nerdctl", "fake"}, autoDetectOrder())
+
+	_, err = Detect(context.Background(), DetectOptions{Runtime: "nope"})
+	require.ErrorContains(t, err, "unknown runtime: nope (supported: docker, fake, nerdctl, podman)")
+}
@@ -0,0 +1,18 @@
+// Package runtimetest provides helpers for tests that work with the node containers
+// a runtime reports.
+package runtimetest
+
+import (
+	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// Node returns a node container of cluster in state with the labels k0da sets on it.
+func Node(cluster, name, role, state string) runtime.ContainerInfo {
+	return runtime.ContainerInfo{Name: name, State: state, Labels: map[string]string{
+		k0daconfig.LabelCluster:     "true",
+		k0daconfig.LabelClusterName: cluster,
+		k0daconfig.LabelNodeName:    name,
+		k0daconfig.LabelNodeRole:    role,
+	}}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	k0daconfig "github.com/makhov/k0da/internal/config"
+)
+
+// StageImageBundles resolves spec.k0s.imageBundles to files on the host and returns
+// their paths in order. Local bundles are used in place, relative paths being relative
+// to the config file; URLs are downloaded into destDir.
+func StageImageBundles(cc *k0daconfig.ClusterConfig, destDir string) ([]string, error) {
+	if cc == nil || len(cc.Spec.K0s.ImageBundles) == 0 {
+		return nil, nil
+	}
+	baseDir := ""
+	if strings.TrimSpace(cc.SourcePath) != "" {
+		baseDir = filepath.Dir(cc.SourcePath)
+	}
+
+	var staged []string
+	for i, b := range cc.Spec.K0s.ImageBundles {
+		p := strings.TrimSpace(b)
+		if p == "" {
+			continue
+		}
+		if isURL(p) {
+			dst := filepath.Join(destDir, fmt.Sprintf("%03d_%s", i, urlBase(p)))
+			if err := downloadFile(p, dst); err != nil {
+				return nil, fmt.Errorf("failed to download image bundle %q: %w", p, err)
+			}
+			staged = append(staged, dst)
+			continue
+		}
+		abs := p
+		if !filepath.IsAbs(p) && baseDir != "" {
+			abs = filepath.Join(baseDir, p)
+		}
+		abs, err := filepath.Abs(abs)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("image bundle %q: %w", p, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("image bundle %q is not a file", p)
+		}
+		staged = append(staged, abs)
+	}
+	return staged, nil
+}
+
+// bundleDownloadTimeout caps the download of one image bundle, bundles can be large.
+var bundleDownloadTimeout = 10 * time.Minute
+
+func downloadFile(url, dst string) error {
+	client := &http.Client{Timeout: bundleDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// CgroupRoot is where the host mounts its cgroup hierarchy.
+const CgroupRoot = "/sys/fs/cgroup"
+
+// ErrCgroupV1 is returned by CheckCgroupV2 on hosts using the legacy cgroup v1 hierarchy.
+var ErrCgroupV1 = errors.New("host uses cgroup v1")
+
+// DetectCgroupVersion returns 2 when root is a unified (v2) hierarchy and 1 when it
+// is a legacy (v1) one.
+func DetectCgroupVersion(root string) (int, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return 2, nil
+	}
+	if _, err := os.Stat(root); err != nil {
+		return 0, fmt.Errorf("failed to read cgroup hierarchy: %w", err)
+	}
+	return 1, nil
+}
+
+// HostCgroupVersion returns the cgroup version of the local host, or 0 when it can't
+// be told: on macOS and Windows containers run in a VM whose cgroups aren't visible here.
+func HostCgroupVersion() (int, error) {
+	if runtime.GOOS != "linux" {
+		return 0, nil
+	}
+	return DetectCgroupVersion(CgroupRoot)
+}
+
+// CheckCgroupV2 fails with remediation guidance when the host uses cgroup v1, which
+// k0s nodes running in containers don't become ready on.
+func CheckCgroupV2() error {
+	v, err := HostCgroupVersion()
+	if err != nil || v != 1 {
+		return nil
+	}
+	return fmt.Errorf("%w: k0s nodes in containers need cgroup v2 (unified hierarchy). "+
+		"Enable it by booting the host with systemd.unified_cgroup_hierarchy=1 on the kernel command line, "+
+		"or use a distribution that defaults to cgroup v2", ErrCgroupV1)
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DNSNodePort is the node port on the controller that forwards to CoreDNS when DNS exposure is enabled.
+const DNSNodePort = 30053
+
+// dnsManifestName is the staged manifest file name; it sorts after the numbered user manifests.
+const dnsManifestName = "zz_k0da-dns.yaml"
+
+// dnsNodePortManifest exposes CoreDNS via a NodePort service so that a published
+// container port can route host DNS queries into the cluster.
+var dnsNodePortManifest = fmt.Sprintf(`apiVersion: v1
+kind: Service
+metadata:
+  name: k0da-dns
+  namespace: kube-system
+  labels:
+    app.kubernetes.io/managed-by: k0da
+spec:
+  type: NodePort
+  selector:
+    k8s-app: kube-dns
+  ports:
+  - name: dns
+    port: 53
+    targetPort: 53
+    protocol: UDP
+    nodePort: %[1]d
+  - name: dns-tcp
+    port: 53
+    targetPort: 53
+    protocol: TCP
+    nodePort: %[1]d
+`, DNSNodePort)
+
+// WriteDNSManifest stages the CoreDNS NodePort service manifest into destDir.
+func WriteDNSManifest(destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create manifests directory: %w", err)
+	}
+	dst := filepath.Join(destDir, dnsManifestName)
+	if err := os.WriteFile(dst, []byte(dnsNodePortManifest), 0644); err != nil {
+		return fmt.Errorf("failed to write DNS manifest to %q: %w", dst, err)
+	}
+	return nil
+}
+
+// DNSHostInstructions returns instructions for routing host queries for cluster
+// service names to the published DNS port.
+func DNSHostInstructions(hostIP string, port int) string {
+	if strings.TrimSpace(hostIP) == "" || hostIP == "0.0.0.0" {
+		hostIP = "127.0.0.1"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cluster DNS is published on %s:%d (udp/tcp).\n", hostIP, port)
+	fmt.Fprintf(&b, "Test it with: dig @%s -p %d kubernetes.default.svc.cluster.local\n", hostIP, port)
+	switch runtime.GOOS {
+	case "darwin":
+		b.WriteString("To resolve *.svc.cluster.local from the host, create /etc/resolver/svc.cluster.local with:\n")
+		fmt.Fprintf(&b, "  nameserver %s\n  port %d\n", hostIP, port)
+	case "linux":
+		b.WriteString("To resolve *.svc.cluster.local from the host with systemd-resolved, add to /etc/systemd/resolved.conf.d/k0da.conf:\n")
+		fmt.Fprintf(&b, "  [Resolve]\n  DNS=%s:%d\n  Domains=~svc.cluster.local\n", hostIP, port)
+		b.WriteString("then run: sudo systemctl restart systemd-resolved\n")
+	default:
+		b.WriteString("Point your resolver for the svc.cluster.local domain at the address above.\n")
+	}
+	b.WriteString("Note: resolved service IPs are only reachable from the host if you route the service CIDR to the node.")
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clusterScopedKinds are the built-in kinds without a namespace, which
+// setManifestNamespace leaves alone.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                       true,
+	"CertificateSigningRequest":        true,
+	"ClusterRole":                      true,
+	"ClusterRoleBinding":               true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"CustomResourceDefinition":         true,
+	"FlowSchema":                       true,
+	"IngressClass":                     true,
+	"MutatingWebhookConfiguration":     true,
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"PriorityClass":                    true,
+	"PriorityLevelConfiguration":       true,
+	"RuntimeClass":                     true,
+	"StorageClass":                     true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"ValidatingWebhookConfiguration":   true,
+	"VolumeAttachment":                 true,
+}
+
+// setManifestNamespace sets metadata.namespace on the objects of a multi-document YAML
+// manifest that don't have one, skipping built-in cluster-scoped kinds. Empty documents
+// are dropped; comments are kept.
+func setManifestNamespace(data []byte, namespace string) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		obj := doc.Content[0]
+		if kind := mappingValue(obj, "kind"); kind == nil || !clusterScopedKinds[kind.Value] {
+			metadata := mappingValue(obj, "metadata")
+			if metadata == nil {
+				metadata = &yaml.Node{Kind: yaml.MappingNode}
+				obj.Content = append(obj.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "metadata"}, metadata)
+			}
+			if metadata.Kind == yaml.MappingNode && mappingValue(metadata, "namespace") == nil {
+				metadata.Content = append(metadata.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: "namespace"},
+					&yaml.Node{Kind: yaml.ScalarNode, Value: namespace})
+			}
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetJoinTokenServer points a k0s join token at another API server address. A join
+// token is a gzipped, base64-encoded kubeconfig; the server of each of its clusters is
+// replaced, everything else is kept as is.
+func SetJoinTokenServer(token, server string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return "", fmt.Errorf("invalid join token: %w", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid join token: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("invalid join token: %w", err)
+	}
+
+	var kc map[string]any
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return "", fmt.Errorf("invalid join token: %w", err)
+	}
+	clusters, _ := kc["clusters"].([]any)
+	if len(clusters) == 0 {
+		return "", fmt.Errorf("invalid join token: no clusters in kubeconfig")
+	}
+	for _, c := range clusters {
+		named, ok := c.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("invalid join token: malformed cluster entry")
+		}
+		cluster, ok := named["cluster"].(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("invalid join token: malformed cluster entry")
+		}
+		cluster["server"] = server
+	}
+
+	data, err = yaml.Marshal(kc)
+	if err != nil {
+		return "", fmt.Errorf("marshal join token: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return "", fmt.Errorf("compress join token: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("compress join token: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -12,17 +15,20 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
 
 	k0daconfig "github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/lock"
+	"github.com/makhov/k0da/internal/paths"
 	"github.com/makhov/k0da/internal/runtime"
 )
 
-// WaitForK0sReady waits for k0s to be ready in a container
-func WaitForK0sReady(ctx context.Context, r runtime.Runtime, containerName, timeout string) error {
-	fmt.Printf("Waiting for cluster to be ready (timeout: %s)...\n", timeout)
+// WaitForK0sReady waits for k0s to be ready in a container, printing progress to out.
+func WaitForK0sReady(ctx context.Context, r runtime.Runtime, out io.Writer, containerName, timeout string) error {
+	fmt.Fprintf(out, "Waiting for cluster to be ready (timeout: %s)...\n", timeout)
 
 	// Parse timeout duration
 	timeoutDuration, err := time.ParseDuration(timeout)
@@ -39,7 +45,7 @@ func WaitForK0sReady(ctx context.Context, r runtime.Runtime, containerName, time
 		case <-ticker.C:
 			// Check if k0s status is responding
 			if isK0sReady(ctx, r, containerName) {
-				fmt.Println("✅ k0s is ready!")
+				fmt.Fprintln(out, "✅ k0s is ready!")
 				return nil
 			}
 
@@ -48,13 +54,90 @@ func WaitForK0sReady(ctx context.Context, r runtime.Runtime, containerName, time
 				return fmt.Errorf("timeout waiting for cluster to be ready after %s", timeout)
 			}
 
-			fmt.Print(".")
+			fmt.Fprint(out, ".")
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
+// WaitForSystemPodsReady waits until all kube-system pods (CoreDNS, CNI, kube-proxy, ...) report Ready.
+// It is a deeper check than WaitForK0sReady, which only verifies that the API server responds.
+func WaitForSystemPodsReady(ctx context.Context, r runtime.Runtime, out io.Writer, containerName, timeout string) error {
+	fmt.Fprintf(out, "Waiting for kube-system pods to be ready (timeout: %s)...\n", timeout)
+
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		timeoutDuration = 60 * time.Second
+	}
+
+	startTime := time.Now()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if areSystemPodsReady(ctx, r, containerName) {
+				fmt.Fprintln(out, "✅ kube-system pods are ready!")
+				return nil
+			}
+
+			if time.Since(startTime) > timeoutDuration {
+				return fmt.Errorf("timeout waiting for kube-system pods to be ready after %s", timeout)
+			}
+
+			fmt.Fprint(out, ".")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WaitForNodesReady waits until every node registered with the API server reports Ready.
+func WaitForNodesReady(ctx context.Context, r runtime.Runtime, out io.Writer, containerName, timeout string) error {
+	fmt.Fprintf(out, "Waiting for nodes to be ready (timeout: %s)...\n", timeout)
+
+	timeoutDuration, err := time.ParseDuration(timeout)
+	if err != nil {
+		timeoutDuration = 60 * time.Second
+	}
+
+	startTime := time.Now()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if areNodesReady(ctx, r, containerName) {
+				fmt.Fprintln(out, "✅ nodes are ready!")
+				return nil
+			}
+
+			if time.Since(startTime) > timeoutDuration {
+				return fmt.Errorf("timeout waiting for nodes to be ready after %s", timeout)
+			}
+
+			fmt.Fprint(out, ".")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// areNodesReady checks that at least one node is registered and all of them are Ready.
+func areNodesReady(ctx context.Context, r runtime.Runtime, containerName string) bool {
+	_, exit, err := r.ExecInContainer(ctx, containerName, []string{"k0s", "kubectl", "wait", "--for=condition=Ready", "nodes", "--all", "--timeout=5s"})
+	return err == nil && exit == 0
+}
+
+// areSystemPodsReady checks that kube-system has pods and all of them are Ready.
+func areSystemPodsReady(ctx context.Context, r runtime.Runtime, containerName string) bool {
+	_, exit, err := r.ExecInContainer(ctx, containerName, []string{"k0s", "kubectl", "wait", "--for=condition=Ready", "pods", "-n", "kube-system", "--all", "--timeout=5s"})
+	return err == nil && exit == 0
+}
+
 // isK0sReady checks if k0s is ready in a container
 func isK0sReady(ctx context.Context, r runtime.Runtime, containerName string) bool {
 	stdout, exit, err := r.ExecInContainer(ctx, containerName, []string{"k0s", "status"})
@@ -64,15 +147,45 @@ func isK0sReady(ctx context.Context, r runtime.Runtime, containerName string) bo
 	return strings.Contains(stdout, "Kube-api probing successful: true")
 }
 
-// AllocateHostPort reserves a free TCP port on the given host IP (defaults to 0.0.0.0).
-// It opens a listener on hostIP:0, reads the assigned port, then closes the listener
-// and returns the port number. Returns 0 if allocation failed.
+// Ports handed out by AllocateHostPort and not yet released; see ReleaseHostPort.
+var (
+	allocatedPortsMu sync.Mutex
+	allocatedPorts   = map[int]bool{}
+)
+
+// AllocateHostPort finds a free TCP port on hostIP (defaults to 0.0.0.0). The port is only probed free:
+// it is reserved against further AllocateHostPort calls in this process until
+// ReleaseHostPort, but another process may still take it before it is bound.
 func AllocateHostPort(hostIP string) (int, error) {
 	hip := strings.TrimSpace(hostIP)
 	if hip == "" {
 		hip = "0.0.0.0"
 	}
-	ln, err := net.Listen("tcp", hip+":0")
+	allocatedPortsMu.Lock()
+	defer allocatedPortsMu.Unlock()
+	for i := 0; i < 10; i++ {
+		port, err := probeFreePort(hip)
+		if err != nil {
+			return 0, err
+		}
+		if !allocatedPorts[port] {
+			allocatedPorts[port] = true
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("unable to allocate a free port")
+}
+
+// ReleaseHostPort returns a port from AllocateHostPort, typically once the
+// container runtime has bound it.
+func ReleaseHostPort(port int) {
+	allocatedPortsMu.Lock()
+	defer allocatedPortsMu.Unlock()
+	delete(allocatedPorts, port)
+}
+
+func probeFreePort(hostIP string) (int, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(hostIP, "0"))
 	if err != nil {
 		return 0, err
 	}
@@ -173,37 +286,96 @@ func SaveKubeconfig(kubeconfig *Kubeconfig, filePath string) error {
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+	// Replace the file a symlinked kubeconfig points to rather than the link itself.
+	if resolved, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = resolved
+	}
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	// Write a temp file next to the kubeconfig and rename it into place, so an
+	// interrupted write never leaves a truncated kubeconfig behind.
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
 		return fmt.Errorf("failed to write kubeconfig: %w", err)
 	}
 
 	return nil
 }
 
+// lockKubeconfig serializes the load-modify-save cycles of concurrent k0da
+// invocations, e.g. parallel creates in CI. Call Release on the returned lock.
+func lockKubeconfig() (*lock.Lock, error) {
+	l, err := lock.Acquire(paths.KubeconfigLock(), lock.DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock kubeconfig: %w", err)
+	}
+	return l, nil
+}
+
 // CopyManifestsToDir copies provided manifest file paths into destination directory.
-// Paths are resolved relative to baseDir when not absolute. Files are written
-// into destDir with a numeric prefix to preserve ordering when provided.
+// Paths are resolved relative to baseDir when not absolute. Files are named after
+// their order and source (see manifestFileName), so they sort by order and adding,
+// removing or reordering manifests in the list doesn't rename the others.
+//
+// destDir ends up holding exactly the manifests of cc, so manifests dropped from the
+// config are removed, also when none are left. k0s applies the directory as one stack
+// and prunes the resources of removed files. The manifests are staged next to destDir
+// first, so a failing download leaves the previous ones in place.
 func CopyManifestsToDir(cc *k0daconfig.ClusterConfig, destDir string) error {
-	if cc == nil || len(cc.Spec.K0s.Manifests) == 0 {
+	if cc == nil {
 		return nil
 	}
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return fmt.Errorf("failed to create manifests directory: %w", err)
 	}
-	if err := RemoveAllFiles(destDir); err != nil {
-		return fmt.Errorf("failed to clean manifests dir: %w", err)
+	staging, err := os.MkdirTemp(filepath.Dir(destDir), ".manifests-*")
+	if err != nil {
+		return fmt.Errorf("failed to create manifests staging directory: %w", err)
 	}
+	defer func() { _ = os.RemoveAll(staging) }()
 
 	baseDir := ""
 	if strings.TrimSpace(cc.SourcePath) != "" {
 		baseDir = filepath.Dir(cc.SourcePath)
 	}
+	if err := copyManifestsToDir(cc.Spec.K0s.Manifests, baseDir, staging); err != nil {
+		return err
+	}
+	if cc.Spec.Options.ExposeDNS {
+		if err := WriteDNSManifest(staging); err != nil {
+			return err
+		}
+	}
 
-	return copyManifestsToDir(cc.Spec.K0s.Manifests, baseDir, destDir)
+	// destDir is bind-mounted into the node, so its contents are replaced rather
+	// than the directory itself.
+	if err := RemoveAllFiles(destDir); err != nil {
+		return fmt.Errorf("failed to clean manifests dir: %w", err)
+	}
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(staging, e.Name()), filepath.Join(destDir, e.Name())); err != nil {
+			return fmt.Errorf("failed to stage manifest %s: %w", e.Name(), err)
+		}
+	}
+	return nil
 }
 
 func isURL(str string) bool {
@@ -219,12 +391,28 @@ func urlBase(str string) string {
 	return path.Base(u.Path)
 }
 
-func copyManifestsToDir(paths []string, baseDir string, destDir string) error {
-	for i, mp := range paths {
-		p := strings.TrimSpace(mp)
-		if p == "" {
+// manifestFileName is the staged name of a manifest, <order>-<hash>-<base>: the
+// zero-padded order of the manifest sorts the files, and the short hash of the source
+// as written in the config keeps the name stable and apart from other sources with
+// the same base name.
+func manifestFileName(order int, source, baseName string) string {
+	sum := sha256.Sum256([]byte(source))
+	return fmt.Sprintf("%03d-%s-%s", order, hex.EncodeToString(sum[:4]), baseName)
+}
+
+func copyManifestsToDir(manifests []k0daconfig.Manifest, baseDir string, destDir string) error {
+	seen := map[string]bool{}
+	for _, m := range manifests {
+		p := strings.TrimSpace(m.Path)
+		// The same file may be staged once per namespace.
+		source := p
+		if m.Namespace != "" {
+			source += "?namespace=" + m.Namespace
+		}
+		if p == "" || seen[source] {
 			continue
 		}
+		seen[source] = true
 		var (
 			data     []byte
 			baseName string
@@ -257,8 +445,12 @@ func copyManifestsToDir(paths []string, baseDir string, destDir string) error {
 			}
 			baseName = filepath.Base(abs)
 		}
-		// Prefix with index to keep deterministic order
-		dst := filepath.Join(destDir, fmt.Sprintf("%03d_%s", i, baseName))
+		if m.Namespace != "" {
+			if data, err = setManifestNamespace(data, m.Namespace); err != nil {
+				return fmt.Errorf("failed to set namespace of manifest %q: %w", p, err)
+			}
+		}
+		dst := filepath.Join(destDir, manifestFileName(m.Order, source, baseName))
 		if err := os.WriteFile(dst, data, 0644); err != nil {
 			return fmt.Errorf("failed to write manifest to %q: %w", dst, err)
 		}
@@ -275,62 +467,113 @@ func RemoveAllFiles(dir string) error {
 		}
 		return err
 	}
+	var errs []error
 	for _, e := range entries {
 		if e.Type().IsRegular() || (e.Type() == fs.ModeSymlink) {
-			_ = os.Remove(filepath.Join(dir, e.Name()))
+			errs = append(errs, os.Remove(filepath.Join(dir, e.Name())))
 			continue
 		}
 		// Also remove nested files if any leftover directories exist
-		_ = os.RemoveAll(filepath.Join(dir, e.Name()))
+		errs = append(errs, os.RemoveAll(filepath.Join(dir, e.Name())))
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-// defaultKubeconfigPath returns the path to the default kubeconfig file
-// (first entry of KUBECONFIG if set, otherwise $HOME/.kube/config)
-func defaultKubeconfigPath() string {
-	if v := strings.TrimSpace(os.Getenv("KUBECONFIG")); v != "" {
-		parts := strings.Split(v, string(os.PathListSeparator))
-		if len(parts) > 0 && strings.TrimSpace(parts[0]) != "" {
-			return parts[0]
+// Retries for `k0s kubeconfig admin`; variables so tests can shorten them.
+var (
+	adminKubeconfigAttempts = 10
+	adminKubeconfigInterval = 1 * time.Second
+)
+
+// getAdminKubeconfig gets the admin kubeconfig from a controller container.
+// Retry a few times: right after startup k0s may not have generated it yet.
+func getAdminKubeconfig(ctx context.Context, b runtime.Runtime, containerName string) (*Kubeconfig, error) {
+	var lastErr error
+	for i := 0; i < adminKubeconfigAttempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(adminKubeconfigInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
 		}
-	}
-	home := os.Getenv("HOME")
-	if strings.TrimSpace(home) == "" {
-		if h, err := os.UserHomeDir(); err == nil {
-			home = h
+		stdout, exit, err := b.ExecInContainer(ctx, containerName, []string{"k0s", "kubeconfig", "admin"})
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get kubeconfig from container: %w", err)
+			continue
+		}
+		if exit != 0 {
+			lastErr = fmt.Errorf("failed to get kubeconfig from container: exit code %d", exit)
+			continue
+		}
+		var kc Kubeconfig
+		if err := yaml.Unmarshal([]byte(stdout), &kc); err != nil {
+			lastErr = fmt.Errorf("failed to parse container kubeconfig: %w (output: %q)", err, stdout)
+			continue
+		}
+		if err := validateAdminKubeconfig(&kc); err != nil {
+			lastErr = fmt.Errorf("invalid container kubeconfig: %w (output: %q)", err, stdout)
+			continue
 		}
+		return &kc, nil
 	}
-	return filepath.Join(home, ".kube", "config")
+	return nil, lastErr
 }
 
-// AddClusterToKubeconfig adds a new cluster to the default kubeconfig
-func AddClusterToKubeconfig(ctx context.Context, b runtime.Runtime, clusterName, containerName string) error {
-	// Get the original kubeconfig from the container
-	stdout, exit, err := b.ExecInContainer(ctx, containerName, []string{"k0s", "kubeconfig", "admin"})
-	if err != nil || exit != 0 {
-		return fmt.Errorf("failed to get kubeconfig from container: %v", err)
-	}
-
-	// Parse the container kubeconfig
-	var containerKubeconfig Kubeconfig
-	if err := yaml.Unmarshal([]byte(stdout), &containerKubeconfig); err != nil {
-		return fmt.Errorf("failed to parse container kubeconfig: %w", err)
+// validateAdminKubeconfig checks that kc has everything AddClusterToKubeconfig
+// merges, so a bogus exec output never ends up in the user's kubeconfig.
+func validateAdminKubeconfig(kc *Kubeconfig) error {
+	switch {
+	case len(kc.Clusters) == 0:
+		return fmt.Errorf("no cluster")
+	case kc.Clusters[0].Cluster.Server == "":
+		return fmt.Errorf("cluster %q has no server", kc.Clusters[0].Name)
+	case len(kc.Contexts) == 0:
+		return fmt.Errorf("no context")
+	case len(kc.Users) == 0:
+		return fmt.Errorf("no user")
 	}
+	return nil
+}
 
+// AddClusterToKubeconfig adds a new cluster to the default kubeconfig
+func AddClusterToKubeconfig(ctx context.Context, b runtime.Runtime, clusterName, containerName string) error {
 	// Get the port mapping for the container
 	port, err := GetContainerPort(ctx, b, containerName)
 	if err != nil {
 		return fmt.Errorf("failed to get container port: %w", err)
 	}
+	host := "127.0.0.1"
+	if cc, err := k0daconfig.LoadClusterConfig(paths.StoredConfigPath(clusterName)); err == nil && cc.Spec.Options.APIServerAddress != "" {
+		host = cc.Spec.Options.APIServerAddress
+	}
+	return AddClusterToKubeconfigWithServer(ctx, b, clusterName, containerName, "https://"+net.JoinHostPort(host, port))
+}
+
+// AddClusterToKubeconfigWithServer is AddClusterToKubeconfig for a known API server URL,
+// e.g. the one the API port was just published on.
+func AddClusterToKubeconfigWithServer(ctx context.Context, b runtime.Runtime, clusterName, containerName, server string) error {
+	// Get the original kubeconfig from the container
+	containerKubeconfig, err := getAdminKubeconfig(ctx, b, containerName)
+	if err != nil {
+		return err
+	}
 
 	// Update the server URL with correct host and port
 	if len(containerKubeconfig.Clusters) > 0 {
-		containerKubeconfig.Clusters[0].Cluster.Server = fmt.Sprintf("https://127.0.0.1:%s", port)
+		containerKubeconfig.Clusters[0].Cluster.Server = server
 	}
 
+	l, err := lockKubeconfig()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	// Load or create the default kubeconfig
-	kubeconfigPath := defaultKubeconfigPath()
+	kubeconfigPath := paths.Kubeconfig()
 	var kc *Kubeconfig
 	if _, err := os.Stat(kubeconfigPath); os.IsNotExist(err) {
 		kc = &Kubeconfig{
@@ -394,13 +637,17 @@ func AddClusterToKubeconfig(ctx context.Context, b runtime.Runtime, clusterName,
 
 // RemoveClusterFromKubeconfig removes a cluster from the default kubeconfig
 func RemoveClusterFromKubeconfig(clusterName string) error {
-	kubeconfigPath := defaultKubeconfigPath()
+	kubeconfigPath := paths.Kubeconfig()
 
 	var kc *Kubeconfig
 	if _, err := os.Stat(kubeconfigPath); os.IsNotExist(err) {
 		return nil
 	}
-	var err error
+	l, err := lockKubeconfig()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
 	kc, err = LoadKubeconfig(kubeconfigPath)
 	if err != nil {
 		return fmt.Errorf("failed to load kubeconfig: %w", err)
@@ -426,6 +673,63 @@ func RemoveClusterFromKubeconfig(clusterName string) error {
 	return nil
 }
 
+// RenameClusterInKubeconfig renames the cluster, context and user of a cluster in the
+// default kubeconfig, keeping it the current context if it was.
+func RenameClusterInKubeconfig(oldName, newName string) error {
+	kubeconfigPath := paths.Kubeconfig()
+	if _, err := os.Stat(kubeconfigPath); os.IsNotExist(err) {
+		return nil
+	}
+	l, err := lockKubeconfig()
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	kc, err := LoadKubeconfig(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	kc = renameClusterInKubeconfig(kc, oldName, newName)
+	if err := SaveKubeconfig(kc, kubeconfigPath); err != nil {
+		return fmt.Errorf("failed to save kubeconfig: %w", err)
+	}
+	return nil
+}
+
+func renameClusterInKubeconfig(kubeconfig *Kubeconfig, oldName, newName string) *Kubeconfig {
+	from := fmt.Sprintf("k0da-%s", oldName)
+	to := fmt.Sprintf("k0da-%s", newName)
+
+	// Drop stale entries of the new name so the renamed ones don't clash with them.
+	kubeconfig = removeClusterFromKubeconfig(kubeconfig, newName)
+	for i := range kubeconfig.Clusters {
+		if kubeconfig.Clusters[i].Name == from {
+			kubeconfig.Clusters[i].Name = to
+		}
+	}
+	for i := range kubeconfig.Contexts {
+		c := &kubeconfig.Contexts[i]
+		if c.Name == from {
+			c.Name = to
+		}
+		if c.Context.Cluster == from {
+			c.Context.Cluster = to
+		}
+		if c.Context.User == from {
+			c.Context.User = to
+		}
+	}
+	for i := range kubeconfig.Users {
+		if kubeconfig.Users[i].Name == from {
+			kubeconfig.Users[i].Name = to
+		}
+	}
+	if kubeconfig.CurrentContext == from {
+		kubeconfig.CurrentContext = to
+	}
+	return kubeconfig
+}
+
 // removeClusterFromKubeconfig is a helper function to remove a cluster from kubeconfig
 func removeClusterFromKubeconfig(kubeconfig *Kubeconfig, clusterName string) *Kubeconfig {
 	clusterNameFormatted := fmt.Sprintf("k0da-%s", clusterName)
@@ -1,12 +1,22 @@
 package utils
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	k0daconfig "github.com/makhov/k0da/internal/config"
 	"github.com/makhov/k0da/internal/runtime"
 	"github.com/stretchr/testify/require"
 )
@@ -22,7 +32,11 @@ type fakeRuntime struct {
 	portErr error
 }
 
-func (f *fakeRuntime) Name() string { return "fake" }
+func (f *fakeRuntime) Name() string     { return "fake" }
+func (f *fakeRuntime) Describe() string { return "fake (test)" }
+func (f *fakeRuntime) Version(_ context.Context) (string, error) {
+	return "0.0.0-fake", nil
+}
 func (f *fakeRuntime) RunContainer(_ context.Context, _ runtime.RunContainerOptions) (string, error) {
 	return "", nil
 }
@@ -37,9 +51,34 @@ func (f *fakeRuntime) RemoveContainer(_ context.Context, _ string) error { retur
 func (f *fakeRuntime) ExecInContainer(_ context.Context, _ string, _ []string) (string, int, error) {
 	return f.execStdout, f.execExitCode, f.execErr
 }
+func (f *fakeRuntime) ExecInContainerStream(ctx context.Context, name string, command []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if stdout != nil {
+		_, _ = io.WriteString(stdout, f.execStdout)
+	}
+	return f.execExitCode, f.execErr
+}
+
+func (f *fakeRuntime) ExecInContainerTTY(ctx context.Context, name string, command []string, stdin io.Reader, stdout io.Writer) (int, error) {
+	return f.ExecInContainerStream(ctx, name, command, stdin, stdout, stdout)
+}
+
+func (f *fakeRuntime) ExecInContainerInteractive(ctx context.Context, name string, command []string) (int, error) {
+	return f.execExitCode, f.execErr
+}
+
 func (f *fakeRuntime) GetPortMapping(_ context.Context, _ string, _ int, _ string) (string, int, error) {
 	return f.portIP, f.port, f.portErr
 }
+func (f *fakeRuntime) ContainerIP(_ context.Context, _, _ string) (string, error) {
+	return "", nil
+}
+func (f *fakeRuntime) ConnectNetwork(_ context.Context, _, _ string) error {
+	return nil
+}
+func (f *fakeRuntime) ContainerLogs(_ context.Context, _ string, _ runtime.LogsOptions, stdout, _ io.Writer) error {
+	_, err := io.WriteString(stdout, f.execStdout)
+	return err
+}
 func (f *fakeRuntime) VolumeExists(_ context.Context, _ string) (bool, error) { return false, nil }
 func (f *fakeRuntime) RemoveVolume(_ context.Context, _ string) error         { return nil }
 func (f *fakeRuntime) ListContainersByLabel(_ context.Context, _ map[string]string, _ bool) ([]runtime.ContainerInfo, error) {
@@ -48,11 +87,44 @@ func (f *fakeRuntime) ListContainersByLabel(_ context.Context, _ map[string]stri
 func (f *fakeRuntime) CopyToContainer(_ context.Context, _ string, _ string, _ string) error {
 	return nil
 }
+func (f *fakeRuntime) PullImage(_ context.Context, _ string) error {
+	return nil
+}
+func (f *fakeRuntime) ImageExists(_ context.Context, _ string) (bool, error) {
+	return true, nil
+}
+func (f *fakeRuntime) ImageID(_ context.Context, _ string) (string, error) {
+	return "", nil
+}
 func (f *fakeRuntime) SaveImageToTar(_ context.Context, _ string, _ string) error {
 	return nil
 }
 
-func (f *fakeRuntime) EnsureNetwork(_ context.Context, _ string) error { return nil }
+func (f *fakeRuntime) RenameContainer(_ context.Context, _, _ string) error { return nil }
+func (f *fakeRuntime) RecreateContainer(_ context.Context, _ string, _ runtime.RecreateOptions) error {
+	return nil
+}
+
+func (f *fakeRuntime) RecreateWithLabels(_ context.Context, _ string, _ map[string]string) error {
+	return nil
+}
+func (f *fakeRuntime) EnsureNetwork(_ context.Context, _ string, _ runtime.NetworkOptions) error {
+	return nil
+}
+func (f *fakeRuntime) NetworkSubnets(_ context.Context, _ string) ([]string, error) {
+	return nil, nil
+}
+func (f *fakeRuntime) NetworkExists(_ context.Context, _ string) (bool, error) {
+	return true, nil
+}
+
+// setHome points the user home directory at dir for the test. os.UserHomeDir reads
+// $USERPROFILE on Windows, so both variables are set.
+func setHome(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
 
 func TestWaitForK0sReady_SucceedsImmediately(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -63,14 +135,14 @@ func TestWaitForK0sReady_SucceedsImmediately(t *testing.T) {
 		execExitCode: 0,
 	}
 
-	err := WaitForK0sReady(ctx, r, "test", "2s")
+	err := WaitForK0sReady(ctx, r, io.Discard, "test", "2s")
 	require.NoError(t, err)
 }
 
 func TestAddAndRemoveClusterToUnifiedKubeconfig(t *testing.T) {
-	// Isolated HOME
+	// Isolated home
 	tmp := t.TempDir()
-	t.Setenv("HOME", tmp)
+	setHome(t, tmp)
 
 	adminKubeconfigYAML := `apiVersion: v1
 kind: Config
@@ -113,9 +185,442 @@ users:
 	require.Equal(t, "https://127.0.0.1:52345", kc.Clusters[0].Cluster.Server)
 }
 
+// flakyExecRuntime returns empty exec output for the first failures calls.
+type flakyExecRuntime struct {
+	*fakeRuntime
+	failures int
+	calls    int
+}
+
+func (f *flakyExecRuntime) ExecInContainer(ctx context.Context, name string, command []string) (string, int, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return "", 0, nil
+	}
+	return f.fakeRuntime.ExecInContainer(ctx, name, command)
+}
+
+func TestGetAdminKubeconfig_Retries(t *testing.T) {
+	interval := adminKubeconfigInterval
+	adminKubeconfigInterval = time.Millisecond
+	t.Cleanup(func() { adminKubeconfigInterval = interval })
+
+	valid := "clusters:\n- name: k0s\n  cluster:\n    server: https://localhost:6443\ncontexts:\n- name: k0s\nusers:\n- name: k0s\n"
+	r := &flakyExecRuntime{fakeRuntime: &fakeRuntime{execStdout: valid}, failures: 3}
+	kc, err := getAdminKubeconfig(context.Background(), r, "test")
+	require.NoError(t, err)
+	require.Equal(t, 4, r.calls)
+	require.Equal(t, "https://localhost:6443", kc.Clusters[0].Cluster.Server)
+
+	r = &flakyExecRuntime{fakeRuntime: &fakeRuntime{}, failures: adminKubeconfigAttempts}
+	_, err = getAdminKubeconfig(context.Background(), r, "test")
+	require.ErrorContains(t, err, "no cluster")
+	require.Equal(t, adminKubeconfigAttempts, r.calls)
+
+	// Cancellation ends the wait between attempts.
+	adminKubeconfigInterval = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r = &flakyExecRuntime{fakeRuntime: &fakeRuntime{}, failures: adminKubeconfigAttempts}
+	_, err = getAdminKubeconfig(ctx, r, "test")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, r.calls)
+}
+
+func TestAddClusterToKubeconfig_GarbageOutput(t *testing.T) {
+	interval := adminKubeconfigInterval
+	adminKubeconfigInterval = time.Millisecond
+	t.Cleanup(func() { adminKubeconfigInterval = interval })
+	tmp := t.TempDir()
+	setHome(t, tmp)
+	t.Setenv("KUBECONFIG", "")
+
+	for _, out := range []string{
+		"Error: open /var/lib/k0s/pki/admin.conf: no such file or directory",
+		"apiVersion: v1\nkind: Config\n",
+		"clusters:\n- name: k0s\n  cluster: {}\ncontexts:\n- name: k0s\nusers:\n- name: k0s\n",
+	} {
+		r := &fakeRuntime{execStdout: out, portIP: "0.0.0.0", port: 52345}
+		err := AddClusterToKubeconfig(context.Background(), r, "test", "test")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), fmt.Sprintf("%q", out))
+		require.NoFileExists(t, filepath.Join(tmp, ".kube", "config"))
+	}
+}
+
+func TestAddClusterToKubeconfig_Concurrent(t *testing.T) {
+	tmp := t.TempDir()
+	setHome(t, tmp)
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("K0DA_HOME", filepath.Join(tmp, ".k0da"))
+
+	admin := "clusters:\n- name: k0s\n  cluster:\n    server: https://localhost:6443\ncontexts:\n- name: k0s\nusers:\n- name: k0s\n"
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &fakeRuntime{execStdout: admin, portIP: "0.0.0.0", port: 50000 + i}
+			errs <- AddClusterToKubeconfig(context.Background(), r, fmt.Sprintf("c%d", i), "test")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	kc, err := LoadKubeconfig(filepath.Join(tmp, ".kube", "config"))
+	require.NoError(t, err)
+	require.Len(t, kc.Clusters, 8)
+	require.Len(t, kc.Contexts, 8)
+	entries, err := os.ReadDir(filepath.Join(tmp, ".kube"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temp files must not be left behind")
+}
+
 func TestGetContainerPort(t *testing.T) {
 	r := &fakeRuntime{portIP: "0.0.0.0", port: 60000}
 	port, err := GetContainerPort(context.Background(), r, "any")
 	require.NoError(t, err)
 	require.Equal(t, "60000", port)
 }
+
+func TestCopyManifestsToDir_ExposeDNS(t *testing.T) {
+	dest := t.TempDir()
+	cc := &k0daconfig.ClusterConfig{}
+	cc.Spec.Options.ExposeDNS = true
+
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	data, err := os.ReadFile(filepath.Join(dest, dnsManifestName))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "nodePort: 30053")
+	require.Contains(t, string(data), "k8s-app: kube-dns")
+}
+
+func manifests(paths ...string) []k0daconfig.Manifest {
+	var out []k0daconfig.Manifest
+	for _, p := range paths {
+		out = append(out, k0daconfig.Manifest{Path: p})
+	}
+	return out
+}
+
+func TestCopyManifestsToDir_RemovesDroppedManifests(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte("kind: "+name), 0644))
+	}
+	dest := filepath.Join(t.TempDir(), "manifests")
+	staged := func() []string {
+		entries, err := os.ReadDir(dest)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	cc := &k0daconfig.ClusterConfig{SourcePath: filepath.Join(src, "cluster.yaml")}
+	cc.Spec.K0s.Manifests = manifests("a.yaml", "b.yaml")
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	require.Len(t, staged(), 2)
+
+	cc.Spec.K0s.Manifests = manifests("b.yaml")
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	names := staged()
+	require.Len(t, names, 1)
+	data, err := os.ReadFile(filepath.Join(dest, names[0]))
+	require.NoError(t, err)
+	require.Equal(t, "kind: b.yaml", string(data))
+
+	// A failing manifest keeps the staged ones.
+	cc.Spec.K0s.Manifests = manifests("missing.yaml")
+	require.Error(t, CopyManifestsToDir(cc, dest))
+	require.Equal(t, names, staged())
+
+	cc.Spec.K0s.Manifests = nil
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	require.Empty(t, staged())
+
+	// Only the manifests dir is left next to it.
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestCopyManifestsToDir_StableNames(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"first.yaml", "a.yaml", "b.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte("kind: "+name), 0644))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(src, "other"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "other", "a.yaml"), []byte("kind: other"), 0644))
+	dest := filepath.Join(t.TempDir(), "manifests")
+	staged := func() map[string]string {
+		entries, err := os.ReadDir(dest)
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(dest, e.Name()))
+			require.NoError(t, err)
+			files[string(data)] = e.Name()
+		}
+		return files
+	}
+
+	cc := &k0daconfig.ClusterConfig{SourcePath: filepath.Join(src, "cluster.yaml")}
+	cc.Spec.K0s.Manifests = manifests("a.yaml", "b.yaml", "other/a.yaml")
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	before := staged()
+	require.Len(t, before, 3)
+
+	cc.Spec.K0s.Manifests = manifests("first.yaml", "b.yaml", "a.yaml", "other/a.yaml", "a.yaml")
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+	after := staged()
+	require.Len(t, after, 4)
+	for kind, name := range before {
+		require.Equal(t, name, after[kind], kind)
+	}
+}
+
+func TestCopyManifestsToDir_Order(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"app.yaml", "crds.yaml", "namespace.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(src, name), []byte("kind: "+name), 0644))
+	}
+	dest := filepath.Join(t.TempDir(), "manifests")
+	cc := &k0daconfig.ClusterConfig{SourcePath: filepath.Join(src, "cluster.yaml")}
+	cc.Spec.K0s.Manifests = []k0daconfig.Manifest{{Path: "app.yaml", Order: 20}, {Path: "crds.yaml", Order: 10}, {Path: "namespace.yaml"}}
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	var kinds []string
+	for _, e := range entries {
+		require.Regexp(t, `^\d{3}-[0-9a-f]{8}-`, e.Name())
+		data, err := os.ReadFile(filepath.Join(dest, e.Name()))
+		require.NoError(t, err)
+		kinds = append(kinds, string(data))
+	}
+	require.Equal(t, []string{"kind: namespace.yaml", "kind: crds.yaml", "kind: app.yaml"}, kinds)
+}
+
+func TestSetManifestNamespace(t *testing.T) {
+	in := `# app
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: kept
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+---
+apiVersion: v1
+kind: Secret
+`
+	out, err := setManifestNamespace([]byte(in), "team-a")
+	require.NoError(t, err)
+	require.Equal(t, `# app
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team-a
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: kept
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  namespace: team-a
+`, string(out))
+
+	_, err = setManifestNamespace([]byte("kind: [unclosed"), "team-a")
+	require.Error(t, err)
+}
+
+func TestCopyManifestsToDir_Namespace(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "cm.yaml"), []byte("kind: ConfigMap\nmetadata:\n  name: a\n"), 0644))
+	dest := t.TempDir()
+	cc := &k0daconfig.ClusterConfig{SourcePath: filepath.Join(src, "cluster.yaml")}
+	cc.Spec.K0s.Manifests = []k0daconfig.Manifest{{Path: "cm.yaml"}, {Path: "cm.yaml", Namespace: "team-a"}}
+	require.NoError(t, CopyManifestsToDir(cc, dest))
+
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	var namespaced int
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dest, e.Name()))
+		require.NoError(t, err)
+		if strings.Contains(string(data), "namespace: team-a") {
+			namespaced++
+		}
+	}
+	require.Equal(t, 1, namespaced)
+}
+
+func TestWaitForSystemPodsReady_SucceedsImmediately(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	r := &fakeRuntime{execExitCode: 0}
+	require.NoError(t, WaitForSystemPodsReady(ctx, r, io.Discard, "test", "2s"))
+}
+
+func TestWaitForSystemPodsReady_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r := &fakeRuntime{execExitCode: 1}
+	err := WaitForSystemPodsReady(ctx, r, io.Discard, "test", "1s")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "kube-system pods")
+}
+
+func TestWaitForNodesReady(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require.NoError(t, WaitForNodesReady(ctx, &fakeRuntime{execExitCode: 0}, io.Discard, "test", "2s"))
+
+	err := WaitForNodesReady(ctx, &fakeRuntime{execExitCode: 1}, io.Discard, "test", "1s")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nodes")
+}
+
+func TestParseEnvFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(p, []byte("# comment\n\nA=1\nexport B = two words \nC=\"quoted=value\"\nD='single'\nE=\n"), 0644))
+	env, err := ParseEnvFile(p)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"A": "1", "B": "two words", "C": "quoted=value", "D": "single", "E": ""}, env)
+
+	bad := filepath.Join(t.TempDir(), "bad.env")
+	require.NoError(t, os.WriteFile(bad, []byte("A=1\nnot a pair\n"), 0644))
+	_, err = ParseEnvFile(bad)
+	require.ErrorContains(t, err, "bad.env:2")
+}
+
+func TestDetectCgroupVersion(t *testing.T) {
+	v2 := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(v2, "cgroup.controllers"), []byte("cpu memory pids\n"), 0644))
+	v, err := DetectCgroupVersion(v2)
+	require.NoError(t, err)
+	require.Equal(t, 2, v)
+
+	v, err = DetectCgroupVersion(t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	_, err = DetectCgroupVersion(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestRenameClusterInKubeconfig(t *testing.T) {
+	kc := &Kubeconfig{
+		Clusters:       []NamedCluster{{Name: "k0da-tmp"}, {Name: "k0da-prod"}, {Name: "other"}},
+		Contexts:       []NamedContext{{Name: "k0da-tmp", Context: Context{Cluster: "k0da-tmp", User: "k0da-tmp"}}},
+		Users:          []NamedUser{{Name: "k0da-tmp"}},
+		CurrentContext: "k0da-tmp",
+	}
+
+	kc = renameClusterInKubeconfig(kc, "tmp", "prod")
+	require.Equal(t, []NamedCluster{{Name: "k0da-prod"}, {Name: "other"}}, kc.Clusters)
+	require.Equal(t, []NamedContext{{Name: "k0da-prod", Context: Context{Cluster: "k0da-prod", User: "k0da-prod"}}}, kc.Contexts)
+	require.Equal(t, []NamedUser{{Name: "k0da-prod"}}, kc.Users)
+	require.Equal(t, "k0da-prod", kc.CurrentContext)
+}
+
+func TestSetJoinTokenServer(t *testing.T) {
+	kc := `apiVersion: v1
+kind: Config
+clusters:
+- name: k0s
+  cluster:
+    server: https://10.0.0.1:6443
+    certificate-authority-data: Y2E=
+users:
+- name: kubelet-bootstrap
+  user:
+    token: abc.def
+`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(kc))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	token := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	rewritten, err := SetJoinTokenServer(token+"\n", "https://controller.example.com:6443")
+	require.NoError(t, err)
+
+	raw, err := base64.StdEncoding.DecodeString(rewritten)
+	require.NoError(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "server: https://controller.example.com:6443")
+	require.Contains(t, string(data), "token: abc.def")
+
+	_, err = SetJoinTokenServer("not a token", "https://x:6443")
+	require.Error(t, err)
+}
+
+func TestStageImageBundles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "local.tar"), []byte("local"), 0644))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("remote"))
+	}))
+	defer srv.Close()
+
+	cc := &k0daconfig.ClusterConfig{SourcePath: filepath.Join(dir, "k0da.yaml")}
+	cc.Spec.K0s.ImageBundles = []string{"local.tar", srv.URL + "/bundles/remote.tar"}
+	dest := filepath.Join(dir, "staged")
+	bundles, err := StageImageBundles(cc, dest)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "local.tar"), filepath.Join(dest, "001_remote.tar")}, bundles)
+	data, err := os.ReadFile(bundles[1])
+	require.NoError(t, err)
+	require.Equal(t, "remote", string(data))
+
+	cc.Spec.K0s.ImageBundles = []string{"missing.tar"}
+	_, err = StageImageBundles(cc, dest)
+	require.ErrorContains(t, err, "missing.tar")
+
+	// A stalled download gives up.
+	timeout := bundleDownloadTimeout
+	bundleDownloadTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { bundleDownloadTimeout = timeout })
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer stalled.Close()
+	cc.Spec.K0s.ImageBundles = []string{stalled.URL + "/stalled.tar"}
+	_, err = StageImageBundles(cc, dest)
+	require.ErrorContains(t, err, "stalled.tar")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/makhov/k0da/internal/plugins"
 	"os"
@@ -15,6 +16,10 @@ func main() {
 	}
 
 	if err := cmd.Execute(); err != nil {
+		var exitErr *cmd.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
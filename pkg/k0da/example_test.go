@@ -0,0 +1,138 @@
+package k0da_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/makhov/k0da/pkg/k0da"
+)
+
+// fakeRuntime keeps containers in memory instead of running them. Runtime methods the
+// example doesn't reach are left to the embedded nil interface.
+type fakeRuntime struct {
+	k0da.Runtime
+	containers []k0da.ContainerInfo
+}
+
+func (r *fakeRuntime) Name() string                            { return "fake" }
+func (r *fakeRuntime) PullImage(context.Context, string) error { return nil }
+func (r *fakeRuntime) ImageExists(context.Context, string) (bool, error) {
+	return true, nil
+}
+func (r *fakeRuntime) StopContainer(context.Context, string) error { return nil }
+func (r *fakeRuntime) EnsureNetwork(context.Context, string, k0da.NetworkOptions) error {
+	return nil
+}
+func (r *fakeRuntime) VolumeExists(context.Context, string) (bool, error) { return false, nil }
+
+func (r *fakeRuntime) RunContainer(_ context.Context, opts k0da.RunContainerOptions) (string, error) {
+	r.containers = append(r.containers, k0da.ContainerInfo{ID: opts.Name, Name: opts.Name, Image: opts.Image, State: k0da.ContainerRunning, Labels: opts.Labels})
+	return opts.Name, nil
+}
+
+func (r *fakeRuntime) ContainerIsRunning(_ context.Context, name string) (bool, error) {
+	for _, c := range r.containers {
+		if c.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRuntime) RemoveContainer(_ context.Context, name string) error {
+	for i, c := range r.containers {
+		if c.Name == name {
+			r.containers = append(r.containers[:i], r.containers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no such container: %s", name)
+}
+
+func (r *fakeRuntime) ListContainersByLabel(_ context.Context, selector map[string]string, _ bool) ([]k0da.ContainerInfo, error) {
+	var out []k0da.ContainerInfo
+	for _, c := range r.containers {
+		match := true
+		for k, v := range selector {
+			if c.Labels[k] != v {
+				match = false
+			}
+		}
+		if match {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// ExecInContainer answers `k0s kubeconfig admin`, which Create runs to write the
+// kubeconfig context.
+func (r *fakeRuntime) ExecInContainer(_ context.Context, _ string, command []string) (string, int, error) {
+	if strings.Join(command, " ") == "k0s kubeconfig admin" {
+		return "clusters:\n- name: k0s\n  cluster:\n    server: https://localhost:6443\ncontexts:\n- name: k0s\nusers:\n- name: k0s\n", 0, nil
+	}
+	return "", 1, nil
+}
+
+func ExampleClusterConfig() {
+	cc := &k0da.ClusterConfig{Kind: "Cluster", APIVersion: k0da.APIVersion}
+	cc.Spec.Nodes = []k0da.NodeSpec{
+		{Name: "cp", Role: "controller", Ports: []k0da.Port{{ContainerPort: 30080, HostPort: 8080}}},
+		{Role: "worker"},
+		{Role: "worker"},
+	}
+	cc.Spec.K0s.Manifests = []k0da.Manifest{{Path: "testdata/app.yaml", Namespace: "e2e"}}
+	if err := cc.Validate(); err != nil {
+		panic(err)
+	}
+	fmt.Println("nodes:", len(cc.Spec.Nodes))
+	// Output:
+	// nodes: 3
+}
+
+func ExampleManager() {
+	// Keep the state and kubeconfig of the example out of the user's home.
+	home, _ := os.MkdirTemp("", "k0da-example-*")
+	defer func() { _ = os.RemoveAll(home) }()
+	defer os.Setenv(k0da.HomeEnv, os.Getenv(k0da.HomeEnv))
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv(k0da.HomeEnv, home)
+	os.Setenv("KUBECONFIG", filepath.Join(home, "kubeconfig"))
+
+	cc := &k0da.ClusterConfig{}
+	cc.Spec.K0s.Version = "v1.33.3-k0s.0"
+	// Nothing runs on the host, so skip the host checks and mounts.
+	cc.Spec.Options.CgroupNS = "host"
+	noModules := false
+	cc.Spec.Options.MountKernelModules = &noModules
+	if err := cc.Validate(); err != nil {
+		panic(err)
+	}
+
+	ctx := context.Background()
+	// Progress messages are discarded unless m.Out is set.
+	m := k0da.New(&fakeRuntime{})
+	result, err := m.Create(ctx, k0da.CreateOptions{Name: "demo", Config: cc, WaitFor: k0da.WaitForAPI})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("created:", result.Context, result.Nodes)
+
+	clusters, _ := m.List(ctx, true, k0da.Filter{})
+	for _, c := range clusters {
+		fmt.Println("listed:", c.Name, c.Nodes)
+	}
+
+	if err := m.Delete(ctx, "demo"); err != nil {
+		panic(err)
+	}
+	clusters, _ = m.List(ctx, true, k0da.Filter{})
+	fmt.Println("after delete:", len(clusters))
+	// Output:
+	// created: k0da-demo [demo]
+	// listed: demo 1
+	// after delete: 0
+}
@@ -0,0 +1,83 @@
+// Package k0da creates and manages k0s clusters in containers from Go, e.g. in test
+// harnesses of other modules. It exposes the operations of the k0da CLI through
+// Manager; the types are aliases of the ones the CLI uses.
+package k0da
+
+import (
+	"context"
+
+	"github.com/makhov/k0da/internal/cluster"
+	"github.com/makhov/k0da/internal/config"
+	"github.com/makhov/k0da/internal/paths"
+	"github.com/makhov/k0da/internal/runtime"
+)
+
+// Runtime is a container runtime clusters run on. Implement it to exercise the
+// orchestration without containers.
+type Runtime = runtime.Runtime
+
+// Types of the Runtime methods.
+type (
+	ContainerInfo       = runtime.ContainerInfo
+	RunContainerOptions = runtime.RunContainerOptions
+	RecreateOptions     = runtime.RecreateOptions
+	LogsOptions         = runtime.LogsOptions
+	NetworkOptions      = runtime.NetworkOptions
+	DetectOptions       = runtime.DetectOptions
+)
+
+// States of ContainerInfo.State.
+const (
+	ContainerRunning = runtime.StateRunning
+	ContainerExited  = runtime.StateExited
+	ContainerCreated = runtime.StateCreated
+	ContainerPaused  = runtime.StatePaused
+)
+
+// Cluster configs and their parts, e.g. to build a multi-node config in code.
+type (
+	ClusterConfig = config.ClusterConfig
+	Spec          = config.Spec
+	OptionsSpec   = config.OptionsSpec
+	K0sSpec       = config.K0sSpec
+	NodeSpec      = config.NodeSpec
+	Port          = config.Port
+	Mount         = config.Mount
+	Manifest      = config.Manifest
+	HelmChart     = config.HelmChart
+	RegistryAuth  = config.RegistryAuth
+)
+
+// APIVersion is the apiVersion of cluster configs.
+const APIVersion = config.APIVersion
+
+// Options and results of the Manager operations.
+type (
+	CreateOptions = cluster.CreateOptions
+	UpdateOptions = cluster.UpdateOptions
+	Result        = cluster.Result
+	Info          = cluster.Info
+	Filter        = cluster.Filter
+)
+
+// Values of CreateOptions.WaitFor.
+const (
+	WaitForAPI = cluster.WaitForAPI
+	WaitForAll = cluster.WaitForAll
+)
+
+// HomeEnv is the environment variable that moves the state k0da keeps, by default in
+// ~/.k0da, e.g. to a temporary directory per test run.
+const HomeEnv = paths.HomeEnv
+
+// Detect returns the container runtime selected by opts, $K0DA_RUNTIME or the first
+// one found, like the CLI does.
+func Detect(ctx context.Context, opts DetectOptions) (Runtime, error) {
+	return runtime.Detect(ctx, opts)
+}
+
+// LoadConfig loads and validates a cluster config file; an empty path returns the
+// default config.
+func LoadConfig(path string) (*ClusterConfig, error) {
+	return config.LoadClusterConfig(path)
+}
@@ -0,0 +1,57 @@
+package k0da
+
+import (
+	"context"
+	"io"
+
+	"github.com/makhov/k0da/internal/cluster"
+)
+
+// Manager runs cluster operations against one container runtime: pass the runtime
+// returned by Detect, or a fake implementing Runtime.
+type Manager struct {
+	r Runtime
+	// Out receives the progress messages of Create and Delete; nil discards them.
+	Out io.Writer
+}
+
+// New returns a Manager using r.
+func New(r Runtime) *Manager {
+	return &Manager{r: r}
+}
+
+// Runtime returns the runtime the Manager uses.
+func (m *Manager) Runtime() Runtime {
+	return m.r
+}
+
+// Create creates a cluster and writes its kubeconfig context. Progress goes to m.Out
+// unless opts.Out is set.
+func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*Result, error) {
+	if opts.Out == nil {
+		opts.Out = m.Out
+	}
+	return cluster.Create(ctx, m.r, opts)
+}
+
+// Delete removes the nodes, volumes and kubeconfig context of a cluster.
+func (m *Manager) Delete(ctx context.Context, clusterName string) error {
+	return cluster.Delete(ctx, m.r, clusterName, m.Out)
+}
+
+// List returns the clusters matching filter.
+func (m *Manager) List(ctx context.Context, includeStopped bool, filter Filter) ([]Info, error) {
+	return cluster.List(ctx, m.r, includeStopped, filter)
+}
+
+// Update applies a changed config to a running cluster.
+func (m *Manager) Update(ctx context.Context, opts UpdateOptions) error {
+	return cluster.Update(ctx, m.r, opts)
+}
+
+// LoadImage copies an image from the host runtime into the nodes of a cluster. It
+// returns the loaded refs and whether anything was loaded: unless force is set, an
+// image the nodes already have is skipped.
+func (m *Manager) LoadImage(ctx context.Context, clusterName, imageRef string, force bool) ([]string, bool, error) {
+	return cluster.LoadImage(ctx, m.r, clusterName, imageRef, force)
+}